@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Configuration analysis across meshes, in the spirit of istioctl analyze.
+// Each check reads the live resources and reports problems that the API
+// server accepts but that break or degrade traffic at runtime.
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+type ResourceRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+type AnalysisResult struct {
+	Mesh        string      `json:"mesh"`
+	Code        string      `json:"code"`
+	Severity    string      `json:"severity"`
+	Resource    ResourceRef `json:"resource"`
+	Message     string      `json:"message"`
+	Remediation string      `json:"remediation"`
+}
+
+// Snapshot of the core resources the checks look up
+type analysisContext struct {
+	services   map[string]bool
+	namespaces []corev1.Namespace
+	pods       []corev1.Pod
+}
+
+func loadAnalysisContext() (*analysisContext, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := clientset.CoreV1().Services("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %v", err)
+	}
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %v", err)
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+
+	ctx := &analysisContext{
+		services:   make(map[string]bool),
+		namespaces: namespaces.Items,
+		pods:       pods.Items,
+	}
+	for _, svc := range services.Items {
+		ctx.services[fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace)] = true
+	}
+	return ctx, nil
+}
+
+func (ctx *analysisContext) serviceExists(name, namespace string) bool {
+	return ctx.services[fqdnForHost(name, namespace)]
+}
+
+// Destination hosts of a VirtualService with the field path of each
+func virtualServiceDestinations(vs VirtualService) map[string]string {
+	destinations := make(map[string]string)
+	for i, route := range vs.HTTP {
+		for j, dest := range route.Route {
+			destinations[fmt.Sprintf("http[%d].route[%d]", i, j)] = dest.Destination.Host
+		}
+		if route.Mirror != nil {
+			destinations[fmt.Sprintf("http[%d].mirror", i)] = route.Mirror.Host
+		}
+	}
+	for field, routes := range map[string][]map[string]interface{}{"tcp": vs.TCP, "tls": vs.TLS} {
+		for i, route := range routes {
+			for j, dest := range jsonList(route, "route") {
+				if host := jsonString(jsonMap(dest, "destination"), "host"); host != "" {
+					destinations[fmt.Sprintf("%s[%d].route[%d]", field, i, j)] = host
+				}
+			}
+		}
+	}
+	return destinations
+}
+
+func analyzeIstio(ctx *analysisContext) ([]AnalysisResult, []string) {
+	results := []AnalysisResult{}
+	var skipped []string
+
+	virtualServices, err := getVirtualServices("")
+	if err != nil {
+		return results, []string{fmt.Sprintf("istio: VirtualServices unavailable: %v", err)}
+	}
+	destinationRules, err := getDestinationRules("")
+	if err != nil {
+		skipped = append(skipped, fmt.Sprintf("istio: DestinationRules unavailable: %v", err))
+	}
+	gateways, err := getGateways("")
+	if err != nil {
+		skipped = append(skipped, fmt.Sprintf("istio: Gateways unavailable: %v", err))
+	}
+	serviceEntries, err := getServiceEntries("")
+	if err != nil {
+		skipped = append(skipped, fmt.Sprintf("istio: ServiceEntries unavailable: %v", err))
+	}
+
+	externalHosts := make(map[string]bool)
+	for _, se := range serviceEntries {
+		for _, host := range se.Hosts {
+			externalHosts[fqdnForHost(host, se.Namespace)] = true
+		}
+	}
+	hostKnown := func(host, namespace string) bool {
+		fqdn := fqdnForHost(host, namespace)
+		if strings.Contains(fqdn, "*") || ctx.services[fqdn] || externalHosts[fqdn] {
+			return true
+		}
+		for pattern := range externalHosts {
+			if strings.HasPrefix(pattern, "*.") && gatewayHostMatches(pattern, fqdn) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// VirtualService routes to hosts with no Service or ServiceEntry
+	for _, vs := range virtualServices {
+		fields := make([]string, 0)
+		destinations := virtualServiceDestinations(vs)
+		for field := range destinations {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			host := destinations[field]
+			if hostKnown(host, vs.Namespace) {
+				continue
+			}
+			results = append(results, AnalysisResult{
+				Mesh:     "istio",
+				Code:     "ReferencedHostNotFound",
+				Severity: severityError,
+				Resource: ResourceRef{Kind: "VirtualService", Namespace: vs.Namespace, Name: vs.Name},
+				Message:  fmt.Sprintf("%s routes to %s, which matches no Service or ServiceEntry", field, fqdnForHost(host, vs.Namespace)),
+				Remediation: fmt.Sprintf("Create the Service %s, add a ServiceEntry for it, or fix the destination host in the VirtualService",
+					host),
+			})
+		}
+	}
+
+	// VirtualServices bound to gateways that are missing or do not serve their hosts
+	if gateways != nil {
+		for _, issue := range findGatewayHostIssues(virtualServices, gateways) {
+			remediation := fmt.Sprintf("Add %s to a server on gateway %s, or remove the gateway from the VirtualService", issue.Host, issue.Gateway)
+			if issue.Host == "" {
+				remediation = fmt.Sprintf("Create gateway %s or reference an existing gateway as namespace/name", issue.Gateway)
+			}
+			results = append(results, AnalysisResult{
+				Mesh:        "istio",
+				Code:        "VirtualServiceGatewayMismatch",
+				Severity:    severityError,
+				Resource:    ResourceRef{Kind: "VirtualService", Namespace: issue.Namespace, Name: issue.VirtualService},
+				Message:     issue.Message,
+				Remediation: remediation,
+			})
+		}
+	}
+
+	if destinationRules != nil {
+		// Several DestinationRules for one host: Istio applies only one of them
+		byHost := make(map[string][]DestinationRule)
+		for _, dr := range destinationRules {
+			host := fqdnForHost(dr.Host, dr.Namespace)
+			byHost[host] = append(byHost[host], dr)
+		}
+		hosts := make([]string, 0, len(byHost))
+		for host := range byHost {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+
+		for _, host := range hosts {
+			rules := byHost[host]
+			if len(rules) < 2 {
+				continue
+			}
+			var names []string
+			for _, dr := range rules {
+				names = append(names, dr.Namespace+"/"+dr.Name)
+			}
+			for _, dr := range rules {
+				results = append(results, AnalysisResult{
+					Mesh:     "istio",
+					Code:     "ConflictingDestinationRules",
+					Severity: severityWarning,
+					Resource: ResourceRef{Kind: "DestinationRule", Namespace: dr.Namespace, Name: dr.Name},
+					Message: fmt.Sprintf("%d DestinationRules target %s (%s); only one traffic policy takes effect",
+						len(rules), host, strings.Join(names, ", ")),
+					Remediation: "Merge the subsets and traffic policies into a single DestinationRule for the host",
+				})
+			}
+		}
+
+		for _, issue := range findSubsetIssues(virtualServices, destinationRules) {
+			results = append(results, AnalysisResult{
+				Mesh:        "istio",
+				Code:        "ReferencedSubsetNotFound",
+				Severity:    severityError,
+				Resource:    ResourceRef{Kind: "VirtualService", Namespace: issue.Namespace, Name: issue.VirtualService},
+				Message:     fmt.Sprintf("%s: %s", issue.Route, issue.Message),
+				Remediation: fmt.Sprintf("Define subset %s in a DestinationRule for %s", issue.Subset, issue.Host),
+			})
+		}
+	}
+
+	// Running pods without a sidecar in namespaces labelled for injection
+	injected := make(map[string]bool)
+	for _, namespace := range ctx.namespaces {
+		if namespace.Labels["istio-injection"] == "enabled" || namespace.Labels["istio.io/rev"] != "" {
+			injected[namespace.Name] = true
+		}
+	}
+	for _, pod := range ctx.pods {
+		if !injected[pod.Namespace] || pod.Status.Phase != corev1.PodRunning || pod.Spec.HostNetwork || hasIstioSidecar(pod) {
+			continue
+		}
+		if pod.Labels["sidecar.istio.io/inject"] == "false" || pod.Annotations["sidecar.istio.io/inject"] == "false" {
+			continue
+		}
+		results = append(results, AnalysisResult{
+			Mesh:     "istio",
+			Code:     "PodMissingProxy",
+			Severity: severityWarning,
+			Resource: ResourceRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name},
+			Message:  fmt.Sprintf("pod runs without an istio-proxy sidecar although namespace %s is labelled for injection", pod.Namespace),
+			Remediation: fmt.Sprintf("Restart workload %s so the sidecar is injected, or opt it out with sidecar.istio.io/inject=false",
+				podWorkloadName(pod)),
+		})
+	}
+
+	return results, skipped
+}
+
+// Split a ServiceProfile name of the form service.namespace.svc.cluster.local
+func serviceProfileTarget(name string) (string, string, bool) {
+	if !strings.HasSuffix(name, ".svc.cluster.local") {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimSuffix(name, ".svc.cluster.local"), ".")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func analyzeLinkerd(ctx *analysisContext) ([]AnalysisResult, []string) {
+	results := []AnalysisResult{}
+
+	serviceProfiles, err := getServiceProfiles()
+	if err != nil {
+		return results, []string{fmt.Sprintf("linkerd: ServiceProfiles unavailable: %v", err)}
+	}
+	for _, sp := range serviceProfiles {
+		service, namespace, ok := serviceProfileTarget(sp.Name)
+		if !ok || ctx.serviceExists(service, namespace) {
+			continue
+		}
+		results = append(results, AnalysisResult{
+			Mesh:        "linkerd",
+			Code:        "ServiceProfileServiceNotFound",
+			Severity:    severityWarning,
+			Resource:    ResourceRef{Kind: "ServiceProfile", Namespace: sp.Namespace, Name: sp.Name},
+			Message:     fmt.Sprintf("ServiceProfile applies to %s/%s, which does not exist", namespace, service),
+			Remediation: fmt.Sprintf("Delete the ServiceProfile or create Service %s in namespace %s", service, namespace),
+		})
+	}
+
+	trafficSplits, err := getTrafficSplits()
+	if err != nil {
+		return results, []string{fmt.Sprintf("linkerd: TrafficSplits unavailable: %v", err)}
+	}
+	for _, split := range trafficSplits {
+		ref := ResourceRef{Kind: "TrafficSplit", Namespace: split.Namespace, Name: split.Name}
+
+		if !ctx.serviceExists(split.Service, split.Namespace) {
+			results = append(results, AnalysisResult{
+				Mesh:        "linkerd",
+				Code:        "TrafficSplitServiceNotFound",
+				Severity:    severityError,
+				Resource:    ref,
+				Message:     fmt.Sprintf("apex service %s does not exist", split.Service),
+				Remediation: fmt.Sprintf("Create Service %s in namespace %s; clients address the split through it", split.Service, split.Namespace),
+			})
+		}
+
+		total := 0
+		for _, backend := range split.Backends {
+			total += backend.Weight
+			if !ctx.serviceExists(backend.Service, split.Namespace) {
+				results = append(results, AnalysisResult{
+					Mesh:        "linkerd",
+					Code:        "TrafficSplitBackendNotFound",
+					Severity:    severityError,
+					Resource:    ref,
+					Message:     fmt.Sprintf("backend service %s does not exist; requests routed to it fail", backend.Service),
+					Remediation: fmt.Sprintf("Create Service %s in namespace %s or remove the backend", backend.Service, split.Namespace),
+				})
+			}
+		}
+
+		switch {
+		case len(split.Backends) == 0:
+			results = append(results, AnalysisResult{
+				Mesh:        "linkerd",
+				Code:        "TrafficSplitNoBackends",
+				Severity:    severityError,
+				Resource:    ref,
+				Message:     "TrafficSplit has no backends",
+				Remediation: "Add at least one backend service with a positive weight",
+			})
+		case total == 0:
+			results = append(results, AnalysisResult{
+				Mesh:        "linkerd",
+				Code:        "TrafficSplitWeights",
+				Severity:    severityError,
+				Resource:    ref,
+				Message:     "all backend weights are zero, so no traffic is routed",
+				Remediation: "Give at least one backend a positive weight",
+			})
+		case total != 100:
+			results = append(results, AnalysisResult{
+				Mesh:        "linkerd",
+				Code:        "TrafficSplitWeights",
+				Severity:    severityWarning,
+				Resource:    ref,
+				Message:     fmt.Sprintf("backend weights sum to %d rather than 100; traffic is split by relative weight", total),
+				Remediation: "Adjust the weights to add up to 100 so they read as percentages",
+			})
+		}
+	}
+
+	return results, nil
+}
+
+func runAnalysis(mesh, namespace string) ([]AnalysisResult, []string, error) {
+	ctx, err := loadAnalysisContext()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := []AnalysisResult{}
+	skipped := []string{}
+	if mesh == "" || mesh == "istio" {
+		found, notes := analyzeIstio(ctx)
+		results = append(results, found...)
+		skipped = append(skipped, notes...)
+	}
+	if mesh == "" || mesh == "linkerd" {
+		found, notes := analyzeLinkerd(ctx)
+		results = append(results, found...)
+		skipped = append(skipped, notes...)
+	}
+
+	if namespace != "" {
+		filtered := []AnalysisResult{}
+		for _, result := range results {
+			if result.Resource.Namespace == namespace {
+				filtered = append(filtered, result)
+			}
+		}
+		results = filtered
+	}
+
+	rank := map[string]int{severityError: 0, severityWarning: 1, severityInfo: 2}
+	sort.SliceStable(results, func(i, j int) bool {
+		return rank[results[i].Severity] < rank[results[j].Severity]
+	})
+
+	return results, skipped, nil
+}
+
+func registerAnalyzeRoutes(e *echo.Echo) {
+	// Run configuration checks. ?mesh=istio|linkerd limits the checks and
+	// ?namespace= the reported resources.
+	e.GET("/api/analyze", func(c echo.Context) error {
+		mesh := c.QueryParam("mesh")
+		if mesh != "" && mesh != "istio" && mesh != "linkerd" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("unsupported mesh %q (use istio or linkerd)", mesh),
+			})
+		}
+
+		results, skipped, err := runAnalysis(mesh, c.QueryParam("namespace"))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to analyze configuration: %v", err),
+			})
+		}
+
+		summary := map[string]int{severityError: 0, severityWarning: 0, severityInfo: 0}
+		for _, result := range results {
+			summary[result.Severity]++
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"results": results,
+			"count":   len(results),
+			"summary": summary,
+			"skipped": skipped,
+		})
+	})
+}
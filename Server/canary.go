@@ -0,0 +1,591 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Mesh-agnostic canary releases. A canary shifts traffic for one service
+// between a stable and a canary version:
+//   - istio:   a DestinationRule with one subset per version plus a weighted
+//              VirtualService, both named after the service
+//   - linkerd: an SMI TrafficSplit, or a policy.linkerd.io HTTPRoute, across
+//              per-version backend Services
+
+type Canary struct {
+	Mesh          string        `json:"mesh"`
+	Namespace     string        `json:"namespace"`
+	Service       string        `json:"service"`
+	StableVersion string        `json:"stable_version"`
+	CanaryVersion string        `json:"canary_version"`
+	VersionLabel  string        `json:"version_label,omitempty"`
+	StableBackend string        `json:"stable_backend,omitempty"`
+	CanaryBackend string        `json:"canary_backend,omitempty"`
+	LinkerdMode   string        `json:"linkerd_mode,omitempty"`
+	CanaryWeight  int           `json:"canary_weight"`
+	Steps         []int         `json:"steps"`
+	Phase         string        `json:"phase"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	History       []CanaryEvent `json:"history"`
+}
+
+type CanaryEvent struct {
+	Time         time.Time `json:"time"`
+	Action       string    `json:"action"`
+	CanaryWeight int       `json:"canary_weight"`
+	Message      string    `json:"message,omitempty"`
+}
+
+const (
+	CanaryPhaseProgressing = "Progressing"
+	CanaryPhasePromoted    = "Promoted"
+	CanaryPhaseRolledBack  = "RolledBack"
+)
+
+var defaultCanarySteps = []int{10, 25, 50, 100}
+
+// Global canary registry, keyed by namespace/service
+var (
+	canaries      = make(map[string]*Canary)
+	canariesMutex sync.Mutex
+)
+
+func canaryKey(namespace, service string) string {
+	return namespace + "/" + service
+}
+
+func validateCanary(canary Canary) []string {
+	var errors []string
+
+	if canary.Mesh != "istio" && canary.Mesh != "linkerd" {
+		errors = append(errors, "mesh must be istio or linkerd")
+	}
+	if canary.Service == "" {
+		errors = append(errors, "service is required")
+	}
+	if canary.StableVersion == "" || canary.CanaryVersion == "" {
+		errors = append(errors, "stable_version and canary_version are required")
+	} else if canary.StableVersion == canary.CanaryVersion {
+		errors = append(errors, "stable_version and canary_version must differ")
+	}
+	if canary.Mesh == "linkerd" && canary.LinkerdMode != "smi" && canary.LinkerdMode != "httproute" {
+		errors = append(errors, "linkerd_mode must be smi or httproute")
+	}
+	if canary.CanaryWeight < 0 || canary.CanaryWeight > 100 {
+		errors = append(errors, "canary_weight must be between 0 and 100")
+	}
+
+	last := 0
+	for i, step := range canary.Steps {
+		if step <= last || step > 100 {
+			errors = append(errors, fmt.Sprintf("steps[%d]: steps must increase and stay within 1-100", i))
+			break
+		}
+		last = step
+	}
+
+	return errors
+}
+
+// Fill in defaults for optional canary fields
+func normalizeCanary(canary *Canary) {
+	if canary.Namespace == "" {
+		canary.Namespace = "default"
+	}
+	if canary.VersionLabel == "" {
+		canary.VersionLabel = "version"
+	}
+	if len(canary.Steps) == 0 {
+		canary.Steps = defaultCanarySteps
+	}
+	if canary.Mesh == "linkerd" {
+		if canary.LinkerdMode == "" {
+			canary.LinkerdMode = "smi"
+		}
+		if canary.StableBackend == "" {
+			canary.StableBackend = canary.Service + "-" + canary.StableVersion
+		}
+		if canary.CanaryBackend == "" {
+			canary.CanaryBackend = canary.Service + "-" + canary.CanaryVersion
+		}
+	}
+}
+
+// Refuse to take over routing resources that Meshify did not create
+func checkCanaryOwnership(canary Canary) error {
+	kind := ""
+	name := canary.Service
+	switch {
+	case canary.Mesh == "istio":
+		kind = "virtualservices.networking.istio.io"
+	case canary.LinkerdMode == "httproute":
+		kind = "httproutes.policy.linkerd.io"
+	default:
+		kind = "trafficsplits.split.smi-spec.io"
+	}
+
+	resource, err := kubectlGetResource(kind, canary.Namespace, name)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	if resource.Metadata.Labels["app.kubernetes.io/managed-by"] != "meshify" {
+		return fmt.Errorf("%s %s/%s already exists and is not managed by Meshify", resource.Kind, canary.Namespace, name)
+	}
+	return nil
+}
+
+// Write the routing resources that send weight percent of traffic to the canary
+func applyCanaryWeight(canary *Canary, weight int) error {
+	var manifests []k8sResource
+	var applySubsets func(dryRun bool) error
+
+	switch {
+	case canary.Mesh == "istio":
+		dr, exists, err := canaryDestinationRule(*canary)
+		if err != nil {
+			return err
+		}
+		applySubsets = func(dryRun bool) error { return applyCanarySubsets(*dr, exists, dryRun) }
+		manifests = append(manifests, virtualServiceManifest(canaryVirtualService(*canary, weight)))
+	case canary.LinkerdMode == "httproute":
+		route, err := canaryHTTPRoute(*canary, weight)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, route)
+	default:
+		manifests = append(manifests, trafficSplitManifest(TrafficSplit{
+			Name:      canary.Service,
+			Namespace: canary.Namespace,
+			Service:   canary.Service,
+			Backends: []TrafficSplitBackend{
+				{Service: canary.StableBackend, Weight: 100 - weight},
+				{Service: canary.CanaryBackend, Weight: weight},
+			},
+		}))
+	}
+
+	if applySubsets != nil {
+		if err := applySubsets(true); err != nil {
+			return err
+		}
+	}
+	for _, manifest := range manifests {
+		if err := kubectlDryRun(manifest, false); err != nil {
+			return err
+		}
+	}
+	if applySubsets != nil {
+		if err := applySubsets(false); err != nil {
+			return err
+		}
+	}
+	for _, manifest := range manifests {
+		if _, err := kubectlApply(manifest, false); err != nil {
+			return err
+		}
+	}
+
+	canary.CanaryWeight = weight
+	canary.UpdatedAt = time.Now()
+	return nil
+}
+
+// Build the DestinationRule for an Istio canary, keeping any subsets and
+// traffic policy already defined for the service, and report whether it
+// already exists
+func canaryDestinationRule(canary Canary) (*DestinationRule, bool, error) {
+	exists := true
+	dr, err := getDestinationRule(canary.Namespace, canary.Service)
+	if err != nil {
+		if !isNotFoundError(err) {
+			return nil, false, err
+		}
+		exists = false
+		dr = &DestinationRule{
+			Name:      canary.Service,
+			Namespace: canary.Namespace,
+			Host:      canary.Service,
+		}
+	}
+
+	for _, version := range []string{canary.StableVersion, canary.CanaryVersion} {
+		subset := Subset{Name: version, Labels: map[string]string{canary.VersionLabel: version}}
+		replaced := false
+		for i := range dr.Subsets {
+			if dr.Subsets[i].Name == version {
+				if err := dr.Subsets[i].setLabels(subset.Labels); err != nil {
+					return nil, false, err
+				}
+				replaced = true
+			}
+		}
+		if !replaced {
+			dr.Subsets = append(dr.Subsets, subset)
+		}
+	}
+
+	return dr, exists, nil
+}
+
+// Write the canary subsets. A new DestinationRule is created and managed by
+// Meshify; an existing one, which may belong to someone else, is only
+// merge-patched at spec.subsets so its other fields and labels stay as they
+// are.
+func applyCanarySubsets(dr DestinationRule, exists, dryRun bool) error {
+	if !exists {
+		if dryRun {
+			return kubectlDryRun(destinationRuleManifest(dr), true)
+		}
+		_, err := kubectlCreate(destinationRuleManifest(dr), false)
+		return err
+	}
+
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{"subsets": dr.Subsets},
+	}
+	_, err := kubectlPatch("destinationrules.networking.istio.io", dr.Namespace, dr.Name, "merge", patch, dryRun)
+	return err
+}
+
+func canaryVirtualService(canary Canary, weight int) VirtualService {
+	return VirtualService{
+		Name:      canary.Service,
+		Namespace: canary.Namespace,
+		Hosts:     []string{canary.Service},
+		HTTP: []HTTPRoute{
+			{
+				Name: "canary",
+				Route: []HTTPRouteDestination{
+					{Destination: Destination{Host: canary.Service, Subset: canary.StableVersion}, Weight: int32(100 - weight)},
+					{Destination: Destination{Host: canary.Service, Subset: canary.CanaryVersion}, Weight: int32(weight)},
+				},
+			},
+		},
+	}
+}
+
+// Linkerd HTTPRoute attached to the service, splitting across backend Services
+func canaryHTTPRoute(canary Canary, weight int) (k8sResource, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return k8sResource{}, err
+	}
+
+	svc, err := clientset.CoreV1().Services(canary.Namespace).Get(context.Background(), canary.Service, metav1.GetOptions{})
+	if err != nil {
+		return k8sResource{}, err
+	}
+	if len(svc.Spec.Ports) == 0 {
+		return k8sResource{}, fmt.Errorf("service %s/%s exposes no ports", canary.Namespace, canary.Service)
+	}
+	port := svc.Spec.Ports[0].Port
+
+	return k8sResource{
+		APIVersion: "policy.linkerd.io/v1beta2",
+		Kind:       "HTTPRoute",
+		Metadata: k8sObjectMeta{
+			Name:      canary.Service,
+			Namespace: canary.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: map[string]interface{}{
+			"parentRefs": []map[string]interface{}{
+				{"name": canary.Service, "kind": "Service", "group": "core", "port": port},
+			},
+			"rules": []map[string]interface{}{
+				{
+					"backendRefs": []map[string]interface{}{
+						{"name": canary.StableBackend, "port": port, "weight": 100 - weight},
+						{"name": canary.CanaryBackend, "port": port, "weight": weight},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// Remove the routing resources so traffic falls back to plain Service
+// load balancing. The Istio DestinationRule is kept since other routes may
+// rely on its subsets.
+func deleteCanaryResources(canary Canary) error {
+	kind := "trafficsplits.split.smi-spec.io"
+	switch {
+	case canary.Mesh == "istio":
+		kind = "virtualservices.networking.istio.io"
+	case canary.LinkerdMode == "httproute":
+		kind = "httproutes.policy.linkerd.io"
+	}
+
+	if _, err := kubectlDeleteResource(kind, canary.Namespace, canary.Service); err != nil && !isNotFoundError(err) {
+		return err
+	}
+	return nil
+}
+
+// Next step weight above the current one, or 100 once the steps run out
+func nextCanaryStep(canary Canary) int {
+	for _, step := range canary.Steps {
+		if step > canary.CanaryWeight {
+			return step
+		}
+	}
+	return 100
+}
+
+// Move a canary to a new weight and record the change
+func setCanaryWeight(canary *Canary, weight int, action, message string) error {
+	if err := applyCanaryWeight(canary, weight); err != nil {
+		return err
+	}
+
+	switch {
+	case action == "rollback":
+		canary.Phase = CanaryPhaseRolledBack
+	case weight == 100:
+		canary.Phase = CanaryPhasePromoted
+	default:
+		canary.Phase = CanaryPhaseProgressing
+	}
+
+	canary.History = append(canary.History, CanaryEvent{
+		Time:         canary.UpdatedAt,
+		Action:       action,
+		CanaryWeight: weight,
+		Message:      message,
+	})
+	log.Printf("Canary %s/%s: %s to %d%% canary traffic", canary.Namespace, canary.Service, action, weight)
+	return nil
+}
+
+// Start a validated canary at its requested weight and register it. On
+// failure the returned status is the HTTP status to report.
+func startCanary(canary *Canary) (int, error) {
+	canariesMutex.Lock()
+	defer canariesMutex.Unlock()
+
+	if _, exists := canaries[canaryKey(canary.Namespace, canary.Service)]; exists {
+		return http.StatusConflict, fmt.Errorf("a canary for %s/%s already exists", canary.Namespace, canary.Service)
+	}
+	if err := checkCanaryOwnership(*canary); err != nil {
+		return http.StatusConflict, err
+	}
+
+	weight := canary.CanaryWeight
+	canary.CanaryWeight = 0
+	canary.CreatedAt = time.Now()
+	canary.History = []CanaryEvent{}
+
+	if err := setCanaryWeight(canary, weight, "start", ""); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	canaries[canaryKey(canary.Namespace, canary.Service)] = canary
+	return http.StatusCreated, nil
+}
+
+func getCanary(namespace, service string) (*Canary, bool) {
+	canariesMutex.Lock()
+	defer canariesMutex.Unlock()
+
+	canary, exists := canaries[canaryKey(namespace, service)]
+	return canary, exists
+}
+
+func registerCanaryRoutes(e *echo.Echo) {
+	// List canaries
+	e.GET("/api/canaries", func(c echo.Context) error {
+		canariesMutex.Lock()
+		list := []Canary{}
+		for _, canary := range canaries {
+			list = append(list, *canary)
+		}
+		canariesMutex.Unlock()
+
+		sort.Slice(list, func(i, j int) bool {
+			return canaryKey(list[i].Namespace, list[i].Service) < canaryKey(list[j].Namespace, list[j].Service)
+		})
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"canaries": list,
+			"count":    len(list),
+		})
+	})
+
+	// Start a canary
+	e.POST("/api/canaries", func(c echo.Context) error {
+		var canary Canary
+		if err := c.Bind(&canary); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid canary payload",
+			})
+		}
+		normalizeCanary(&canary)
+
+		if errors := validateCanary(canary); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		if status, err := startCanary(&canary); err != nil {
+			log.Printf("Error starting canary: %v", err)
+			return c.JSON(status, map[string]string{
+				"error": fmt.Sprintf("Failed to start canary: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusCreated, map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Canary started with %d%% traffic to %s", canary.CanaryWeight, canary.CanaryVersion),
+			"canary":  canary,
+		})
+	})
+
+	// Get a canary
+	e.GET("/api/canaries/:namespace/:service", func(c echo.Context) error {
+		canary, exists := getCanary(c.Param("namespace"), c.Param("service"))
+		if !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Canary not found",
+			})
+		}
+
+		canariesMutex.Lock()
+		defer canariesMutex.Unlock()
+		return c.JSON(http.StatusOK, canary)
+	})
+
+	// Set the canary weight directly
+	e.PUT("/api/canaries/:namespace/:service/weight", func(c echo.Context) error {
+		var request struct {
+			CanaryWeight int `json:"canary_weight"`
+		}
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid weight payload",
+			})
+		}
+		if request.CanaryWeight < 0 || request.CanaryWeight > 100 {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "canary_weight must be between 0 and 100",
+			})
+		}
+
+		canary, exists := getCanary(c.Param("namespace"), c.Param("service"))
+		if !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Canary not found",
+			})
+		}
+
+		canariesMutex.Lock()
+		defer canariesMutex.Unlock()
+
+		if err := setCanaryWeight(canary, request.CanaryWeight, "set_weight", ""); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to set canary weight: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Canary weight set to %d%%", canary.CanaryWeight),
+			"canary":  canary,
+		})
+	})
+
+	// Promote the canary to its next step
+	e.POST("/api/canaries/:namespace/:service/promote", func(c echo.Context) error {
+		canary, exists := getCanary(c.Param("namespace"), c.Param("service"))
+		if !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Canary not found",
+			})
+		}
+
+		canariesMutex.Lock()
+		defer canariesMutex.Unlock()
+
+		if canary.Phase == CanaryPhasePromoted {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Canary is already fully promoted",
+			})
+		}
+
+		if err := setCanaryWeight(canary, nextCanaryStep(*canary), "promote", ""); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to promote canary: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Canary promoted to %d%%", canary.CanaryWeight),
+			"canary":  canary,
+		})
+	})
+
+	// Send all traffic back to the stable version
+	e.POST("/api/canaries/:namespace/:service/rollback", func(c echo.Context) error {
+		canary, exists := getCanary(c.Param("namespace"), c.Param("service"))
+		if !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Canary not found",
+			})
+		}
+
+		canariesMutex.Lock()
+		defer canariesMutex.Unlock()
+
+		if err := setCanaryWeight(canary, 0, "rollback", ""); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to roll back canary: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("All traffic returned to %s", canary.StableVersion),
+			"canary":  canary,
+		})
+	})
+
+	// Finish a canary and remove its routing resources
+	e.DELETE("/api/canaries/:namespace/:service", func(c echo.Context) error {
+		canary, exists := getCanary(c.Param("namespace"), c.Param("service"))
+		if !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Canary not found",
+			})
+		}
+
+		canariesMutex.Lock()
+		defer canariesMutex.Unlock()
+
+		if err := deleteCanaryResources(*canary); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to delete canary resources: %v", err),
+			})
+		}
+		delete(canaries, canaryKey(canary.Namespace, canary.Service))
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": "Canary deleted successfully",
+		})
+	})
+}
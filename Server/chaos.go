@@ -0,0 +1,680 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// Istio fault injection experiments. An experiment adds delay and/or abort
+// faults to every HTTP route of the service's VirtualService (creating one if
+// none exists), then takes them out again once the duration is up. Existing
+// VirtualServices are only JSON-patched at spec.http[i].fault, so the rest of
+// the resource, and any change made to it during the experiment, is left
+// alone. The VirtualService is annotated with what was injected, so faults
+// left behind by a restart are found and reverted at startup, and a failed
+// revert is retried until it succeeds. Golden signals are sampled before the
+// faults go in, just before they come out, and again once the post-revert
+// traffic fills the rate window.
+
+type ChaosExperiment struct {
+	ID                    string          `json:"id"`
+	Namespace             string          `json:"namespace"`
+	Service               string          `json:"service"`
+	Delay                 *ChaosDelay     `json:"delay,omitempty"`
+	Abort                 *ChaosAbort     `json:"abort,omitempty"`
+	Duration              string          `json:"duration"`
+	Status                string          `json:"status"`
+	Message               string          `json:"message,omitempty"`
+	VirtualService        string          `json:"virtual_service"`
+	CreatedVirtualService bool            `json:"created_virtual_service"`
+	StartedAt             time.Time       `json:"started_at"`
+	EndsAt                time.Time       `json:"ends_at"`
+	RevertedAt            *time.Time      `json:"reverted_at,omitempty"`
+	Metrics               []GoldenSignals `json:"metrics"`
+
+	// Fault as injected, and the faults the routes had before, by route index
+	injectedFault  json.RawMessage
+	originalFaults map[int]json.RawMessage
+	timer          *time.Timer
+}
+
+type ChaosDelay struct {
+	FixedDelay string  `json:"fixed_delay"`
+	Percent    float64 `json:"percent"`
+}
+
+type ChaosAbort struct {
+	HTTPStatus int32   `json:"http_status"`
+	Percent    float64 `json:"percent"`
+}
+
+// GoldenSignals is one sample of traffic, errors and latency for a service
+type GoldenSignals struct {
+	Phase        string    `json:"phase"`
+	Time         time.Time `json:"time"`
+	RequestRate  *float64  `json:"request_rate"`
+	ErrorRate    *float64  `json:"error_rate"`
+	LatencyP50Ms *float64  `json:"latency_p50_ms"`
+	LatencyP99Ms *float64  `json:"latency_p99_ms"`
+	Errors       []string  `json:"errors,omitempty"`
+}
+
+// Recorded in the chaos annotation of the VirtualService
+type chaosInjection struct {
+	Experiment     string                  `json:"experiment"`
+	Service        string                  `json:"service"`
+	EndsAt         time.Time               `json:"ends_at"`
+	Created        bool                    `json:"created,omitempty"`
+	Fault          json.RawMessage         `json:"fault,omitempty"`
+	OriginalFaults map[int]json.RawMessage `json:"original_faults,omitempty"`
+}
+
+const (
+	ChaosStatusRunning   = "Running"
+	ChaosStatusReverting = "Reverting"
+	ChaosStatusCompleted = "Completed"
+	ChaosStatusStopped   = "Stopped"
+
+	maxChaosDuration = time.Hour
+	chaosRateWindow  = time.Minute
+
+	chaosAnnotation        = "meshify.io/chaos-experiment"
+	chaosRevertBackoff     = 5 * time.Second
+	chaosRevertBackoffMax  = 5 * time.Minute
+	virtualServiceResource = "virtualservices.networking.istio.io"
+)
+
+// Global experiment registry
+var (
+	chaosExperiments      = make(map[string]*ChaosExperiment)
+	chaosExperimentsMutex sync.Mutex
+)
+
+// Sample golden signals from the client side, where injected faults are
+// observed, for all traffic to the service
+func collectGoldenSignals(namespace, service, phase string) GoldenSignals {
+	selector := fmt.Sprintf(`reporter="source",destination_service_name="%s",destination_service_namespace="%s"`, service, namespace)
+	signals := GoldenSignals{Phase: phase, Time: time.Now()}
+
+	queries := []struct {
+		name   string
+		query  string
+		target **float64
+	}{
+		{"request_rate", fmt.Sprintf(`sum(rate(istio_requests_total{%s}[1m]))`, selector), &signals.RequestRate},
+		{"error_rate", fmt.Sprintf(`sum(rate(istio_requests_total{%s,response_code!~"[23].."}[1m])) / sum(rate(istio_requests_total{%s}[1m])) * 100`, selector, selector), &signals.ErrorRate},
+		{"latency_p50_ms", fmt.Sprintf(`histogram_quantile(0.50, sum(rate(istio_request_duration_milliseconds_bucket{%s}[1m])) by (le))`, selector), &signals.LatencyP50Ms},
+		{"latency_p99_ms", fmt.Sprintf(`histogram_quantile(0.99, sum(rate(istio_request_duration_milliseconds_bucket{%s}[1m])) by (le))`, selector), &signals.LatencyP99Ms},
+	}
+
+	for _, q := range queries {
+		value, err := queryPrometheusScalar(q.query)
+		if err != nil {
+			signals.Errors = append(signals.Errors, fmt.Sprintf("%s: %v", q.name, err))
+			continue
+		}
+		*q.target = &value
+	}
+
+	return signals
+}
+
+// Find the mesh-internal VirtualService routing to a service
+func findServiceVirtualService(namespace, service string) (*VirtualService, error) {
+	virtualServices, err := getVirtualServices(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	target := fqdnForHost(service, namespace)
+	for _, vs := range virtualServices {
+		appliesToMesh := len(vs.Gateways) == 0
+		for _, gw := range vs.Gateways {
+			if gw == "mesh" {
+				appliesToMesh = true
+			}
+		}
+		if !appliesToMesh {
+			continue
+		}
+		for _, host := range vs.Hosts {
+			if fqdnForHost(host, vs.Namespace) == target {
+				found := vs
+				return &found, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func chaosFault(experiment ChaosExperiment) *HTTPFaultInjection {
+	fault := &HTTPFaultInjection{}
+	if experiment.Delay != nil {
+		fault.Delay = &FaultDelay{
+			FixedDelay: experiment.Delay.FixedDelay,
+			Percentage: &Percent{Value: experiment.Delay.Percent},
+		}
+	}
+	if experiment.Abort != nil {
+		fault.Abort = &FaultAbort{
+			HTTPStatus: experiment.Abort.HTTPStatus,
+			Percentage: &Percent{Value: experiment.Abort.Percent},
+		}
+	}
+	return fault
+}
+
+func validateChaosExperiment(experiment ChaosExperiment) []string {
+	var errors []string
+
+	if experiment.Service == "" {
+		errors = append(errors, "service is required")
+	}
+	if experiment.Delay == nil && experiment.Abort == nil {
+		errors = append(errors, "a delay or abort fault is required")
+	}
+	if d := experiment.Delay; d != nil {
+		if _, err := time.ParseDuration(d.FixedDelay); err != nil {
+			errors = append(errors, fmt.Sprintf("delay.fixed_delay: invalid duration %q", d.FixedDelay))
+		}
+		if d.Percent <= 0 || d.Percent > 100 {
+			errors = append(errors, "delay.percent must be between 0 and 100")
+		}
+	}
+	if a := experiment.Abort; a != nil {
+		if a.HTTPStatus < 200 || a.HTTPStatus > 599 {
+			errors = append(errors, "abort.http_status must be a valid HTTP status")
+		}
+		if a.Percent <= 0 || a.Percent > 100 {
+			errors = append(errors, "abort.percent must be between 0 and 100")
+		}
+	}
+
+	duration, err := time.ParseDuration(experiment.Duration)
+	if err != nil || duration <= 0 {
+		errors = append(errors, fmt.Sprintf("duration: invalid duration %q", experiment.Duration))
+	} else if duration > maxChaosDuration {
+		errors = append(errors, fmt.Sprintf("duration must not exceed %s", maxChaosDuration))
+	}
+
+	return errors
+}
+
+// A live VirtualService with its routes as raw JSON objects
+func liveVirtualServiceRoutes(namespace, name string) (*k8sRawResource, []map[string]json.RawMessage, error) {
+	resource, err := kubectlGetResource(virtualServiceResource, namespace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	var spec struct {
+		HTTP []map[string]json.RawMessage `json:"http"`
+	}
+	if err := json.Unmarshal(resource.Spec, &spec); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse VirtualService %s/%s: %v", namespace, name, err)
+	}
+	return resource, spec.HTTP, nil
+}
+
+func (experiment *ChaosExperiment) injection() chaosInjection {
+	return chaosInjection{
+		Experiment:     experiment.ID,
+		Service:        experiment.Service,
+		EndsAt:         experiment.EndsAt,
+		Created:        experiment.CreatedVirtualService,
+		Fault:          experiment.injectedFault,
+		OriginalFaults: experiment.originalFaults,
+	}
+}
+
+// JSON patch operation setting the chaos annotation
+func chaosAnnotationPatch(resource *k8sRawResource, injection chaosInjection) (map[string]interface{}, error) {
+	value, err := json.Marshal(injection)
+	if err != nil {
+		return nil, err
+	}
+	if resource.Metadata.Annotations == nil {
+		return map[string]interface{}{
+			"op":    "add",
+			"path":  "/metadata/annotations",
+			"value": map[string]string{chaosAnnotation: string(value)},
+		}, nil
+	}
+	return map[string]interface{}{
+		"op":    "add",
+		"path":  "/metadata/annotations/" + strings.Replace(chaosAnnotation, "/", "~1", -1),
+		"value": string(value),
+	}, nil
+}
+
+// Apply the fault rules for an experiment, remembering what to restore
+func injectChaosFaults(experiment *ChaosExperiment) error {
+	existing, err := findServiceVirtualService(experiment.Namespace, experiment.Service)
+	if err != nil {
+		return err
+	}
+
+	fault := chaosFault(*experiment)
+	probe := VirtualService{
+		Name:      experiment.Service + "-chaos",
+		Namespace: experiment.Namespace,
+		Hosts:     []string{experiment.Service},
+		HTTP: []HTTPRoute{
+			{Route: []HTTPRouteDestination{{Destination: Destination{Host: experiment.Service}}}, Fault: fault},
+		},
+	}
+	if errors := validateVirtualService(probe); len(errors) > 0 {
+		return fmt.Errorf("invalid fault rules: %s", strings.Join(errors, "; "))
+	}
+
+	injected, err := json.Marshal(fault)
+	if err != nil {
+		return err
+	}
+	experiment.injectedFault = injected
+
+	if existing == nil {
+		experiment.VirtualService = probe.Name
+		experiment.CreatedVirtualService = true
+		value, err := json.Marshal(experiment.injection())
+		if err != nil {
+			return err
+		}
+		manifest := virtualServiceManifest(probe)
+		manifest.Metadata.Annotations = map[string]string{chaosAnnotation: string(value)}
+		if err := kubectlDryRun(manifest, true); err != nil {
+			return err
+		}
+		_, err = kubectlCreate(manifest, false)
+		return err
+	}
+
+	experiment.VirtualService = existing.Name
+	resource, routes, err := liveVirtualServiceRoutes(existing.Namespace, existing.Name)
+	if err != nil {
+		return err
+	}
+	if _, ok := resource.Metadata.Annotations[chaosAnnotation]; ok {
+		return fmt.Errorf("VirtualService %s/%s still carries faults from an earlier experiment", existing.Namespace, existing.Name)
+	}
+	if len(routes) == 0 {
+		return fmt.Errorf("VirtualService %s/%s has no HTTP routes to inject faults into", existing.Namespace, existing.Name)
+	}
+
+	experiment.originalFaults = make(map[int]json.RawMessage)
+
+	patch := []map[string]interface{}{}
+	for i, route := range routes {
+		if previous, ok := route["fault"]; ok {
+			experiment.originalFaults[i] = previous
+		}
+		patch = append(patch, map[string]interface{}{
+			"op":    "add",
+			"path":  fmt.Sprintf("/spec/http/%d/fault", i),
+			"value": json.RawMessage(injected),
+		})
+	}
+	annotation, err := chaosAnnotationPatch(resource, experiment.injection())
+	if err != nil {
+		return err
+	}
+	patch = append(patch, annotation)
+
+	if _, err := kubectlPatch(virtualServiceResource, existing.Namespace, existing.Name, "json", patch, true); err != nil {
+		return err
+	}
+	_, err = kubectlPatch(virtualServiceResource, existing.Namespace, existing.Name, "json", patch, false)
+	return err
+}
+
+// Remove the fault rules, deleting the VirtualService if the experiment
+// created it. Only routes still carrying the injected fault are touched; each
+// gets back the fault it had before, or none.
+func revertChaosFaults(experiment *ChaosExperiment) error {
+	if experiment.CreatedVirtualService {
+		if _, err := kubectlDeleteResource(virtualServiceResource, experiment.Namespace, experiment.VirtualService); err != nil && !isNotFoundError(err) {
+			return err
+		}
+		return nil
+	}
+
+	resource, routes, err := liveVirtualServiceRoutes(experiment.Namespace, experiment.VirtualService)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+
+	var injected interface{}
+	json.Unmarshal(experiment.injectedFault, &injected)
+
+	patch := []map[string]interface{}{}
+	for i, route := range routes {
+		current, ok := route["fault"]
+		if !ok || !sameJSON(current, experiment.injectedFault) {
+			continue
+		}
+		path := fmt.Sprintf("/spec/http/%d/fault", i)
+		// Fail rather than clobber if the route changed since it was read
+		patch = append(patch, map[string]interface{}{"op": "test", "path": path, "value": injected})
+		if previous, ok := experiment.originalFaults[i]; ok {
+			patch = append(patch, map[string]interface{}{"op": "replace", "path": path, "value": previous})
+		} else {
+			patch = append(patch, map[string]interface{}{"op": "remove", "path": path})
+		}
+	}
+	if _, ok := resource.Metadata.Annotations[chaosAnnotation]; ok {
+		patch = append(patch, map[string]interface{}{
+			"op":   "remove",
+			"path": "/metadata/annotations/" + strings.Replace(chaosAnnotation, "/", "~1", -1),
+		})
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+
+	_, err = kubectlPatch(virtualServiceResource, experiment.Namespace, experiment.VirtualService, "json", patch, false)
+	return err
+}
+
+// Compare two JSON documents ignoring formatting and key order
+func sameJSON(a, b json.RawMessage) bool {
+	var left, right interface{}
+	if json.Unmarshal(a, &left) != nil || json.Unmarshal(b, &right) != nil {
+		return false
+	}
+	leftJSON, _ := json.Marshal(left)
+	rightJSON, _ := json.Marshal(right)
+	return bytes.Equal(leftJSON, rightJSON)
+}
+
+// End an experiment and schedule the post-revert sample. Must be called with
+// the experiment lock held.
+func endChaosExperiment(experiment *ChaosExperiment, status string) {
+	if experiment.Status != ChaosStatusRunning {
+		return
+	}
+	if experiment.timer != nil {
+		experiment.timer.Stop()
+	}
+
+	experiment.Metrics = append(experiment.Metrics, collectGoldenSignals(experiment.Namespace, experiment.Service, "during"))
+	experiment.Status = ChaosStatusReverting
+	retryChaosRevert(experiment, status, chaosRevertBackoff)
+}
+
+// Revert the faults, retrying with backoff until it succeeds. Must be called
+// with the experiment lock held.
+func retryChaosRevert(experiment *ChaosExperiment, status string, backoff time.Duration) {
+	if err := revertChaosFaults(experiment); err != nil {
+		log.Printf("Error reverting chaos experiment %s, retrying in %s: %v", experiment.ID, backoff, err)
+		experiment.Message = fmt.Sprintf("Failed to revert faults in VirtualService %s/%s, retrying in %s: %v", experiment.Namespace, experiment.VirtualService, backoff, err)
+		next := backoff * 2
+		if next > chaosRevertBackoffMax {
+			next = chaosRevertBackoffMax
+		}
+		time.AfterFunc(backoff, func() {
+			chaosExperimentsMutex.Lock()
+			defer chaosExperimentsMutex.Unlock()
+			retryChaosRevert(experiment, status, next)
+		})
+		return
+	}
+
+	now := time.Now()
+	experiment.Status = status
+	experiment.Message = ""
+	experiment.RevertedAt = &now
+	log.Printf("Chaos experiment %s reverted (%s)", experiment.ID, status)
+
+	id := experiment.ID
+	time.AfterFunc(chaosRateWindow, func() {
+		signals := collectGoldenSignals(experiment.Namespace, experiment.Service, "after")
+
+		chaosExperimentsMutex.Lock()
+		defer chaosExperimentsMutex.Unlock()
+		if experiment, exists := chaosExperiments[id]; exists {
+			experiment.Metrics = append(experiment.Metrics, signals)
+		}
+	})
+}
+
+// End the experiment when its duration is up. Must be called with the
+// experiment lock held.
+func scheduleChaosEnd(experiment *ChaosExperiment) {
+	experiment.timer = time.AfterFunc(time.Until(experiment.EndsAt), func() {
+		chaosExperimentsMutex.Lock()
+		defer chaosExperimentsMutex.Unlock()
+		endChaosExperiment(experiment, ChaosStatusCompleted)
+	})
+}
+
+// Pick up experiments whose faults are still in place after a restart.
+// Experiments that should have ended are reverted straight away, the rest
+// run until their original end time.
+func recoverChaosExperiments() {
+	items, err := kubectlListResources(virtualServiceResource, "")
+	if err != nil {
+		log.Printf("Warning: Could not check VirtualServices for leftover chaos faults: %v", err)
+		return
+	}
+
+	chaosExperimentsMutex.Lock()
+	defer chaosExperimentsMutex.Unlock()
+
+	for _, item := range items {
+		value, ok := item.Metadata.Annotations[chaosAnnotation]
+		if !ok {
+			continue
+		}
+		var injection chaosInjection
+		if err := json.Unmarshal([]byte(value), &injection); err != nil {
+			log.Printf("Warning: Ignoring unreadable chaos annotation on VirtualService %s/%s: %v", item.Metadata.Namespace, item.Metadata.Name, err)
+			continue
+		}
+		if _, exists := chaosExperiments[injection.Experiment]; exists {
+			continue
+		}
+
+		experiment := &ChaosExperiment{
+			ID:                    injection.Experiment,
+			Namespace:             item.Metadata.Namespace,
+			Service:               injection.Service,
+			Status:                ChaosStatusRunning,
+			Message:               "Recovered after a server restart",
+			VirtualService:        item.Metadata.Name,
+			CreatedVirtualService: injection.Created,
+			EndsAt:                injection.EndsAt,
+			Metrics:               []GoldenSignals{},
+			injectedFault:         injection.Fault,
+			originalFaults:        injection.OriginalFaults,
+		}
+		chaosExperiments[experiment.ID] = experiment
+		log.Printf("Recovered chaos experiment %s on VirtualService %s/%s", experiment.ID, experiment.Namespace, experiment.VirtualService)
+
+		if time.Now().Before(experiment.EndsAt) {
+			scheduleChaosEnd(experiment)
+		} else {
+			endChaosExperiment(experiment, ChaosStatusCompleted)
+		}
+	}
+}
+
+// Compare each phase against the baseline
+func chaosReport(experiment ChaosExperiment) map[string]interface{} {
+	samples := make(map[string]GoldenSignals)
+	for _, signals := range experiment.Metrics {
+		samples[signals.Phase] = signals
+	}
+
+	delta := func(phase string, pick func(GoldenSignals) *float64) *float64 {
+		before, ok := samples["before"]
+		sample, ok2 := samples[phase]
+		if !ok || !ok2 || pick(before) == nil || pick(sample) == nil {
+			return nil
+		}
+		d := *pick(sample) - *pick(before)
+		return &d
+	}
+
+	changes := make(map[string]interface{})
+	for _, phase := range []string{"during", "after"} {
+		changes[phase] = map[string]*float64{
+			"request_rate":   delta(phase, func(s GoldenSignals) *float64 { return s.RequestRate }),
+			"error_rate":     delta(phase, func(s GoldenSignals) *float64 { return s.ErrorRate }),
+			"latency_p50_ms": delta(phase, func(s GoldenSignals) *float64 { return s.LatencyP50Ms }),
+			"latency_p99_ms": delta(phase, func(s GoldenSignals) *float64 { return s.LatencyP99Ms }),
+		}
+	}
+
+	return map[string]interface{}{
+		"experiment": experiment,
+		"samples":    samples,
+		"changes":    changes,
+		"complete":   len(samples) == 3,
+	}
+}
+
+func registerChaosRoutes(e *echo.Echo) {
+	// List experiments, newest first
+	e.GET("/api/chaos/experiments", func(c echo.Context) error {
+		chaosExperimentsMutex.Lock()
+		list := []ChaosExperiment{}
+		for _, experiment := range chaosExperiments {
+			list = append(list, *experiment)
+		}
+		chaosExperimentsMutex.Unlock()
+
+		sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.After(list[j].StartedAt) })
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"experiments": list,
+			"count":       len(list),
+		})
+	})
+
+	// Start an experiment
+	e.POST("/api/chaos/experiments", func(c echo.Context) error {
+		var experiment ChaosExperiment
+		if err := c.Bind(&experiment); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid experiment payload",
+			})
+		}
+		if experiment.Namespace == "" {
+			experiment.Namespace = "default"
+		}
+
+		if errors := validateChaosExperiment(experiment); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		chaosExperimentsMutex.Lock()
+		defer chaosExperimentsMutex.Unlock()
+
+		for _, running := range chaosExperiments {
+			if running.Status == ChaosStatusRunning && running.Namespace == experiment.Namespace && running.Service == experiment.Service {
+				return c.JSON(http.StatusConflict, map[string]string{
+					"error": fmt.Sprintf("Experiment %s is already running against %s/%s", running.ID, experiment.Namespace, experiment.Service),
+				})
+			}
+		}
+
+		experiment.ID = fmt.Sprintf("chaos-%s-%d", experiment.Service, time.Now().Unix())
+		experiment.Metrics = []GoldenSignals{collectGoldenSignals(experiment.Namespace, experiment.Service, "before")}
+
+		duration, _ := time.ParseDuration(experiment.Duration)
+		experiment.StartedAt = time.Now()
+		experiment.EndsAt = experiment.StartedAt.Add(duration)
+
+		if err := injectChaosFaults(&experiment); err != nil {
+			log.Printf("Error injecting faults: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to inject faults: %v", err),
+			})
+		}
+
+		experiment.Status = ChaosStatusRunning
+
+		stored := &experiment
+		chaosExperiments[experiment.ID] = stored
+		scheduleChaosEnd(stored)
+
+		return c.JSON(http.StatusCreated, map[string]interface{}{
+			"success":    true,
+			"message":    fmt.Sprintf("Faults injected into %s/%s until %s", experiment.Namespace, experiment.Service, experiment.EndsAt.Format(time.RFC3339)),
+			"experiment": stored,
+		})
+	})
+
+	// Get an experiment
+	e.GET("/api/chaos/experiments/:id", func(c echo.Context) error {
+		chaosExperimentsMutex.Lock()
+		defer chaosExperimentsMutex.Unlock()
+
+		experiment, exists := chaosExperiments[c.Param("id")]
+		if !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Experiment not found",
+			})
+		}
+		return c.JSON(http.StatusOK, experiment)
+	})
+
+	// Before/during/after report for an experiment
+	e.GET("/api/chaos/experiments/:id/report", func(c echo.Context) error {
+		chaosExperimentsMutex.Lock()
+		defer chaosExperimentsMutex.Unlock()
+
+		experiment, exists := chaosExperiments[c.Param("id")]
+		if !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Experiment not found",
+			})
+		}
+		return c.JSON(http.StatusOK, chaosReport(*experiment))
+	})
+
+	// Stop an experiment early and revert its faults
+	e.POST("/api/chaos/experiments/:id/stop", func(c echo.Context) error {
+		chaosExperimentsMutex.Lock()
+		defer chaosExperimentsMutex.Unlock()
+
+		experiment, exists := chaosExperiments[c.Param("id")]
+		if !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Experiment not found",
+			})
+		}
+		if experiment.Status != ChaosStatusRunning {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("Experiment is already %s", experiment.Status),
+			})
+		}
+
+		endChaosExperiment(experiment, ChaosStatusStopped)
+		if experiment.Status == ChaosStatusReverting {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": experiment.Message,
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success":    true,
+			"message":    "Experiment stopped and faults reverted",
+			"experiment": experiment,
+		})
+	})
+}
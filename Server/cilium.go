@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Cilium adapter. The agent runs as the cilium DaemonSet (in kube-system
+// for Helm and cilium install defaults), the operator as a Deployment next
+// to it. Per-node state comes from the cluster-scoped CiliumNode resources
+// and workload endpoints from CiliumEndpoint.
+
+type CiliumComponent struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Type      string `json:"type"`
+	Desired   int32  `json:"desired"`
+	Ready     int32  `json:"ready"`
+	Status    string `json:"status"`
+	Image     string `json:"image,omitempty"`
+}
+
+type CiliumHubbleStatus struct {
+	Enabled      bool             `json:"enabled"`
+	RelayEnabled bool             `json:"relay_enabled"`
+	UIEnabled    bool             `json:"ui_enabled"`
+	Relay        *CiliumComponent `json:"relay,omitempty"`
+}
+
+type CiliumNodeStatus struct {
+	Name             string   `json:"name"`
+	InternalIP       string   `json:"internal_ip"`
+	CiliumInternalIP string   `json:"cilium_internal_ip"`
+	PodCIDRs         []string `json:"pod_cidrs"`
+	NodeReady        bool     `json:"node_ready"`
+	AgentPod         string   `json:"agent_pod"`
+	AgentReady       bool     `json:"agent_ready"`
+	AgentRestarts    int32    `json:"agent_restarts"`
+	Endpoints        int      `json:"endpoints"`
+}
+
+type CiliumEndpointInfo struct {
+	Name             string `json:"name"`
+	Namespace        string `json:"namespace"`
+	ID               int64  `json:"id"`
+	Identity         int64  `json:"identity"`
+	State            string `json:"state"`
+	Node             string `json:"node"`
+	IPv4             string `json:"ipv4,omitempty"`
+	IngressEnforcing bool   `json:"ingress_enforcing"`
+	EgressEnforcing  bool   `json:"egress_enforcing"`
+}
+
+type CiliumEndpointSummary struct {
+	Total          int            `json:"total"`
+	Ready          int            `json:"ready"`
+	NotReady       int            `json:"not_ready"`
+	PolicyEnforced int            `json:"policy_enforced"`
+	ByState        map[string]int `json:"by_state"`
+	ByNamespace    map[string]int `json:"by_namespace"`
+}
+
+type CiliumStatus struct {
+	IsInstalled bool                  `json:"is_installed"`
+	Version     string                `json:"version"`
+	Namespace   string                `json:"namespace"`
+	Healthy     bool                  `json:"healthy"`
+	Agent       CiliumComponent       `json:"agent"`
+	Operator    *CiliumComponent      `json:"operator"`
+	Hubble      CiliumHubbleStatus    `json:"hubble"`
+	Nodes       []CiliumNodeStatus    `json:"nodes"`
+	Endpoints   CiliumEndpointSummary `json:"endpoints"`
+	Issues      []string              `json:"issues"`
+}
+
+type ciliumNodeSpec struct {
+	Addresses []struct {
+		Type string `json:"type"`
+		IP   string `json:"ip"`
+	} `json:"addresses"`
+	IPAM struct {
+		PodCIDRs []string `json:"podCIDRs"`
+	} `json:"ipam"`
+}
+
+type ciliumEndpointStatus struct {
+	ID       int64 `json:"id"`
+	Identity struct {
+		ID int64 `json:"id"`
+	} `json:"identity"`
+	Networking struct {
+		Addressing []struct {
+			IPv4 string `json:"ipv4"`
+		} `json:"addressing"`
+		Node string `json:"node"`
+	} `json:"networking"`
+	State  string `json:"state"`
+	Policy struct {
+		Ingress struct {
+			Enforcing bool `json:"enforcing"`
+		} `json:"ingress"`
+		Egress struct {
+			Enforcing bool `json:"enforcing"`
+		} `json:"egress"`
+	} `json:"policy"`
+}
+
+// Tag of a container image, ignoring any digest and registry port
+func imageTag(image string) string {
+	image = strings.SplitN(image, "@", 2)[0]
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return ""
+}
+
+func componentStatus(desired, ready int32) string {
+	switch {
+	case desired == 0:
+		return "Scaled Down"
+	case ready == desired:
+		return "Running"
+	case ready == 0:
+		return "Not Ready"
+	}
+	return "Partially Ready"
+}
+
+func deploymentComponent(deployment appsv1.Deployment) *CiliumComponent {
+	component := &CiliumComponent{
+		Name:      deployment.Name,
+		Namespace: deployment.Namespace,
+		Type:      "Deployment",
+		Desired:   deployment.Status.Replicas,
+		Ready:     deployment.Status.ReadyReplicas,
+	}
+	if deployment.Spec.Replicas != nil {
+		component.Desired = *deployment.Spec.Replicas
+	}
+	if len(deployment.Spec.Template.Spec.Containers) > 0 {
+		component.Image = deployment.Spec.Template.Spec.Containers[0].Image
+	}
+	component.Status = componentStatus(component.Desired, component.Ready)
+	return component
+}
+
+// The agent DaemonSet, located by its k8s-app=cilium label
+func findCiliumAgent() (*appsv1.DaemonSet, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+	daemonSets, err := clientset.AppsV1().DaemonSets("").List(context.Background(), metav1.ListOptions{LabelSelector: "k8s-app=cilium"})
+	if err != nil {
+		return nil, err
+	}
+	for _, ds := range daemonSets.Items {
+		if ds.Name == "cilium" {
+			return &ds, nil
+		}
+	}
+	if len(daemonSets.Items) > 0 {
+		return &daemonSets.Items[0], nil
+	}
+	return nil, nil
+}
+
+func getCiliumEndpoints(namespace string) ([]CiliumEndpointInfo, error) {
+	items, err := kubectlListResources("ciliumendpoints.cilium.io", namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := []CiliumEndpointInfo{}
+	for _, item := range items {
+		var status ciliumEndpointStatus
+		if len(item.Status) > 0 {
+			if err := json.Unmarshal(item.Status, &status); err != nil {
+				log.Printf("Skipping CiliumEndpoint %s/%s: %v", item.Metadata.Namespace, item.Metadata.Name, err)
+				continue
+			}
+		}
+		endpoint := CiliumEndpointInfo{
+			Name:             item.Metadata.Name,
+			Namespace:        item.Metadata.Namespace,
+			ID:               status.ID,
+			Identity:         status.Identity.ID,
+			State:            status.State,
+			Node:             status.Networking.Node,
+			IngressEnforcing: status.Policy.Ingress.Enforcing,
+			EgressEnforcing:  status.Policy.Egress.Enforcing,
+		}
+		if len(status.Networking.Addressing) > 0 {
+			endpoint.IPv4 = status.Networking.Addressing[0].IPv4
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Namespace != endpoints[j].Namespace {
+			return endpoints[i].Namespace < endpoints[j].Namespace
+		}
+		return endpoints[i].Name < endpoints[j].Name
+	})
+	return endpoints, nil
+}
+
+func summarizeCiliumEndpoints(endpoints []CiliumEndpointInfo) CiliumEndpointSummary {
+	summary := CiliumEndpointSummary{
+		ByState:     make(map[string]int),
+		ByNamespace: make(map[string]int),
+	}
+	for _, endpoint := range endpoints {
+		summary.Total++
+		if endpoint.State == "ready" {
+			summary.Ready++
+		} else {
+			summary.NotReady++
+		}
+		if endpoint.IngressEnforcing || endpoint.EgressEnforcing {
+			summary.PolicyEnforced++
+		}
+		state := endpoint.State
+		if state == "" {
+			state = "unknown"
+		}
+		summary.ByState[state]++
+		summary.ByNamespace[endpoint.Namespace]++
+	}
+	return summary
+}
+
+// Per-node status from CiliumNode, joined with the Kubernetes node
+// condition, the agent pod scheduled there and the endpoints it hosts.
+// Endpoints report their node by IP, so they are matched on the node's
+// addresses.
+func getCiliumNodes(agentNamespace string, endpoints []CiliumEndpointInfo) ([]CiliumNodeStatus, error) {
+	items, err := kubectlListResources("ciliumnodes.cilium.io", "")
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	nodeReady := make(map[string]bool)
+	for _, node := range nodes.Items {
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady {
+				nodeReady[node.Name] = condition.Status == corev1.ConditionTrue
+			}
+		}
+	}
+
+	agents, err := clientset.CoreV1().Pods(agentNamespace).List(context.Background(), metav1.ListOptions{LabelSelector: "k8s-app=cilium"})
+	if err != nil {
+		return nil, err
+	}
+	agentByNode := make(map[string]corev1.Pod)
+	for _, pod := range agents.Items {
+		agentByNode[pod.Spec.NodeName] = pod
+	}
+
+	endpointsByIP := make(map[string]int)
+	for _, endpoint := range endpoints {
+		endpointsByIP[endpoint.Node]++
+	}
+
+	result := []CiliumNodeStatus{}
+	for _, item := range items {
+		var spec ciliumNodeSpec
+		if len(item.Spec) > 0 {
+			if err := json.Unmarshal(item.Spec, &spec); err != nil {
+				log.Printf("Skipping CiliumNode %s: %v", item.Metadata.Name, err)
+				continue
+			}
+		}
+
+		status := CiliumNodeStatus{
+			Name:      item.Metadata.Name,
+			PodCIDRs:  spec.IPAM.PodCIDRs,
+			NodeReady: nodeReady[item.Metadata.Name],
+		}
+		if status.PodCIDRs == nil {
+			status.PodCIDRs = []string{}
+		}
+		for _, address := range spec.Addresses {
+			switch address.Type {
+			case "InternalIP":
+				status.InternalIP = address.IP
+			case "CiliumInternalIP":
+				status.CiliumInternalIP = address.IP
+			}
+			status.Endpoints += endpointsByIP[address.IP]
+		}
+
+		if pod, ok := agentByNode[item.Metadata.Name]; ok {
+			status.AgentPod = pod.Name
+			for _, container := range pod.Status.ContainerStatuses {
+				if container.Name == "cilium-agent" {
+					status.AgentReady = container.Ready
+					status.AgentRestarts = container.RestartCount
+				}
+			}
+		}
+
+		result = append(result, status)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func getCiliumStatus() (*CiliumStatus, error) {
+	agent, err := findCiliumAgent()
+	if err != nil {
+		return nil, err
+	}
+	if agent == nil {
+		return &CiliumStatus{IsInstalled: false}, nil
+	}
+
+	status := &CiliumStatus{
+		IsInstalled: true,
+		Namespace:   agent.Namespace,
+		Agent: CiliumComponent{
+			Name:      agent.Name,
+			Namespace: agent.Namespace,
+			Type:      "DaemonSet",
+			Desired:   agent.Status.DesiredNumberScheduled,
+			Ready:     agent.Status.NumberReady,
+		},
+		Nodes:  []CiliumNodeStatus{},
+		Issues: []string{},
+	}
+	status.Agent.Status = componentStatus(status.Agent.Desired, status.Agent.Ready)
+	if len(agent.Spec.Template.Spec.Containers) > 0 {
+		status.Agent.Image = agent.Spec.Template.Spec.Containers[0].Image
+		status.Version = imageTag(status.Agent.Image)
+	}
+	if status.Version == "" {
+		status.Version = "Unknown"
+	}
+	if status.Agent.Ready < status.Agent.Desired {
+		status.Issues = append(status.Issues, fmt.Sprintf("%d of %d Cilium agents are not ready", status.Agent.Desired-status.Agent.Ready, status.Agent.Desired))
+	}
+
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+	deployments, err := clientset.AppsV1().Deployments(agent.Namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments.Items {
+		switch deployment.Name {
+		case "cilium-operator":
+			status.Operator = deploymentComponent(deployment)
+		case "hubble-relay":
+			status.Hubble.RelayEnabled = true
+			status.Hubble.Relay = deploymentComponent(deployment)
+		case "hubble-ui":
+			status.Hubble.UIEnabled = true
+		}
+	}
+	if status.Operator == nil {
+		status.Issues = append(status.Issues, "Cilium operator deployment not found")
+	} else if status.Operator.Ready == 0 {
+		status.Issues = append(status.Issues, "Cilium operator has no ready replicas")
+	}
+	if status.Hubble.Relay != nil && status.Hubble.Relay.Ready == 0 {
+		status.Issues = append(status.Issues, "Hubble Relay is deployed but not ready")
+	}
+
+	config, err := clientset.CoreV1().ConfigMaps(agent.Namespace).Get(context.Background(), "cilium-config", metav1.GetOptions{})
+	if err != nil {
+		log.Printf("Warning: Could not read cilium-config: %v", err)
+	} else {
+		status.Hubble.Enabled = config.Data["enable-hubble"] == "true"
+	}
+
+	endpoints, err := getCiliumEndpoints("")
+	if err != nil {
+		log.Printf("Warning: Could not list CiliumEndpoints: %v", err)
+		endpoints = []CiliumEndpointInfo{}
+	}
+	status.Endpoints = summarizeCiliumEndpoints(endpoints)
+
+	nodes, err := getCiliumNodes(agent.Namespace, endpoints)
+	if err != nil {
+		log.Printf("Warning: Could not list CiliumNodes: %v", err)
+	} else {
+		status.Nodes = nodes
+	}
+	for _, node := range status.Nodes {
+		if node.AgentPod == "" {
+			status.Issues = append(status.Issues, fmt.Sprintf("node %s has no Cilium agent", node.Name))
+		} else if !node.AgentReady {
+			status.Issues = append(status.Issues, fmt.Sprintf("Cilium agent %s on node %s is not ready", node.AgentPod, node.Name))
+		}
+	}
+
+	status.Healthy = len(status.Issues) == 0
+	return status, nil
+}
+
+func registerCiliumRoutes(e *echo.Echo) {
+	// Agent, operator, Hubble, node and endpoint status
+	e.GET("/api/cilium/status", func(c echo.Context) error {
+		status, err := getCiliumStatus()
+		if err != nil {
+			log.Printf("Error checking Cilium installation: %v", err)
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"is_installed": false,
+				"error":        err.Error(),
+			})
+		}
+		if !status.IsInstalled {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"is_installed": false,
+				"message":      "Cilium is not installed",
+			})
+		}
+		return c.JSON(http.StatusOK, status)
+	})
+
+	// Per-node status from CiliumNode
+	e.GET("/api/cilium/nodes", func(c echo.Context) error {
+		agent, err := findCiliumAgent()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to find Cilium agent: %v", err),
+			})
+		}
+		if agent == nil {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Cilium is not installed",
+			})
+		}
+
+		endpoints, err := getCiliumEndpoints("")
+		if err != nil {
+			log.Printf("Warning: Could not list CiliumEndpoints: %v", err)
+			endpoints = []CiliumEndpointInfo{}
+		}
+		nodes, err := getCiliumNodes(agent.Namespace, endpoints)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list CiliumNodes: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"nodes": nodes,
+			"count": len(nodes),
+		})
+	})
+
+	// Workload endpoints, optionally in one namespace
+	e.GET("/api/cilium/endpoints", func(c echo.Context) error {
+		endpoints, err := getCiliumEndpoints(c.QueryParam("namespace"))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list CiliumEndpoints: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"endpoints": endpoints,
+			"summary":   summarizeCiliumEndpoints(endpoints),
+			"count":     len(endpoints),
+		})
+	})
+}
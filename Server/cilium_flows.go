@@ -0,0 +1,637 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// Hubble flow observation. Flows come from Hubble Relay through hubble
+// observe, which speaks the Relay gRPC API. The relay is found through its
+// Service: in-cluster Meshify dials the Service directly, otherwise a
+// port-forward to a relay pod is opened for the duration of the request and
+// passed to the CLI with --server. Each line of --output jsonpb is one
+// GetFlowsResponse.
+
+const (
+	defaultFlowLimit = 100
+	maxFlowLimit     = 5000
+
+	hubbleRelayService = "hubble-relay"
+	hubbleRelayTimeout = 5 * time.Second
+)
+
+// errHubbleRelayUnavailable marks failures to reach the relay, as opposed to
+// failures of the observe itself
+type errHubbleRelayUnavailable struct {
+	reason string
+}
+
+func (e errHubbleRelayUnavailable) Error() string {
+	return "Hubble Relay is not reachable: " + e.reason
+}
+
+var hubbleVerdicts = map[string]bool{
+	"FORWARDED": true, "DROPPED": true, "ERROR": true, "AUDIT": true,
+	"REDIRECTED": true, "TRACED": true, "TRANSLATED": true,
+}
+
+var hubbleL7Protocols = map[string]bool{
+	"http": true, "dns": true, "kafka": true,
+}
+
+type FlowEndpoint struct {
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	Workload  string `json:"workload,omitempty"`
+	Identity  int64  `json:"identity"`
+	IP        string `json:"ip,omitempty"`
+	Port      int    `json:"port,omitempty"`
+}
+
+type FlowL7 struct {
+	Protocol  string  `json:"protocol"`
+	Type      string  `json:"type"`
+	LatencyMS float64 `json:"latency_ms,omitempty"`
+	Method    string  `json:"method,omitempty"`
+	URL       string  `json:"url,omitempty"`
+	Status    int     `json:"status,omitempty"`
+	Query     string  `json:"query,omitempty"`
+	RCode     int     `json:"rcode,omitempty"`
+	Topic     string  `json:"topic,omitempty"`
+	ErrorCode int     `json:"error_code,omitempty"`
+}
+
+type Flow struct {
+	Time        time.Time    `json:"time"`
+	Node        string       `json:"node"`
+	Verdict     string       `json:"verdict"`
+	DropReason  string       `json:"drop_reason,omitempty"`
+	Direction   string       `json:"direction"`
+	Type        string       `json:"type"`
+	Protocol    string       `json:"protocol"`
+	Source      FlowEndpoint `json:"source"`
+	Destination FlowEndpoint `json:"destination"`
+	L7          *FlowL7      `json:"l7,omitempty"`
+	Summary     string       `json:"summary"`
+}
+
+type DropReasonCount struct {
+	Reason          string `json:"reason"`
+	Count           int    `json:"count"`
+	LastSource      string `json:"last_source"`
+	LastDestination string `json:"last_destination"`
+}
+
+type FlowFilters struct {
+	Namespace string
+	Pod       string
+	Verdict   string
+	Protocol  string
+	Limit     int
+	Follow    bool
+}
+
+type hubbleEndpoint struct {
+	Identity  int64  `json:"identity"`
+	Namespace string `json:"namespace"`
+	PodName   string `json:"pod_name"`
+	Workloads []struct {
+		Name string `json:"name"`
+		Kind string `json:"kind"`
+	} `json:"workloads"`
+}
+
+type hubblePorts struct {
+	SourcePort      int `json:"source_port"`
+	DestinationPort int `json:"destination_port"`
+}
+
+type hubbleFlow struct {
+	Time           time.Time      `json:"time"`
+	Verdict        string         `json:"verdict"`
+	DropReasonDesc string         `json:"drop_reason_desc"`
+	NodeName       string         `json:"node_name"`
+	Type           string         `json:"Type"`
+	Direction      string         `json:"traffic_direction"`
+	Summary        string         `json:"Summary"`
+	Source         hubbleEndpoint `json:"source"`
+	Destination    hubbleEndpoint `json:"destination"`
+	IP             struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+	} `json:"IP"`
+	L4 struct {
+		TCP    *hubblePorts `json:"TCP"`
+		UDP    *hubblePorts `json:"UDP"`
+		ICMPv4 *struct{}    `json:"ICMPv4"`
+		ICMPv6 *struct{}    `json:"ICMPv6"`
+		SCTP   *hubblePorts `json:"SCTP"`
+	} `json:"l4"`
+	L7 *struct {
+		Type      string `json:"type"`
+		LatencyNS string `json:"latency_ns"`
+		HTTP      *struct {
+			Code   int    `json:"code"`
+			Method string `json:"method"`
+			URL    string `json:"url"`
+		} `json:"http"`
+		DNS *struct {
+			Query string `json:"query"`
+			RCode int    `json:"rcode"`
+		} `json:"dns"`
+		Kafka *struct {
+			Topic     string `json:"topic"`
+			ErrorCode int    `json:"error_code"`
+		} `json:"kafka"`
+	} `json:"l7"`
+}
+
+func flowEndpoint(endpoint hubbleEndpoint, ip string, port int) FlowEndpoint {
+	result := FlowEndpoint{
+		Namespace: endpoint.Namespace,
+		Pod:       endpoint.PodName,
+		Identity:  endpoint.Identity,
+		IP:        ip,
+		Port:      port,
+	}
+	if len(endpoint.Workloads) > 0 {
+		result.Workload = strings.ToLower(endpoint.Workloads[0].Kind) + "/" + endpoint.Workloads[0].Name
+	}
+	return result
+}
+
+func (e FlowEndpoint) String() string {
+	if e.Pod != "" {
+		return e.Namespace + "/" + e.Pod
+	}
+	if e.Port > 0 {
+		return fmt.Sprintf("%s:%d", e.IP, e.Port)
+	}
+	return e.IP
+}
+
+func convertHubbleFlow(raw hubbleFlow) Flow {
+	flow := Flow{
+		Time:       raw.Time,
+		Node:       raw.NodeName,
+		Verdict:    raw.Verdict,
+		DropReason: raw.DropReasonDesc,
+		Direction:  strings.ToLower(raw.Direction),
+		Type:       raw.Type,
+		Summary:    raw.Summary,
+	}
+
+	var ports *hubblePorts
+	switch {
+	case raw.L4.TCP != nil:
+		flow.Protocol, ports = "tcp", raw.L4.TCP
+	case raw.L4.UDP != nil:
+		flow.Protocol, ports = "udp", raw.L4.UDP
+	case raw.L4.SCTP != nil:
+		flow.Protocol, ports = "sctp", raw.L4.SCTP
+	case raw.L4.ICMPv4 != nil, raw.L4.ICMPv6 != nil:
+		flow.Protocol = "icmp"
+	}
+	if ports == nil {
+		ports = &hubblePorts{}
+	}
+	flow.Source = flowEndpoint(raw.Source, raw.IP.Source, ports.SourcePort)
+	flow.Destination = flowEndpoint(raw.Destination, raw.IP.Destination, ports.DestinationPort)
+
+	if raw.L7 != nil {
+		l7 := &FlowL7{Type: strings.ToLower(raw.L7.Type)}
+		// int64 fields are strings in protojson
+		if ns, err := strconv.ParseInt(raw.L7.LatencyNS, 10, 64); err == nil {
+			l7.LatencyMS = float64(ns) / 1e6
+		}
+		switch {
+		case raw.L7.HTTP != nil:
+			l7.Protocol = "http"
+			l7.Method, l7.URL, l7.Status = raw.L7.HTTP.Method, raw.L7.HTTP.URL, raw.L7.HTTP.Code
+		case raw.L7.DNS != nil:
+			l7.Protocol = "dns"
+			l7.Query, l7.RCode = raw.L7.DNS.Query, raw.L7.DNS.RCode
+		case raw.L7.Kafka != nil:
+			l7.Protocol = "kafka"
+			l7.Topic, l7.ErrorCode = raw.L7.Kafka.Topic, raw.L7.Kafka.ErrorCode
+		}
+		flow.L7 = l7
+		flow.Protocol = l7.Protocol
+	}
+
+	return flow
+}
+
+func parseFlowFilters(c echo.Context) (FlowFilters, []string) {
+	filters := FlowFilters{
+		Namespace: c.QueryParam("namespace"),
+		Pod:       c.QueryParam("pod"),
+		Verdict:   strings.ToUpper(c.QueryParam("verdict")),
+		Protocol:  strings.ToLower(c.QueryParam("protocol")),
+		Limit:     defaultFlowLimit,
+		Follow:    c.QueryParam("follow") == "true",
+	}
+
+	var errors []string
+	if filters.Verdict != "" && !hubbleVerdicts[filters.Verdict] {
+		errors = append(errors, fmt.Sprintf("unsupported verdict %q", filters.Verdict))
+	}
+	if filters.Protocol != "" && !hubbleL7Protocols[filters.Protocol] {
+		errors = append(errors, fmt.Sprintf("unsupported L7 protocol %q (use http, dns or kafka)", filters.Protocol))
+	}
+	if strings.ContainsAny(filters.Namespace+filters.Pod, " \n") {
+		errors = append(errors, "namespace and pod must not contain whitespace")
+	}
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxFlowLimit {
+			errors = append(errors, fmt.Sprintf("limit must be between 1 and %d", maxFlowLimit))
+		} else {
+			filters.Limit = limit
+		}
+	}
+
+	return filters, errors
+}
+
+// Find the hubble-relay Service and return an address for it, along with a
+// function that releases the port-forward if one was opened
+func connectHubbleRelay(ctx context.Context) (string, func(), error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return "", nil, err
+	}
+
+	services, err := clientset.CoreV1().Services("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", hubbleRelayService).String(),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if len(services.Items) == 0 || len(services.Items[0].Spec.Ports) == 0 {
+		return "", nil, errHubbleRelayUnavailable{"no hubble-relay Service found; enable Hubble Relay (cilium hubble enable)"}
+	}
+	service := services.Items[0]
+	servicePort := service.Spec.Ports[0]
+
+	// In the cluster the Service is directly reachable
+	if _, err := rest.InClusterConfig(); err == nil {
+		address := fmt.Sprintf("%s.%s.svc:%d", service.Name, service.Namespace, servicePort.Port)
+		if err := probeHubbleRelay(address); err != nil {
+			return "", nil, err
+		}
+		return address, func() {}, nil
+	}
+
+	pods, err := clientset.CoreV1().Pods(service.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(service.Spec.Selector).String(),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	var relayPod *corev1.Pod
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == corev1.PodRunning && podReady(pods.Items[i]) {
+			relayPod = &pods.Items[i]
+			break
+		}
+	}
+	if relayPod == nil {
+		return "", nil, errHubbleRelayUnavailable{fmt.Sprintf("no ready hubble-relay pod in %s", service.Namespace)}
+	}
+
+	targetPort := servicePort.TargetPort.IntValue()
+	if servicePort.TargetPort.Type == intstr.String {
+		for _, container := range relayPod.Spec.Containers {
+			for _, port := range container.Ports {
+				if port.Name == servicePort.TargetPort.StrVal {
+					targetPort = int(port.ContainerPort)
+				}
+			}
+		}
+	}
+	if targetPort == 0 {
+		targetPort = int(servicePort.Port)
+	}
+
+	address, stop, err := portForwardPod(relayPod.Namespace, relayPod.Name, targetPort)
+	if err != nil {
+		return "", nil, errHubbleRelayUnavailable{fmt.Sprintf("port-forward to %s/%s failed: %v", relayPod.Namespace, relayPod.Name, err)}
+	}
+	if err := probeHubbleRelay(address); err != nil {
+		stop()
+		return "", nil, err
+	}
+	return address, stop, nil
+}
+
+func podReady(pod corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func probeHubbleRelay(address string) error {
+	conn, err := net.DialTimeout("tcp", address, hubbleRelayTimeout)
+	if err != nil {
+		return errHubbleRelayUnavailable{err.Error()}
+	}
+	conn.Close()
+	return nil
+}
+
+// Forward a local port to a pod port, returning the local address and a
+// function that closes the forward
+func portForwardPod(namespace, pod string, port int) (string, func(), error) {
+	config, err := getKubeConfig()
+	if err != nil {
+		return "", nil, err
+	}
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return "", nil, err
+	}
+	transport, upgrader, err := spdy.RoundTripperFor(config)
+	if err != nil {
+		return "", nil, err
+	}
+
+	url := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(namespace).Name(pod).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stopCh, readyCh := make(chan struct{}), make(chan struct{})
+	var errOut bytes.Buffer
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", port)}, stopCh, readyCh, io.Discard, &errOut)
+	if err != nil {
+		return "", nil, err
+	}
+
+	failed := make(chan error, 1)
+	go func() { failed <- forwarder.ForwardPorts() }()
+
+	select {
+	case <-readyCh:
+	case err := <-failed:
+		if message := strings.TrimSpace(errOut.String()); message != "" {
+			return "", nil, fmt.Errorf("%v: %s", err, message)
+		}
+		return "", nil, err
+	case <-time.After(hubbleRelayTimeout):
+		close(stopCh)
+		return "", nil, fmt.Errorf("timed out waiting for the port-forward")
+	}
+
+	ports, err := forwarder.GetPorts()
+	if err != nil || len(ports) == 0 {
+		close(stopCh)
+		return "", nil, fmt.Errorf("port-forward has no local port")
+	}
+	return fmt.Sprintf("127.0.0.1:%d", ports[0].Local), func() { close(stopCh) }, nil
+}
+
+func hubbleObserveArgs(filters FlowFilters, server string) []string {
+	args := []string{"observe", "--server", server, "--output", "jsonpb"}
+	if filters.Follow {
+		args = append(args, "--follow")
+	} else {
+		args = append(args, "--last", strconv.Itoa(filters.Limit))
+	}
+
+	// A pod given as namespace/pod carries its own namespace
+	switch {
+	case filters.Pod != "" && strings.Contains(filters.Pod, "/"):
+		args = append(args, "--pod", filters.Pod)
+	case filters.Pod != "" && filters.Namespace != "":
+		args = append(args, "--pod", filters.Namespace+"/"+filters.Pod)
+	case filters.Pod != "":
+		args = append(args, "--pod", filters.Pod)
+	case filters.Namespace != "":
+		args = append(args, "--namespace", filters.Namespace)
+	}
+
+	if filters.Verdict != "" {
+		args = append(args, "--verdict", filters.Verdict)
+	}
+	if filters.Protocol != "" {
+		args = append(args, "--type", "l7", "--protocol", filters.Protocol)
+	}
+	return args
+}
+
+func parseHubbleLine(line []byte) (*Flow, error) {
+	var response struct {
+		Flow *hubbleFlow `json:"flow"`
+	}
+	if err := json.Unmarshal(line, &response); err != nil {
+		return nil, err
+	}
+	// Node status and lost-event records carry no flow
+	if response.Flow == nil {
+		return nil, nil
+	}
+	flow := convertHubbleFlow(*response.Flow)
+	return &flow, nil
+}
+
+// Drop reason counts, most frequent first
+type dropAggregator map[string]*DropReasonCount
+
+func (d dropAggregator) add(flow Flow) {
+	if flow.Verdict != "DROPPED" {
+		return
+	}
+	reason := flow.DropReason
+	if reason == "" {
+		reason = "UNKNOWN"
+	}
+	entry := d[reason]
+	if entry == nil {
+		entry = &DropReasonCount{Reason: reason}
+		d[reason] = entry
+	}
+	entry.Count++
+	entry.LastSource = flow.Source.String()
+	entry.LastDestination = flow.Destination.String()
+}
+
+func (d dropAggregator) list() []DropReasonCount {
+	reasons := []DropReasonCount{}
+	for _, entry := range d {
+		reasons = append(reasons, *entry)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if reasons[i].Count != reasons[j].Count {
+			return reasons[i].Count > reasons[j].Count
+		}
+		return reasons[i].Reason < reasons[j].Reason
+	})
+	return reasons
+}
+
+func runHubbleObserve(filters FlowFilters, server string) ([]Flow, []DropReasonCount, error) {
+	cmd := exec.Command("hubble", hubbleObserveArgs(filters, server)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("hubble observe failed: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	flows := []Flow{}
+	drops := dropAggregator{}
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		flow, err := parseHubbleLine(line)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse hubble output: %v", err)
+		}
+		if flow == nil {
+			continue
+		}
+		flows = append(flows, *flow)
+		drops.add(*flow)
+	}
+	return flows, drops.list(), nil
+}
+
+func registerCiliumFlowRoutes(e *echo.Echo) {
+	// Recent flows with aggregated drop reasons. With follow=true flows are
+	// streamed as server-sent "flow" events, each followed by the updated
+	// "drops" aggregate when it is a drop; the stream ends with an "end"
+	// event after limit flows.
+	e.GET("/api/cilium/flows", func(c echo.Context) error {
+		filters, errors := parseFlowFilters(c)
+		if len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+		if _, err := exec.LookPath("hubble"); err != nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{
+				"error": "Hubble CLI not found",
+			})
+		}
+
+		server, release, err := connectHubbleRelay(c.Request().Context())
+		if err != nil {
+			if _, ok := err.(errHubbleRelayUnavailable); ok {
+				return c.JSON(http.StatusServiceUnavailable, map[string]string{
+					"error": err.Error(),
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to locate Hubble Relay: %v", err),
+			})
+		}
+		defer release()
+
+		if !filters.Follow {
+			flows, drops, err := runHubbleObserve(filters, server)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": err.Error(),
+				})
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"flows":        flows,
+				"drop_reasons": drops,
+				"count":        len(flows),
+			})
+		}
+
+		ctx, cancel := context.WithCancel(c.Request().Context())
+		defer cancel()
+
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "hubble", hubbleObserveArgs(filters, server)...)
+		cmd.Stderr = &stderr
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		if err := cmd.Start(); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("failed to start hubble observe: %v", err),
+			})
+		}
+
+		w := c.Response()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		w.Flush()
+
+		drops := dropAggregator{}
+		sent := 0
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sent < filters.Limit && scanner.Scan() {
+			flow, err := parseHubbleLine(scanner.Bytes())
+			if err != nil {
+				log.Printf("Skipping unparseable hubble flow: %v", err)
+				continue
+			}
+			if flow == nil {
+				continue
+			}
+			writeSSE(w, "flow", flow)
+			sent++
+			if flow.Verdict == "DROPPED" {
+				drops.add(*flow)
+				writeSSE(w, "drops", drops.list())
+			}
+		}
+
+		// Stop observing once the limit is reached or the stream broke
+		cancel()
+		cmd.Wait()
+		reason := "limit reached"
+		if sent < filters.Limit {
+			reason = "observe stopped"
+			if message := strings.TrimSpace(stderr.String()); message != "" {
+				reason = message
+			}
+		}
+		if c.Request().Context().Err() == nil {
+			writeSSE(w, "end", map[string]interface{}{
+				"flows":        sent,
+				"drop_reasons": drops.list(),
+				"reason":       reason,
+			})
+		}
+		return nil
+	})
+}
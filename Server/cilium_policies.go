@@ -0,0 +1,1355 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Kubernetes NetworkPolicy and CiliumNetworkPolicy management with a
+// reachability evaluator. Cilium merges both kinds into one policy set per
+// endpoint: once a pod is selected by a policy for a direction, traffic in
+// that direction is denied unless some rule allows it, and Cilium deny rules
+// win over any allow.
+
+const (
+	kindNetworkPolicy                  = "NetworkPolicy"
+	kindCiliumNetworkPolicy            = "CiliumNetworkPolicy"
+	kindCiliumClusterwideNetworkPolicy = "CiliumClusterwideNetworkPolicy"
+
+	ciliumNamespaceLabel      = "io.kubernetes.pod.namespace"
+	ciliumServiceAccountLabel = "io.cilium.k8s.policy.serviceaccount"
+	ciliumNamespaceLabels     = "io.cilium.k8s.namespace.labels."
+)
+
+type KubeNetworkPolicy struct {
+	Name      string                         `json:"name"`
+	Namespace string                         `json:"namespace"`
+	Spec      networkingv1.NetworkPolicySpec `json:"spec"`
+}
+
+type CiliumCIDRRule struct {
+	CIDR   string   `json:"cidr"`
+	Except []string `json:"except,omitempty"`
+}
+
+type CiliumPortProtocol struct {
+	Port     string `json:"port"`
+	EndPort  int32  `json:"endPort,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+type CiliumPortRule struct {
+	Ports []CiliumPortProtocol   `json:"ports,omitempty"`
+	Rules map[string]interface{} `json:"rules,omitempty"`
+}
+
+type CiliumICMPField struct {
+	Type   interface{} `json:"type"`
+	Family string      `json:"family,omitempty"`
+}
+
+type CiliumICMPRule struct {
+	Fields []CiliumICMPField `json:"fields,omitempty"`
+}
+
+// One ingress or egress rule; the from* fields are used for ingress and the
+// to* fields for egress
+type CiliumPolicyRule struct {
+	FromEndpoints []metav1.LabelSelector   `json:"fromEndpoints,omitempty"`
+	FromRequires  []metav1.LabelSelector   `json:"fromRequires,omitempty"`
+	FromEntities  []string                 `json:"fromEntities,omitempty"`
+	FromCIDR      []string                 `json:"fromCIDR,omitempty"`
+	FromCIDRSet   []CiliumCIDRRule         `json:"fromCIDRSet,omitempty"`
+	FromNodes     []metav1.LabelSelector   `json:"fromNodes,omitempty"`
+	FromGroups    []map[string]interface{} `json:"fromGroups,omitempty"`
+	ToEndpoints   []metav1.LabelSelector   `json:"toEndpoints,omitempty"`
+	ToRequires    []metav1.LabelSelector   `json:"toRequires,omitempty"`
+	ToEntities    []string                 `json:"toEntities,omitempty"`
+	ToCIDR        []string                 `json:"toCIDR,omitempty"`
+	ToCIDRSet     []CiliumCIDRRule         `json:"toCIDRSet,omitempty"`
+	ToNodes       []metav1.LabelSelector   `json:"toNodes,omitempty"`
+	ToGroups      []map[string]interface{} `json:"toGroups,omitempty"`
+	ToServices    []map[string]interface{} `json:"toServices,omitempty"`
+	ToFQDNs       []map[string]string      `json:"toFQDNs,omitempty"`
+	ToPorts       []CiliumPortRule         `json:"toPorts,omitempty"`
+	ICMPs         []CiliumICMPRule         `json:"icmps,omitempty"`
+}
+
+type CiliumDefaultDeny struct {
+	Ingress *bool `json:"ingress,omitempty"`
+	Egress  *bool `json:"egress,omitempty"`
+}
+
+type CiliumPolicySpec struct {
+	Description       string                `json:"description,omitempty"`
+	EndpointSelector  metav1.LabelSelector  `json:"endpointSelector"`
+	NodeSelector      *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	Ingress           []CiliumPolicyRule    `json:"ingress,omitempty"`
+	IngressDeny       []CiliumPolicyRule    `json:"ingressDeny,omitempty"`
+	Egress            []CiliumPolicyRule    `json:"egress,omitempty"`
+	EgressDeny        []CiliumPolicyRule    `json:"egressDeny,omitempty"`
+	EnableDefaultDeny *CiliumDefaultDeny    `json:"enableDefaultDeny,omitempty"`
+}
+
+// A CiliumNetworkPolicy, or a CiliumClusterwideNetworkPolicy when the
+// namespace is empty. Cilium applies spec and each entry of specs as
+// independent rule sets.
+type CiliumNetworkPolicy struct {
+	Name      string             `json:"name"`
+	Namespace string             `json:"namespace"`
+	Spec      CiliumPolicySpec   `json:"spec"`
+	Specs     []CiliumPolicySpec `json:"specs,omitempty"`
+}
+
+func (p CiliumNetworkPolicy) kind() string {
+	if p.Namespace == "" {
+		return kindCiliumClusterwideNetworkPolicy
+	}
+	return kindCiliumNetworkPolicy
+}
+
+func (p CiliumNetworkPolicy) ruleSets() []CiliumPolicySpec {
+	return append([]CiliumPolicySpec{p.Spec}, p.Specs...)
+}
+
+type NetworkPolicyInfo struct {
+	Kind         string      `json:"kind"`
+	Name         string      `json:"name"`
+	Namespace    string      `json:"namespace"`
+	PolicyTypes  []string    `json:"policy_types"`
+	IngressRules int         `json:"ingress_rules"`
+	EgressRules  int         `json:"egress_rules"`
+	DenyRules    int         `json:"deny_rules"`
+	SelectedPods []string    `json:"selected_pods"`
+	Spec         interface{} `json:"spec"`
+}
+
+type PolicyPodRef struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+}
+
+type PolicySimulationRequest struct {
+	Source                PolicyPodRef          `json:"source"`
+	Destination           PolicyPodRef          `json:"destination"`
+	Port                  int32                 `json:"port"`
+	Protocol              string                `json:"protocol"`
+	NetworkPolicies       []KubeNetworkPolicy   `json:"network_policies,omitempty"`
+	CiliumNetworkPolicies []CiliumNetworkPolicy `json:"cilium_network_policies,omitempty"`
+}
+
+// Verdict for one direction. Indeterminate is set when the outcome depends
+// on a rule the evaluator cannot resolve to pods; Allowed is then false and
+// the notes name the rules involved.
+type DirectionVerdict struct {
+	Enforced          bool     `json:"enforced"`
+	Allowed           bool     `json:"allowed"`
+	Indeterminate     bool     `json:"indeterminate"`
+	SelectingPolicies []string `json:"selecting_policies"`
+	AllowedBy         []string `json:"allowed_by"`
+	DeniedBy          []string `json:"denied_by"`
+	L7Rules           bool     `json:"l7_rules"`
+	Reason            string   `json:"reason"`
+	Notes             []string `json:"notes"`
+}
+
+type PolicySimulationResult struct {
+	Allowed       bool             `json:"allowed"`
+	Indeterminate bool             `json:"indeterminate"`
+	Reason        string           `json:"reason"`
+	Source        PolicyPodRef     `json:"source"`
+	SourceIP      string           `json:"source_ip"`
+	Destination   PolicyPodRef     `json:"destination"`
+	DestIP        string           `json:"destination_ip"`
+	Port          int32            `json:"port"`
+	Protocol      string           `json:"protocol"`
+	Egress        DirectionVerdict `json:"egress"`
+	Ingress       DirectionVerdict `json:"ingress"`
+}
+
+// Pod as seen by the evaluator
+type policyPod struct {
+	Namespace       string
+	Name            string
+	IP              string
+	ServiceAccount  string
+	Labels          map[string]string
+	NamespaceLabels map[string]string
+	NamedPorts      map[string]int32
+}
+
+type policySet struct {
+	kube   []KubeNetworkPolicy
+	cilium []CiliumNetworkPolicy
+}
+
+func policyRef(kind, namespace, name string) string {
+	if namespace == "" {
+		return fmt.Sprintf("%s %s", kind, name)
+	}
+	return fmt.Sprintf("%s %s/%s", kind, namespace, name)
+}
+
+func newPolicyPod(pod corev1.Pod, namespaceLabels map[string]string) policyPod {
+	result := policyPod{
+		Namespace:       pod.Namespace,
+		Name:            pod.Name,
+		IP:              pod.Status.PodIP,
+		ServiceAccount:  pod.Spec.ServiceAccountName,
+		Labels:          pod.Labels,
+		NamespaceLabels: namespaceLabels,
+		NamedPorts:      make(map[string]int32),
+	}
+	if result.NamespaceLabels == nil {
+		result.NamespaceLabels = map[string]string{}
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name != "" {
+				result.NamedPorts[port.Name] = port.ContainerPort
+			}
+		}
+	}
+	return result
+}
+
+// Labels Cilium derives for an endpoint: pod labels plus its namespace,
+// service account and namespace labels
+func (p policyPod) ciliumLabels() labels.Set {
+	set := labels.Set{}
+	for key, value := range p.Labels {
+		set[key] = value
+	}
+	for key, value := range p.NamespaceLabels {
+		set[ciliumNamespaceLabels+key] = value
+	}
+	set[ciliumNamespaceLabel] = p.Namespace
+	if p.ServiceAccount != "" {
+		set[ciliumServiceAccountLabel] = p.ServiceAccount
+	}
+	return set
+}
+
+// Cilium selectors may prefix keys with their source ("k8s:", "any:"),
+// which Kubernetes selectors do not allow
+func stripLabelSource(key string) string {
+	for _, prefix := range []string{"k8s:", "any:"} {
+		if strings.HasPrefix(key, prefix) {
+			return key[len(prefix):]
+		}
+	}
+	return key
+}
+
+// Convert a Cilium endpoint selector, reporting whether it constrains the
+// namespace itself
+func ciliumSelector(selector metav1.LabelSelector) (labels.Selector, bool, error) {
+	normalized := metav1.LabelSelector{MatchLabels: map[string]string{}}
+	referencesNamespace := false
+	for key, value := range selector.MatchLabels {
+		key = stripLabelSource(key)
+		referencesNamespace = referencesNamespace || key == ciliumNamespaceLabel
+		normalized.MatchLabels[key] = value
+	}
+	for _, expression := range selector.MatchExpressions {
+		expression.Key = stripLabelSource(expression.Key)
+		referencesNamespace = referencesNamespace || expression.Key == ciliumNamespaceLabel
+		normalized.MatchExpressions = append(normalized.MatchExpressions, expression)
+	}
+	converted, err := metav1.LabelSelectorAsSelector(&normalized)
+	return converted, referencesNamespace, err
+}
+
+func ipInBlock(ip, cidr string, except []string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil || !network.Contains(parsed) {
+		return false
+	}
+	for _, block := range except {
+		if _, excluded, err := net.ParseCIDR(block); err == nil && excluded.Contains(parsed) {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve a numeric or named port against the destination pod; 0 when a
+// named port is not exposed
+func resolvePolicyPort(port string, destination policyPod) int32 {
+	if number, err := strconv.Atoi(port); err == nil {
+		return int32(number)
+	}
+	return destination.NamedPorts[port]
+}
+
+// Kubernetes NetworkPolicy evaluation
+
+func kubePolicyTypes(policy KubeNetworkPolicy) []string {
+	if len(policy.Spec.PolicyTypes) > 0 {
+		types := []string{}
+		for _, policyType := range policy.Spec.PolicyTypes {
+			types = append(types, string(policyType))
+		}
+		return types
+	}
+	types := []string{"Ingress"}
+	if len(policy.Spec.Egress) > 0 {
+		types = append(types, "Egress")
+	}
+	return types
+}
+
+func hasPolicyType(types []string, policyType string) bool {
+	for _, t := range types {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+func kubePolicySelects(policy KubeNetworkPolicy, pod policyPod) bool {
+	if policy.Namespace != pod.Namespace {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+	return err == nil && selector.Matches(labels.Set(pod.Labels))
+}
+
+func kubePeerMatches(peer networkingv1.NetworkPolicyPeer, policyNamespace string, pod policyPod) bool {
+	// Cilium treats ipBlock as CIDR rules, which never select
+	// Cilium-managed pods even when the block covers the pod CIDR
+	if peer.IPBlock != nil {
+		return false
+	}
+	if peer.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+		if err != nil || !selector.Matches(labels.Set(pod.NamespaceLabels)) {
+			return false
+		}
+	} else if pod.Namespace != policyNamespace {
+		return false
+	}
+	if peer.PodSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+		return err == nil && selector.Matches(labels.Set(pod.Labels))
+	}
+	return true
+}
+
+func kubePortMatches(ports []networkingv1.NetworkPolicyPort, destination policyPod, port int32, protocol string) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, p := range ports {
+		policyProtocol := "TCP"
+		if p.Protocol != nil {
+			policyProtocol = string(*p.Protocol)
+		}
+		if policyProtocol != protocol {
+			continue
+		}
+		if p.Port == nil {
+			return true
+		}
+		number := resolvePolicyPort(p.Port.String(), destination)
+		if number == 0 {
+			continue
+		}
+		if p.EndPort != nil {
+			if port >= number && port <= *p.EndPort {
+				return true
+			}
+			continue
+		}
+		if port == number {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether a rule of the policy admits the peer; for egress the peer is the
+// destination, for ingress the subject is
+func kubePolicyAllows(policy KubeNetworkPolicy, ingress bool, peer, destination policyPod, port int32, protocol string) bool {
+	if ingress {
+		for _, rule := range policy.Spec.Ingress {
+			if !kubePortMatches(rule.Ports, destination, port, protocol) {
+				continue
+			}
+			if len(rule.From) == 0 {
+				return true
+			}
+			for _, from := range rule.From {
+				if kubePeerMatches(from, policy.Namespace, peer) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, rule := range policy.Spec.Egress {
+		if !kubePortMatches(rule.Ports, destination, port, protocol) {
+			continue
+		}
+		if len(rule.To) == 0 {
+			return true
+		}
+		for _, to := range rule.To {
+			if kubePeerMatches(to, policy.Namespace, peer) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CiliumNetworkPolicy evaluation
+
+// Whether a rule set applies to the pod. Rule sets with a nodeSelector
+// apply to nodes rather than pods, and clusterwide policies select pods in
+// every namespace.
+func ciliumSpecSelects(spec CiliumPolicySpec, policyNamespace string, pod policyPod) bool {
+	if spec.NodeSelector != nil {
+		return false
+	}
+	if policyNamespace != "" && policyNamespace != pod.Namespace {
+		return false
+	}
+	selector, _, err := ciliumSelector(spec.EndpointSelector)
+	return err == nil && selector.Matches(pod.ciliumLabels())
+}
+
+func ciliumPolicySelects(policy CiliumNetworkPolicy, pod policyPod) bool {
+	for _, spec := range policy.ruleSets() {
+		if ciliumSpecSelects(spec, policy.Namespace, pod) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the direction puts a selected endpoint into default deny
+func ciliumSpecEnforces(spec CiliumPolicySpec, ingress bool) bool {
+	rules, denies := spec.Egress, spec.EgressDeny
+	if ingress {
+		rules, denies = spec.Ingress, spec.IngressDeny
+	}
+	if len(rules) == 0 && len(denies) == 0 {
+		return false
+	}
+	if defaults := spec.EnableDefaultDeny; defaults != nil {
+		setting := defaults.Egress
+		if ingress {
+			setting = defaults.Ingress
+		}
+		if setting != nil && !*setting {
+			return false
+		}
+	}
+	return true
+}
+
+// Namespaced policies only select peers in their own namespace unless the
+// selector names one
+func ciliumEndpointMatches(selector metav1.LabelSelector, policyNamespace string, peer policyPod) bool {
+	converted, referencesNamespace, err := ciliumSelector(selector)
+	if err != nil {
+		return false
+	}
+	if policyNamespace != "" && !referencesNamespace && peer.Namespace != policyNamespace {
+		return false
+	}
+	return converted.Matches(peer.ciliumLabels())
+}
+
+// Endpoint and entity peers of a rule. CIDR, FQDN, group and node peers
+// never select Cilium-managed pods. A rule without any L3 peer matches
+// every peer only when it has L4 rules; a fully empty rule ("- {}")
+// matches nothing, which is how Cilium policies put an endpoint into
+// default deny. toServices peers cannot be resolved offline, so a rule
+// that could only match through them reports a note instead.
+func ciliumPeerMatches(rule CiliumPolicyRule, ingress bool, policyNamespace string, peer policyPod) (bool, string) {
+	endpoints, entities := rule.ToEndpoints, rule.ToEntities
+	hasL3 := len(rule.ToCIDR) > 0 || len(rule.ToCIDRSet) > 0 || len(rule.ToFQDNs) > 0 ||
+		len(rule.ToNodes) > 0 || len(rule.ToGroups) > 0 || len(rule.ToServices) > 0
+	if ingress {
+		endpoints, entities = rule.FromEndpoints, rule.FromEntities
+		hasL3 = len(rule.FromCIDR) > 0 || len(rule.FromCIDRSet) > 0 || len(rule.FromNodes) > 0 || len(rule.FromGroups) > 0
+	}
+	if len(endpoints) == 0 && len(entities) == 0 && !hasL3 {
+		return len(rule.ToPorts) > 0 || len(rule.ICMPs) > 0, ""
+	}
+
+	for _, entity := range entities {
+		if entity == "all" || entity == "cluster" {
+			return true, ""
+		}
+	}
+	for _, endpoint := range endpoints {
+		if ciliumEndpointMatches(endpoint, policyNamespace, peer) {
+			return true, ""
+		}
+	}
+	if !ingress && len(rule.ToServices) > 0 {
+		return false, "toServices peers cannot be resolved to pods"
+	}
+	return false, ""
+}
+
+// Whether the L4 part of a rule admits the port. ICMP rules never match
+// the TCP, UDP and SCTP traffic the simulator evaluates.
+func ciliumPortMatches(rule CiliumPolicyRule, destination policyPod, port int32, protocol string) (bool, bool) {
+	if len(rule.ToPorts) == 0 {
+		return len(rule.ICMPs) == 0, false
+	}
+	for _, portRule := range rule.ToPorts {
+		hasL7 := len(portRule.Rules) > 0
+		if len(portRule.Ports) == 0 {
+			return true, hasL7
+		}
+		for _, p := range portRule.Ports {
+			policyProtocol := strings.ToUpper(p.Protocol)
+			if policyProtocol != "" && policyProtocol != "ANY" && policyProtocol != protocol {
+				continue
+			}
+			if p.Port == "" || p.Port == "0" {
+				return true, hasL7
+			}
+			number := resolvePolicyPort(p.Port, destination)
+			if number == 0 {
+				continue
+			}
+			if p.EndPort > 0 {
+				if port >= number && port <= p.EndPort {
+					return true, hasL7
+				}
+				continue
+			}
+			if port == number {
+				return true, hasL7
+			}
+		}
+	}
+	return false, false
+}
+
+// Whether any rule matches, with notes for rules that might match but
+// cannot be evaluated
+func ciliumRulesMatch(field string, rules []CiliumPolicyRule, ingress bool, policyNamespace string, peer, destination policyPod, port int32, protocol string) (bool, bool, []string) {
+	var notes []string
+	for i, rule := range rules {
+		matched, note := ciliumPeerMatches(rule, ingress, policyNamespace, peer)
+		if !matched && note == "" {
+			continue
+		}
+		portMatched, l7 := ciliumPortMatches(rule, destination, port, protocol)
+		if !portMatched {
+			continue
+		}
+		if matched {
+			return true, l7, nil
+		}
+		notes = append(notes, fmt.Sprintf("%s[%d]: %s", field, i, note))
+	}
+	return false, false, notes
+}
+
+// fromRequires and toRequires constrain every peer of the selected
+// endpoint: a peer that does not match all of them is denied, whichever
+// rule would allow it
+func ciliumRequirementsMet(rules []CiliumPolicyRule, ingress bool, policyNamespace string, peer policyPod) bool {
+	for _, rule := range rules {
+		requirements := rule.ToRequires
+		if ingress {
+			requirements = rule.FromRequires
+		}
+		for _, requirement := range requirements {
+			if !ciliumEndpointMatches(requirement, policyNamespace, peer) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Evaluate one direction at the subject pod. For egress the subject is the
+// source and the peer the destination; for ingress it is the other way
+// round.
+func evaluatePolicyDirection(policies policySet, ingress bool, subject, peer policyPod, port int32, protocol string) DirectionVerdict {
+	verdict := DirectionVerdict{
+		SelectingPolicies: []string{},
+		AllowedBy:         []string{},
+		DeniedBy:          []string{},
+		Notes:             []string{},
+	}
+	destination := peer
+	policyType := "Egress"
+	if ingress {
+		destination = subject
+		policyType = "Ingress"
+	}
+
+	for _, policy := range policies.kube {
+		if !hasPolicyType(kubePolicyTypes(policy), policyType) || !kubePolicySelects(policy, subject) {
+			continue
+		}
+		ref := policyRef(kindNetworkPolicy, policy.Namespace, policy.Name)
+		verdict.Enforced = true
+		verdict.SelectingPolicies = append(verdict.SelectingPolicies, ref)
+		if kubePolicyAllows(policy, ingress, peer, destination, port, protocol) {
+			verdict.AllowedBy = append(verdict.AllowedBy, ref)
+		}
+	}
+
+	var unknownDenies, unknownAllows []string
+	for _, policy := range policies.cilium {
+		ref := policyRef(policy.kind(), policy.Namespace, policy.Name)
+		selecting, allowed, denied := false, false, false
+		for i, spec := range policy.ruleSets() {
+			if !ciliumSpecSelects(spec, policy.Namespace, subject) {
+				continue
+			}
+			rules, denies := spec.Egress, spec.EgressDeny
+			field := "egress"
+			if ingress {
+				rules, denies = spec.Ingress, spec.IngressDeny
+				field = "ingress"
+			}
+			if len(rules) == 0 && len(denies) == 0 {
+				continue
+			}
+			// ruleSets puts spec first, then specs
+			if i > 0 {
+				field = fmt.Sprintf("specs[%d].%s", i-1, field)
+			}
+			selecting = true
+			if ciliumSpecEnforces(spec, ingress) {
+				verdict.Enforced = true
+			}
+
+			matched, _, notes := ciliumRulesMatch(field+"Deny", denies, ingress, policy.Namespace, peer, destination, port, protocol)
+			if !ciliumRequirementsMet(rules, ingress, policy.Namespace, peer) {
+				matched = true
+				verdict.Notes = append(verdict.Notes, fmt.Sprintf("%s: the peer does not meet %s requirements", ref, field))
+			}
+			denied = denied || matched
+			for _, note := range notes {
+				unknownDenies = append(unknownDenies, ref+" "+note)
+			}
+
+			matched, l7, notes := ciliumRulesMatch(field, rules, ingress, policy.Namespace, peer, destination, port, protocol)
+			if matched {
+				allowed = true
+				verdict.L7Rules = verdict.L7Rules || l7
+			}
+			for _, note := range notes {
+				unknownAllows = append(unknownAllows, ref+" "+note)
+			}
+		}
+		if selecting {
+			verdict.SelectingPolicies = append(verdict.SelectingPolicies, ref)
+		}
+		if denied {
+			verdict.DeniedBy = append(verdict.DeniedBy, ref)
+		}
+		if allowed {
+			verdict.AllowedBy = append(verdict.AllowedBy, ref)
+		}
+	}
+
+	direction := strings.ToLower(policyType)
+	switch {
+	case len(verdict.DeniedBy) > 0:
+		verdict.Reason = fmt.Sprintf("%s denied by %s", direction, strings.Join(verdict.DeniedBy, ", "))
+	case len(unknownDenies) > 0:
+		verdict.Indeterminate = true
+		verdict.Notes = append(verdict.Notes, unknownDenies...)
+		verdict.Reason = fmt.Sprintf("%s may be denied by a rule that cannot be evaluated", direction)
+	case !verdict.Enforced:
+		verdict.Allowed = true
+		verdict.Reason = fmt.Sprintf("no policy restricts %s of %s/%s", direction, subject.Namespace, subject.Name)
+	case len(verdict.AllowedBy) > 0:
+		verdict.Allowed = true
+		verdict.Reason = fmt.Sprintf("%s allowed by %s", direction, strings.Join(verdict.AllowedBy, ", "))
+	case len(unknownAllows) > 0:
+		verdict.Indeterminate = true
+		verdict.Notes = append(verdict.Notes, unknownAllows...)
+		verdict.Reason = fmt.Sprintf("%s of %s/%s is restricted and is only allowed by rules that cannot be evaluated", direction, subject.Namespace, subject.Name)
+	default:
+		verdict.Reason = fmt.Sprintf("%s of %s/%s is restricted and no rule matches", direction, subject.Namespace, subject.Name)
+	}
+	return verdict
+}
+
+// Add proposed policies to the live set. A proposal replaces the live
+// policy of the same kind, namespace and name, so an edit is evaluated
+// without its old version.
+func overlayPolicies(live policySet, kube []KubeNetworkPolicy, cilium []CiliumNetworkPolicy) policySet {
+	result := policySet{kube: []KubeNetworkPolicy{}, cilium: []CiliumNetworkPolicy{}}
+
+	proposedKube := make(map[string]bool)
+	for _, policy := range kube {
+		proposedKube[policy.Namespace+"/"+policy.Name] = true
+	}
+	for _, policy := range live.kube {
+		if !proposedKube[policy.Namespace+"/"+policy.Name] {
+			result.kube = append(result.kube, policy)
+		}
+	}
+	result.kube = append(result.kube, kube...)
+
+	proposedCilium := make(map[string]bool)
+	for _, policy := range cilium {
+		proposedCilium[policy.Namespace+"/"+policy.Name] = true
+	}
+	for _, policy := range live.cilium {
+		if !proposedCilium[policy.Namespace+"/"+policy.Name] {
+			result.cilium = append(result.cilium, policy)
+		}
+	}
+	result.cilium = append(result.cilium, cilium...)
+
+	return result
+}
+
+func simulatePolicies(policies policySet, source, destination policyPod, port int32, protocol string) PolicySimulationResult {
+	result := PolicySimulationResult{
+		Source:      PolicyPodRef{Namespace: source.Namespace, Pod: source.Name},
+		SourceIP:    source.IP,
+		Destination: PolicyPodRef{Namespace: destination.Namespace, Pod: destination.Name},
+		DestIP:      destination.IP,
+		Port:        port,
+		Protocol:    protocol,
+		Egress:      evaluatePolicyDirection(policies, false, source, destination, port, protocol),
+		Ingress:     evaluatePolicyDirection(policies, true, destination, source, port, protocol),
+	}
+
+	// A definite deny in either direction decides the result even when the
+	// other direction is indeterminate
+	result.Allowed = result.Egress.Allowed && result.Ingress.Allowed
+	switch {
+	case !result.Egress.Allowed && !result.Egress.Indeterminate:
+		result.Reason = result.Egress.Reason
+	case !result.Ingress.Allowed && !result.Ingress.Indeterminate:
+		result.Reason = result.Ingress.Reason
+	case result.Egress.Indeterminate:
+		result.Indeterminate = true
+		result.Reason = result.Egress.Reason
+	case result.Ingress.Indeterminate:
+		result.Indeterminate = true
+		result.Reason = result.Ingress.Reason
+	default:
+		result.Reason = result.Egress.Reason + "; " + result.Ingress.Reason
+		if result.Egress.L7Rules || result.Ingress.L7Rules {
+			result.Reason += "; L7 rules further restrict individual requests"
+		}
+	}
+	return result
+}
+
+// Loading policies and pods
+
+func getKubeNetworkPolicies(namespace string) ([]KubeNetworkPolicy, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientset.NetworkingV1().NetworkPolicies(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	policies := []KubeNetworkPolicy{}
+	for _, item := range list.Items {
+		policies = append(policies, KubeNetworkPolicy{Name: item.Name, Namespace: item.Namespace, Spec: item.Spec})
+	}
+	return policies, nil
+}
+
+func getCiliumNetworkPolicies(namespace string) ([]CiliumNetworkPolicy, error) {
+	return listCiliumPolicies("ciliumnetworkpolicies.cilium.io", namespace)
+}
+
+// Clusterwide policies come back with an empty namespace
+func getCiliumClusterwideNetworkPolicies() ([]CiliumNetworkPolicy, error) {
+	return listCiliumPolicies("ciliumclusterwidenetworkpolicies.cilium.io", "")
+}
+
+func listCiliumPolicies(resource, namespace string) ([]CiliumNetworkPolicy, error) {
+	items, err := kubectlListResources(resource, namespace)
+	if err != nil {
+		return nil, err
+	}
+	policies := []CiliumNetworkPolicy{}
+	for _, item := range items {
+		policy := CiliumNetworkPolicy{Name: item.Metadata.Name, Namespace: item.Metadata.Namespace}
+		if len(item.Spec) > 0 {
+			if err := json.Unmarshal(item.Spec, &policy.Spec); err != nil {
+				log.Printf("Skipping %s %s: %v", policy.kind(), item.Metadata.Name, err)
+				continue
+			}
+		}
+		if len(item.Specs) > 0 {
+			if err := json.Unmarshal(item.Specs, &policy.Specs); err != nil {
+				log.Printf("Skipping %s %s: %v", policy.kind(), item.Metadata.Name, err)
+				continue
+			}
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// Running pods with their namespace labels, keyed by namespace/name
+func getPolicyPods(namespace string) (map[string]policyPod, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	namespaceLabels := make(map[string]map[string]string)
+	for _, ns := range namespaces.Items {
+		namespaceLabels[ns.Name] = ns.Labels
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]policyPod)
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed || pod.Spec.HostNetwork {
+			continue
+		}
+		result[pod.Namespace+"/"+pod.Name] = newPolicyPod(pod, namespaceLabels[pod.Namespace])
+	}
+	return result, nil
+}
+
+func selectedPodNames(pods map[string]policyPod, selects func(policyPod) bool) []string {
+	names := []string{}
+	for _, pod := range pods {
+		if selects(pod) {
+			names = append(names, pod.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func kubePolicyInfo(policy KubeNetworkPolicy, pods map[string]policyPod) NetworkPolicyInfo {
+	return NetworkPolicyInfo{
+		Kind:         kindNetworkPolicy,
+		Name:         policy.Name,
+		Namespace:    policy.Namespace,
+		PolicyTypes:  kubePolicyTypes(policy),
+		IngressRules: len(policy.Spec.Ingress),
+		EgressRules:  len(policy.Spec.Egress),
+		SelectedPods: selectedPodNames(pods, func(pod policyPod) bool { return kubePolicySelects(policy, pod) }),
+		Spec:         policy.Spec,
+	}
+}
+
+func ciliumPolicyInfo(policy CiliumNetworkPolicy, pods map[string]policyPod) NetworkPolicyInfo {
+	info := NetworkPolicyInfo{
+		Kind:         policy.kind(),
+		Name:         policy.Name,
+		Namespace:    policy.Namespace,
+		PolicyTypes:  []string{},
+		SelectedPods: selectedPodNames(pods, func(pod policyPod) bool { return ciliumPolicySelects(policy, pod) }),
+		Spec:         policy.Spec,
+	}
+	if len(policy.Specs) > 0 {
+		info.Spec = policy.ruleSets()
+		if ciliumSpecRules(policy.Spec) == 0 {
+			info.Spec = policy.Specs
+		}
+	}
+	hasIngress, hasEgress := false, false
+	for _, spec := range policy.ruleSets() {
+		info.IngressRules += len(spec.Ingress)
+		info.EgressRules += len(spec.Egress)
+		info.DenyRules += len(spec.IngressDeny) + len(spec.EgressDeny)
+		hasIngress = hasIngress || len(spec.Ingress) > 0 || len(spec.IngressDeny) > 0
+		hasEgress = hasEgress || len(spec.Egress) > 0 || len(spec.EgressDeny) > 0
+	}
+	if hasIngress {
+		info.PolicyTypes = append(info.PolicyTypes, "Ingress")
+	}
+	if hasEgress {
+		info.PolicyTypes = append(info.PolicyTypes, "Egress")
+	}
+	return info
+}
+
+// Validation and manifests
+
+func validateKubeNetworkPolicy(policy KubeNetworkPolicy) []string {
+	var errors []string
+	if policy.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	if _, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector); err != nil {
+		errors = append(errors, fmt.Sprintf("podSelector: %v", err))
+	}
+	for _, policyType := range policy.Spec.PolicyTypes {
+		if policyType != networkingv1.PolicyTypeIngress && policyType != networkingv1.PolicyTypeEgress {
+			errors = append(errors, fmt.Sprintf("unsupported policyType %q", policyType))
+		}
+	}
+
+	checkPeers := func(field string, peers []networkingv1.NetworkPolicyPeer) {
+		for i, peer := range peers {
+			if peer.IPBlock != nil {
+				if peer.PodSelector != nil || peer.NamespaceSelector != nil {
+					errors = append(errors, fmt.Sprintf("%s[%d]: ipBlock cannot be combined with selectors", field, i))
+				}
+				if !validIPBlock(peer.IPBlock.CIDR) || !strings.Contains(peer.IPBlock.CIDR, "/") {
+					errors = append(errors, fmt.Sprintf("%s[%d]: invalid ipBlock cidr %q", field, i, peer.IPBlock.CIDR))
+				}
+				for _, except := range peer.IPBlock.Except {
+					if !ipInBlock(strings.Split(except, "/")[0], peer.IPBlock.CIDR, nil) {
+						errors = append(errors, fmt.Sprintf("%s[%d]: except %q is outside %q", field, i, except, peer.IPBlock.CIDR))
+					}
+				}
+			}
+		}
+	}
+	checkPorts := func(field string, ports []networkingv1.NetworkPolicyPort) {
+		for i, port := range ports {
+			if port.Protocol != nil {
+				switch *port.Protocol {
+				case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+				default:
+					errors = append(errors, fmt.Sprintf("%s[%d]: unsupported protocol %q", field, i, *port.Protocol))
+				}
+			}
+			if port.EndPort != nil {
+				if port.Port == nil || port.Port.Type != intstr.Int {
+					errors = append(errors, fmt.Sprintf("%s[%d]: endPort requires a numeric port", field, i))
+				} else if *port.EndPort < port.Port.IntVal {
+					errors = append(errors, fmt.Sprintf("%s[%d]: endPort must not be below port", field, i))
+				}
+			}
+		}
+	}
+	for i, rule := range policy.Spec.Ingress {
+		checkPeers(fmt.Sprintf("ingress[%d].from", i), rule.From)
+		checkPorts(fmt.Sprintf("ingress[%d].ports", i), rule.Ports)
+	}
+	for i, rule := range policy.Spec.Egress {
+		checkPeers(fmt.Sprintf("egress[%d].to", i), rule.To)
+		checkPorts(fmt.Sprintf("egress[%d].ports", i), rule.Ports)
+	}
+	return errors
+}
+
+func validateCiliumNetworkPolicy(policy CiliumNetworkPolicy) []string {
+	var errors []string
+	if policy.Name == "" {
+		errors = append(errors, "name is required")
+	}
+
+	ruleCount := 0
+	for i, spec := range policy.ruleSets() {
+		prefix := ""
+		if i > 0 {
+			prefix = fmt.Sprintf("specs[%d].", i-1)
+		}
+		ruleCount += ciliumSpecRules(spec)
+		errors = append(errors, validateCiliumPolicySpec(prefix, spec)...)
+	}
+	if ruleCount == 0 {
+		errors = append(errors, "at least one ingress, ingressDeny, egress or egressDeny rule is required")
+	}
+	return errors
+}
+
+func ciliumSpecRules(spec CiliumPolicySpec) int {
+	return len(spec.Ingress) + len(spec.IngressDeny) + len(spec.Egress) + len(spec.EgressDeny)
+}
+
+func validateCiliumPolicySpec(prefix string, spec CiliumPolicySpec) []string {
+	var errors []string
+	if _, _, err := ciliumSelector(spec.EndpointSelector); err != nil {
+		errors = append(errors, fmt.Sprintf("%sendpointSelector: %v", prefix, err))
+	}
+
+	checkRules := func(field string, rules []CiliumPolicyRule) {
+		field = prefix + field
+		for i, rule := range rules {
+			selectors := [][]metav1.LabelSelector{
+				rule.FromEndpoints, rule.ToEndpoints, rule.FromRequires, rule.ToRequires, rule.FromNodes, rule.ToNodes,
+			}
+			for _, list := range selectors {
+				for _, selector := range list {
+					if _, _, err := ciliumSelector(selector); err != nil {
+						errors = append(errors, fmt.Sprintf("%s[%d]: invalid endpoint selector: %v", field, i, err))
+					}
+				}
+			}
+			for _, cidr := range append(append([]string{}, rule.FromCIDR...), rule.ToCIDR...) {
+				if !validIPBlock(cidr) {
+					errors = append(errors, fmt.Sprintf("%s[%d]: invalid CIDR %q", field, i, cidr))
+				}
+			}
+			for _, set := range append(append([]CiliumCIDRRule{}, rule.FromCIDRSet...), rule.ToCIDRSet...) {
+				if !validIPBlock(set.CIDR) {
+					errors = append(errors, fmt.Sprintf("%s[%d]: invalid CIDR %q", field, i, set.CIDR))
+				}
+			}
+			for j, portRule := range rule.ToPorts {
+				for _, port := range portRule.Ports {
+					switch strings.ToUpper(port.Protocol) {
+					case "", "TCP", "UDP", "SCTP", "ANY":
+					default:
+						errors = append(errors, fmt.Sprintf("%s[%d].toPorts[%d]: unsupported protocol %q", field, i, j, port.Protocol))
+					}
+					if number, err := strconv.Atoi(port.Port); err == nil && (number < 0 || number > 65535) {
+						errors = append(errors, fmt.Sprintf("%s[%d].toPorts[%d]: port %q out of range", field, i, j, port.Port))
+					}
+				}
+			}
+		}
+	}
+	checkRules("ingress", spec.Ingress)
+	checkRules("ingressDeny", spec.IngressDeny)
+	checkRules("egress", spec.Egress)
+	checkRules("egressDeny", spec.EgressDeny)
+
+	// Deny rules cannot carry L7 rules
+	for i, rule := range append(append([]CiliumPolicyRule{}, spec.IngressDeny...), spec.EgressDeny...) {
+		for _, portRule := range rule.ToPorts {
+			if len(portRule.Rules) > 0 {
+				errors = append(errors, fmt.Sprintf("%sdeny rule %d: L7 rules are not supported in deny policies", prefix, i))
+			}
+		}
+	}
+	return errors
+}
+
+func networkPolicyManifest(policy KubeNetworkPolicy) k8sResource {
+	return k8sResource{
+		APIVersion: "networking.k8s.io/v1",
+		Kind:       kindNetworkPolicy,
+		Metadata: k8sObjectMeta{
+			Name:      policy.Name,
+			Namespace: policy.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: policy.Spec,
+	}
+}
+
+func ciliumNetworkPolicyManifest(policy CiliumNetworkPolicy) k8sResource {
+	return k8sResource{
+		APIVersion: "cilium.io/v2",
+		Kind:       kindCiliumNetworkPolicy,
+		Metadata: k8sObjectMeta{
+			Name:      policy.Name,
+			Namespace: policy.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: policy.Spec,
+	}
+}
+
+// Resource names used in the policy routes
+var networkPolicyResources = map[string]string{
+	"networkpolicies":                  "networkpolicies.networking.k8s.io",
+	"ciliumnetworkpolicies":            "ciliumnetworkpolicies.cilium.io",
+	"ciliumclusterwidenetworkpolicies": "ciliumclusterwidenetworkpolicies.cilium.io",
+}
+
+func registerCiliumPolicyRoutes(e *echo.Echo) {
+	// NetworkPolicies, CiliumNetworkPolicies and
+	// CiliumClusterwideNetworkPolicies with the pods each selects. kind=
+	// limits the list to one of them.
+	e.GET("/api/cilium/policies", func(c echo.Context) error {
+		namespace, kind := c.QueryParam("namespace"), c.QueryParam("kind")
+		if _, ok := networkPolicyResources[kind]; kind != "" && !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("unsupported kind %q (use networkpolicies, ciliumnetworkpolicies or ciliumclusterwidenetworkpolicies)", kind),
+			})
+		}
+
+		pods, err := getPolicyPods(namespace)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list pods: %v", err),
+			})
+		}
+
+		policies := []NetworkPolicyInfo{}
+		response := map[string]interface{}{}
+		if kind == "" || kind == "networkpolicies" {
+			kubePolicies, err := getKubeNetworkPolicies(namespace)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": fmt.Sprintf("Failed to list NetworkPolicies: %v", err),
+				})
+			}
+			for _, policy := range kubePolicies {
+				policies = append(policies, kubePolicyInfo(policy, pods))
+			}
+		}
+		if kind == "" || kind == "ciliumnetworkpolicies" {
+			ciliumPolicies, err := getCiliumNetworkPolicies(namespace)
+			if err != nil {
+				// Without the Cilium CRDs only NetworkPolicies exist
+				log.Printf("Warning: Could not list CiliumNetworkPolicies: %v", err)
+				response["cilium_error"] = err.Error()
+			}
+			for _, policy := range ciliumPolicies {
+				policies = append(policies, ciliumPolicyInfo(policy, pods))
+			}
+		}
+		if kind == "" || kind == "ciliumclusterwidenetworkpolicies" {
+			clusterwidePolicies, err := getCiliumClusterwideNetworkPolicies()
+			if err != nil {
+				log.Printf("Warning: Could not list CiliumClusterwideNetworkPolicies: %v", err)
+				response["cilium_clusterwide_error"] = err.Error()
+			}
+			for _, policy := range clusterwidePolicies {
+				policies = append(policies, ciliumPolicyInfo(policy, pods))
+			}
+		}
+
+		response["policies"] = policies
+		response["count"] = len(policies)
+		return c.JSON(http.StatusOK, response)
+	})
+
+	// Create a NetworkPolicy. ?dry_run=true validates it against the API
+	// server and returns the pods it would select.
+	e.POST("/api/cilium/policies/networkpolicies", func(c echo.Context) error {
+		var policy KubeNetworkPolicy
+		if err := c.Bind(&policy); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid NetworkPolicy payload",
+			})
+		}
+		if policy.Namespace == "" {
+			policy.Namespace = "default"
+		}
+
+		errors := validateKubeNetworkPolicy(policy)
+		if len(errors) == 0 {
+			if err := kubectlDryRun(networkPolicyManifest(policy), true); err != nil {
+				errors = append(errors, err.Error())
+			}
+		}
+		return createNetworkPolicy(c, kindNetworkPolicy, policy.Namespace, errors, networkPolicyManifest(policy),
+			func(pod policyPod) bool { return kubePolicySelects(policy, pod) })
+	})
+
+	// Create a CiliumNetworkPolicy, with the same dry run support
+	e.POST("/api/cilium/policies/ciliumnetworkpolicies", func(c echo.Context) error {
+		var policy CiliumNetworkPolicy
+		if err := c.Bind(&policy); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid CiliumNetworkPolicy payload",
+			})
+		}
+		if policy.Namespace == "" {
+			policy.Namespace = "default"
+		}
+
+		errors := validateCiliumNetworkPolicy(policy)
+		if len(policy.Specs) > 0 {
+			errors = append(errors, "specs is not supported when creating a policy; put the rules in spec")
+		}
+		if len(errors) == 0 {
+			if err := kubectlDryRun(ciliumNetworkPolicyManifest(policy), true); err != nil {
+				errors = append(errors, err.Error())
+			}
+		}
+		return createNetworkPolicy(c, kindCiliumNetworkPolicy, policy.Namespace, errors, ciliumNetworkPolicyManifest(policy),
+			func(pod policyPod) bool { return ciliumPolicySelects(policy, pod) })
+	})
+
+	// Delete a policy of either kind
+	e.DELETE("/api/cilium/policies/:kind/:namespace/:name", func(c echo.Context) error {
+		resource, ok := networkPolicyResources[c.Param("kind")]
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("unsupported kind %q (use networkpolicies, ciliumnetworkpolicies or ciliumclusterwidenetworkpolicies)", c.Param("kind")),
+			})
+		}
+
+		output, err := kubectlDeleteResource(resource, c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "Policy not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to delete policy: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": output,
+		})
+	})
+
+	// Can the source pod reach the destination pod on a port? Policies in
+	// the request body are evaluated as if they already existed, replacing
+	// live policies of the same name, so a change can be tried before it is
+	// applied. Rules that cannot be resolved to pods, such as toServices,
+	// make the result indeterminate rather than allowed.
+	e.POST("/api/cilium/policies/simulate", func(c echo.Context) error {
+		var request PolicySimulationRequest
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid simulation request",
+			})
+		}
+		request.Protocol = strings.ToUpper(request.Protocol)
+		if request.Protocol == "" {
+			request.Protocol = "TCP"
+		}
+
+		var errors []string
+		if request.Source.Pod == "" || request.Destination.Pod == "" {
+			errors = append(errors, "source.pod and destination.pod are required")
+		}
+		if request.Port < 1 || request.Port > 65535 {
+			errors = append(errors, "port must be between 1 and 65535")
+		}
+		if request.Protocol != "TCP" && request.Protocol != "UDP" && request.Protocol != "SCTP" {
+			errors = append(errors, fmt.Sprintf("unsupported protocol %q", request.Protocol))
+		}
+		for i := range request.NetworkPolicies {
+			if request.NetworkPolicies[i].Namespace == "" {
+				request.NetworkPolicies[i].Namespace = "default"
+			}
+			for _, err := range validateKubeNetworkPolicy(request.NetworkPolicies[i]) {
+				errors = append(errors, fmt.Sprintf("network_policies[%d]: %s", i, err))
+			}
+		}
+		for i := range request.CiliumNetworkPolicies {
+			if request.CiliumNetworkPolicies[i].Namespace == "" {
+				request.CiliumNetworkPolicies[i].Namespace = "default"
+			}
+			for _, err := range validateCiliumNetworkPolicy(request.CiliumNetworkPolicies[i]) {
+				errors = append(errors, fmt.Sprintf("cilium_network_policies[%d]: %s", i, err))
+			}
+		}
+		if len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+		for _, ref := range []*PolicyPodRef{&request.Source, &request.Destination} {
+			if ref.Namespace == "" {
+				ref.Namespace = "default"
+			}
+		}
+
+		pods, err := getPolicyPods("")
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list pods: %v", err),
+			})
+		}
+		source, ok := pods[request.Source.Namespace+"/"+request.Source.Pod]
+		if !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": fmt.Sprintf("source pod %s/%s not found or not running", request.Source.Namespace, request.Source.Pod),
+			})
+		}
+		destination, ok := pods[request.Destination.Namespace+"/"+request.Destination.Pod]
+		if !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": fmt.Sprintf("destination pod %s/%s not found or not running", request.Destination.Namespace, request.Destination.Pod),
+			})
+		}
+
+		kubePolicies, err := getKubeNetworkPolicies("")
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list NetworkPolicies: %v", err),
+			})
+		}
+		ciliumPolicies, err := getCiliumNetworkPolicies("")
+		if err != nil {
+			log.Printf("Warning: Could not list CiliumNetworkPolicies: %v", err)
+			ciliumPolicies = []CiliumNetworkPolicy{}
+		}
+		clusterwidePolicies, err := getCiliumClusterwideNetworkPolicies()
+		if err != nil {
+			log.Printf("Warning: Could not list CiliumClusterwideNetworkPolicies: %v", err)
+		}
+		ciliumPolicies = append(ciliumPolicies, clusterwidePolicies...)
+
+		policies := overlayPolicies(policySet{kube: kubePolicies, cilium: ciliumPolicies},
+			request.NetworkPolicies, request.CiliumNetworkPolicies)
+		return c.JSON(http.StatusOK, simulatePolicies(policies, source, destination, request.Port, request.Protocol))
+	})
+}
+
+func createNetworkPolicy(c echo.Context, kind, namespace string, errors []string, manifest k8sResource, selects func(policyPod) bool) error {
+	if len(errors) > 0 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"valid":  false,
+			"errors": errors,
+		})
+	}
+
+	pods, err := getPolicyPods(namespace)
+	if err != nil {
+		log.Printf("Warning: Could not list pods in %s: %v", namespace, err)
+		pods = map[string]policyPod{}
+	}
+	selected := selectedPodNames(pods, selects)
+
+	if c.QueryParam("dry_run") == "true" {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"valid":         true,
+			"message":       fmt.Sprintf("%s is valid and selects %d pods", kind, len(selected)),
+			"policy":        manifest,
+			"selected_pods": selected,
+		})
+	}
+
+	output, err := kubectlCreate(manifest, false)
+	if err != nil {
+		log.Printf("Error creating %s: %v", kind, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("Failed to create %s: %v", kind, err),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"success":       true,
+		"message":       fmt.Sprintf("%s created successfully", kind),
+		"output":        output,
+		"policy":        manifest,
+		"selected_pods": selected,
+	})
+}
@@ -0,0 +1,918 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Istio AuthorizationPolicy management and an offline evaluator that mirrors
+// the proxy's decision order: CUSTOM, then DENY, then ALLOW.
+
+const istioTrustDomain = "cluster.local"
+
+type AuthorizationPolicy struct {
+	Name      string              `json:"name"`
+	Namespace string              `json:"namespace"`
+	Selector  map[string]string   `json:"selector,omitempty"`
+	Action    string              `json:"action"`
+	Provider  string              `json:"provider,omitempty"`
+	Rules     []AuthorizationRule `json:"rules"`
+}
+
+// Rule types follow the Istio CRD field names
+type AuthorizationRule struct {
+	From []RuleFrom      `json:"from,omitempty"`
+	To   []RuleTo        `json:"to,omitempty"`
+	When []RuleCondition `json:"when,omitempty"`
+}
+
+type RuleFrom struct {
+	Source *RuleSource `json:"source,omitempty"`
+}
+
+type RuleSource struct {
+	Principals           []string `json:"principals,omitempty"`
+	NotPrincipals        []string `json:"notPrincipals,omitempty"`
+	RequestPrincipals    []string `json:"requestPrincipals,omitempty"`
+	NotRequestPrincipals []string `json:"notRequestPrincipals,omitempty"`
+	Namespaces           []string `json:"namespaces,omitempty"`
+	NotNamespaces        []string `json:"notNamespaces,omitempty"`
+	IPBlocks             []string `json:"ipBlocks,omitempty"`
+	NotIPBlocks          []string `json:"notIpBlocks,omitempty"`
+	RemoteIPBlocks       []string `json:"remoteIpBlocks,omitempty"`
+	NotRemoteIPBlocks    []string `json:"notRemoteIpBlocks,omitempty"`
+}
+
+type RuleTo struct {
+	Operation *RuleOperation `json:"operation,omitempty"`
+}
+
+type RuleOperation struct {
+	Hosts      []string `json:"hosts,omitempty"`
+	NotHosts   []string `json:"notHosts,omitempty"`
+	Ports      []string `json:"ports,omitempty"`
+	NotPorts   []string `json:"notPorts,omitempty"`
+	Methods    []string `json:"methods,omitempty"`
+	NotMethods []string `json:"notMethods,omitempty"`
+	Paths      []string `json:"paths,omitempty"`
+	NotPaths   []string `json:"notPaths,omitempty"`
+}
+
+type RuleCondition struct {
+	Key       string   `json:"key"`
+	Values    []string `json:"values,omitempty"`
+	NotValues []string `json:"notValues,omitempty"`
+}
+
+type workloadSelector struct {
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+}
+
+type authorizationPolicySpec struct {
+	Selector *workloadSelector `json:"selector,omitempty"`
+	Action   string            `json:"action,omitempty"`
+	Provider *struct {
+		Name string `json:"name"`
+	} `json:"provider,omitempty"`
+	Rules []AuthorizationRule `json:"rules,omitempty"`
+}
+
+var validAuthorizationActions = map[string]bool{
+	"ALLOW":  true,
+	"DENY":   true,
+	"AUDIT":  true,
+	"CUSTOM": true,
+}
+
+// Request to evaluate. The source is identified by workload or service
+// account; with neither it is treated as an unauthenticated client.
+type AuthzEvaluation struct {
+	Source      AuthzSource      `json:"source"`
+	Destination AuthzDestination `json:"destination"`
+}
+
+type AuthzSource struct {
+	Namespace        string `json:"namespace,omitempty"`
+	Workload         string `json:"workload,omitempty"`
+	ServiceAccount   string `json:"service_account,omitempty"`
+	IP               string `json:"ip,omitempty"`
+	RequestPrincipal string `json:"request_principal,omitempty"`
+	Principal        string `json:"principal,omitempty"`
+	MTLS             bool   `json:"mtls"`
+}
+
+type AuthzDestination struct {
+	Namespace string            `json:"namespace"`
+	Workload  string            `json:"workload,omitempty"`
+	Service   string            `json:"service,omitempty"`
+	Host      string            `json:"host,omitempty"`
+	Port      string            `json:"port,omitempty"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// Action is ALLOW, DENY, or UNKNOWN when the outcome depends on conditions
+// that cannot be evaluated offline; Allowed is false for UNKNOWN
+type AuthzDecision struct {
+	Allowed  bool                `json:"allowed"`
+	Action   string              `json:"action"`
+	Policy   string              `json:"policy,omitempty"`
+	Rule     *int                `json:"rule,omitempty"`
+	Reason   string              `json:"reason"`
+	Source   AuthzSource         `json:"source"`
+	Policies []AuthzPolicyResult `json:"policies"`
+	Notes    []string            `json:"notes"`
+}
+
+type AuthzPolicyResult struct {
+	Policy  string `json:"policy"`
+	Action  string `json:"action"`
+	Matched bool   `json:"matched"`
+	// A rule might match, depending on a condition that cannot be evaluated
+	Indeterminate bool `json:"indeterminate"`
+	Rule          *int `json:"rule,omitempty"`
+}
+
+func getAuthorizationPolicies(namespace string) ([]AuthorizationPolicy, error) {
+	items, err := kubectlListResources("authorizationpolicies.security.istio.io", namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := []AuthorizationPolicy{}
+	for _, item := range items {
+		policy, err := authorizationPolicyFromResource(item)
+		if err != nil {
+			log.Printf("Skipping AuthorizationPolicy %s/%s: %v", item.Metadata.Namespace, item.Metadata.Name, err)
+			continue
+		}
+		policies = append(policies, *policy)
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		if policies[i].Namespace != policies[j].Namespace {
+			return policies[i].Namespace < policies[j].Namespace
+		}
+		return policies[i].Name < policies[j].Name
+	})
+
+	return policies, nil
+}
+
+func getAuthorizationPolicy(namespace, name string) (*AuthorizationPolicy, error) {
+	resource, err := kubectlGetResource("authorizationpolicies.security.istio.io", namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return authorizationPolicyFromResource(*resource)
+}
+
+func authorizationPolicyFromResource(resource k8sRawResource) (*AuthorizationPolicy, error) {
+	var spec authorizationPolicySpec
+	if err := json.Unmarshal(resource.Spec, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %v", err)
+	}
+
+	policy := &AuthorizationPolicy{
+		Name:      resource.Metadata.Name,
+		Namespace: resource.Metadata.Namespace,
+		Action:    spec.Action,
+		Rules:     spec.Rules,
+	}
+	if policy.Action == "" {
+		policy.Action = "ALLOW"
+	}
+	if spec.Selector != nil && len(spec.Selector.MatchLabels) > 0 {
+		policy.Selector = spec.Selector.MatchLabels
+	}
+	if spec.Provider != nil {
+		policy.Provider = spec.Provider.Name
+	}
+	if policy.Rules == nil {
+		policy.Rules = []AuthorizationRule{}
+	}
+
+	return policy, nil
+}
+
+func authorizationPolicyManifest(policy AuthorizationPolicy) k8sResource {
+	spec := authorizationPolicySpec{
+		Action: policy.Action,
+		Rules:  policy.Rules,
+	}
+	if len(policy.Selector) > 0 {
+		spec.Selector = &workloadSelector{MatchLabels: policy.Selector}
+	}
+	if policy.Provider != "" {
+		spec.Provider = &struct {
+			Name string `json:"name"`
+		}{Name: policy.Provider}
+	}
+
+	return k8sResource{
+		APIVersion: "security.istio.io/v1beta1",
+		Kind:       "AuthorizationPolicy",
+		Metadata: k8sObjectMeta{
+			Name:      policy.Name,
+			Namespace: policy.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: spec,
+	}
+}
+
+func normalizeAuthorizationPolicy(policy *AuthorizationPolicy) {
+	if policy.Namespace == "" {
+		policy.Namespace = "default"
+	}
+	if policy.Action == "" {
+		policy.Action = "ALLOW"
+	}
+	policy.Action = strings.ToUpper(policy.Action)
+}
+
+func validateAuthorizationPolicy(policy AuthorizationPolicy) []string {
+	var errors []string
+
+	if policy.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	if !validAuthorizationActions[policy.Action] {
+		errors = append(errors, fmt.Sprintf("unsupported action %q (use ALLOW, DENY, AUDIT or CUSTOM)", policy.Action))
+	}
+	if policy.Action == "CUSTOM" && policy.Provider == "" {
+		errors = append(errors, "CUSTOM action requires a provider")
+	}
+	if policy.Action != "CUSTOM" && policy.Provider != "" {
+		errors = append(errors, "provider is only allowed with the CUSTOM action")
+	}
+
+	for i, rule := range policy.Rules {
+		for j, from := range rule.From {
+			if from.Source == nil {
+				errors = append(errors, fmt.Sprintf("rules[%d].from[%d]: source is required", i, j))
+				continue
+			}
+			for _, block := range append(append(append(from.Source.IPBlocks, from.Source.NotIPBlocks...), from.Source.RemoteIPBlocks...), from.Source.NotRemoteIPBlocks...) {
+				if !validIPBlock(block) {
+					errors = append(errors, fmt.Sprintf("rules[%d].from[%d]: invalid IP block %q", i, j, block))
+				}
+			}
+		}
+		for j, to := range rule.To {
+			if to.Operation == nil {
+				errors = append(errors, fmt.Sprintf("rules[%d].to[%d]: operation is required", i, j))
+				continue
+			}
+			for _, port := range append(to.Operation.Ports, to.Operation.NotPorts...) {
+				if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+					errors = append(errors, fmt.Sprintf("rules[%d].to[%d]: invalid port %q", i, j, port))
+				}
+			}
+		}
+		for j, condition := range rule.When {
+			if condition.Key == "" {
+				errors = append(errors, fmt.Sprintf("rules[%d].when[%d]: key is required", i, j))
+			}
+			if len(condition.Values) == 0 && len(condition.NotValues) == 0 {
+				errors = append(errors, fmt.Sprintf("rules[%d].when[%d]: values or notValues is required", i, j))
+			}
+		}
+	}
+
+	return errors
+}
+
+func validIPBlock(block string) bool {
+	if _, _, err := net.ParseCIDR(block); err == nil {
+		return true
+	}
+	return net.ParseIP(block) != nil
+}
+
+func dryRunAuthorizationPolicy(policy AuthorizationPolicy, create bool) []string {
+	if errors := validateAuthorizationPolicy(policy); len(errors) > 0 {
+		return errors
+	}
+	if err := kubectlDryRun(authorizationPolicyManifest(policy), create); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// Istio string matching: exact, "prefix*", "*suffix", or "*" for any non-empty value
+func authzValueMatches(pattern, value string) bool {
+	switch {
+	case pattern == "*":
+		return value != ""
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(value, pattern[1:])
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(value, pattern[:len(pattern)-1])
+	}
+	return pattern == value
+}
+
+func authzHostMatches(pattern, value string) bool {
+	return authzValueMatches(strings.ToLower(pattern), strings.ToLower(value))
+}
+
+func authzIPMatches(block, value string) bool {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return false
+	}
+	if _, cidr, err := net.ParseCIDR(block); err == nil {
+		return cidr.Contains(ip)
+	}
+	return net.ParseIP(block).Equal(ip)
+}
+
+func authzExactMatches(pattern, value string) bool {
+	return pattern == value
+}
+
+// A field matches when the value hits one of values (if any) and none of notValues
+func authzFieldMatches(values, notValues []string, value string, match func(string, string) bool) bool {
+	if len(values) > 0 {
+		found := false
+		for _, pattern := range values {
+			if match(pattern, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, pattern := range notValues {
+		if match(pattern, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func authzSourceMatches(source *RuleSource, request AuthzEvaluation) bool {
+	if source == nil {
+		return true
+	}
+	src := request.Source
+	namespace := ""
+	if src.MTLS {
+		namespace = src.Namespace
+	}
+
+	return authzFieldMatches(source.Principals, source.NotPrincipals, src.Principal, authzValueMatches) &&
+		authzFieldMatches(source.RequestPrincipals, source.NotRequestPrincipals, src.RequestPrincipal, authzValueMatches) &&
+		authzFieldMatches(source.Namespaces, source.NotNamespaces, namespace, authzValueMatches) &&
+		authzFieldMatches(source.IPBlocks, source.NotIPBlocks, src.IP, authzIPMatches) &&
+		authzFieldMatches(source.RemoteIPBlocks, source.NotRemoteIPBlocks, src.IP, authzIPMatches)
+}
+
+func authzOperationMatches(operation *RuleOperation, request AuthzEvaluation) bool {
+	if operation == nil {
+		return true
+	}
+	dst := request.Destination
+
+	return authzFieldMatches(operation.Hosts, operation.NotHosts, dst.Host, authzHostMatches) &&
+		authzFieldMatches(operation.Ports, operation.NotPorts, dst.Port, authzExactMatches) &&
+		authzFieldMatches(operation.Methods, operation.NotMethods, dst.Method, authzExactMatches) &&
+		authzFieldMatches(operation.Paths, operation.NotPaths, dst.Path, authzValueMatches)
+}
+
+// Value of a condition key for the request. Keys that depend on data the
+// evaluator does not have, such as JWT claims or headers when the request
+// gives none, report ok=false.
+func authzConditionValue(key string, request AuthzEvaluation) (string, bool) {
+	if strings.HasPrefix(key, "request.headers[") && strings.HasSuffix(key, "]") {
+		if request.Destination.Headers == nil {
+			return "", false
+		}
+		name := key[len("request.headers[") : len(key)-1]
+		for header, value := range request.Destination.Headers {
+			if strings.EqualFold(header, name) {
+				return value, true
+			}
+		}
+		return "", true
+	}
+
+	switch key {
+	case "source.ip", "remote.ip":
+		return request.Source.IP, true
+	case "source.namespace":
+		if request.Source.MTLS {
+			return request.Source.Namespace, true
+		}
+		return "", true
+	case "source.principal":
+		return request.Source.Principal, true
+	case "request.auth.principal":
+		return request.Source.RequestPrincipal, true
+	case "destination.port":
+		return request.Destination.Port, true
+	}
+	return "", false
+}
+
+// Outcome of matching a rule against a request
+const (
+	authzNoMatch = iota
+	authzMatch
+	authzIndeterminate
+)
+
+func authzConditionMatches(condition RuleCondition, request AuthzEvaluation, notes *[]string) int {
+	value, ok := authzConditionValue(condition.Key, request)
+	if !ok {
+		*notes = append(*notes, fmt.Sprintf("Condition key %q cannot be evaluated offline", condition.Key))
+		return authzIndeterminate
+	}
+
+	match := authzValueMatches
+	if condition.Key == "source.ip" || condition.Key == "remote.ip" {
+		match = authzIPMatches
+	}
+	if authzFieldMatches(condition.Values, condition.NotValues, value, match) {
+		return authzMatch
+	}
+	return authzNoMatch
+}
+
+func authzRuleMatches(rule AuthorizationRule, request AuthzEvaluation, notes *[]string) int {
+	if len(rule.From) > 0 {
+		matched := false
+		for _, from := range rule.From {
+			if authzSourceMatches(from.Source, request) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return authzNoMatch
+		}
+	}
+
+	if len(rule.To) > 0 {
+		matched := false
+		for _, to := range rule.To {
+			if authzOperationMatches(to.Operation, request) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return authzNoMatch
+		}
+	}
+
+	// Any condition that does not match decides; otherwise an unknown one
+	// leaves the rule undecided
+	result := authzMatch
+	var conditionNotes []string
+	for _, condition := range rule.When {
+		switch authzConditionMatches(condition, request, &conditionNotes) {
+		case authzNoMatch:
+			return authzNoMatch
+		case authzIndeterminate:
+			result = authzIndeterminate
+		}
+	}
+	*notes = append(*notes, conditionNotes...)
+	return result
+}
+
+// Index of the first rule in the policy that matches, or -1, and whether a
+// rule might match depending on conditions that cannot be evaluated
+func authzPolicyMatch(policy AuthorizationPolicy, request AuthzEvaluation, notes *[]string) (int, bool) {
+	indeterminate := false
+	for i, rule := range policy.Rules {
+		switch authzRuleMatches(rule, request, notes) {
+		case authzMatch:
+			return i, false
+		case authzIndeterminate:
+			indeterminate = true
+		}
+	}
+	return -1, indeterminate
+}
+
+// Pods backing a workload or, when workload is empty, a service
+func findDestinationPods(namespace, workload, service string) ([]corev1.Pod, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	options := metav1.ListOptions{}
+	if workload == "" {
+		svc, err := clientset.CoreV1().Services(namespace).Get(context.Background(), service, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if len(svc.Spec.Selector) == 0 {
+			return nil, fmt.Errorf("service %s/%s has no selector", namespace, service)
+		}
+		options.LabelSelector = labels.SelectorFromSet(svc.Spec.Selector).String()
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), options)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []corev1.Pod
+	for _, pod := range pods.Items {
+		if workload == "" || podWorkloadName(pod) == workload {
+			matched = append(matched, pod)
+		}
+	}
+	if len(matched) == 0 {
+		name := workload
+		if name == "" {
+			name = service
+		}
+		return nil, fmt.Errorf("pods not found for %s/%s", namespace, name)
+	}
+
+	return matched, nil
+}
+
+// Fill in the source identity from its workload's pods or service account
+func resolveAuthzSource(source AuthzSource) (AuthzSource, error) {
+	switch {
+	case source.Workload != "":
+		pods, err := findDestinationPods(source.Namespace, source.Workload, "")
+		if err != nil {
+			return source, err
+		}
+		source.ServiceAccount = pods[0].Spec.ServiceAccountName
+		if source.ServiceAccount == "" {
+			source.ServiceAccount = "default"
+		}
+		source.MTLS = hasIstioSidecar(pods[0])
+	case source.ServiceAccount != "":
+		source.MTLS = true
+	default:
+		source.MTLS = false
+	}
+
+	source.Principal = ""
+	if source.MTLS {
+		source.Principal = fmt.Sprintf("%s/ns/%s/sa/%s", istioTrustDomain, source.Namespace, source.ServiceAccount)
+	}
+	return source, nil
+}
+
+func validateAuthzEvaluation(request AuthzEvaluation) []string {
+	var errors []string
+
+	if request.Destination.Namespace == "" {
+		errors = append(errors, "destination namespace is required")
+	}
+	if request.Destination.Workload == "" && request.Destination.Service == "" {
+		errors = append(errors, "destination workload or service is required")
+	}
+	if (request.Source.Workload != "" || request.Source.ServiceAccount != "") && request.Source.Namespace == "" {
+		errors = append(errors, "source namespace is required with a workload or service account")
+	}
+	if request.Source.IP != "" && net.ParseIP(request.Source.IP) == nil {
+		errors = append(errors, fmt.Sprintf("invalid source IP %q", request.Source.IP))
+	}
+
+	return errors
+}
+
+func evaluateAuthorization(request AuthzEvaluation) (*AuthzDecision, error) {
+	dst := &request.Destination
+	if dst.Method == "" {
+		dst.Method = "GET"
+	}
+	if dst.Path == "" {
+		dst.Path = "/"
+	}
+	if dst.Host == "" && dst.Service != "" {
+		dst.Host = fqdnForHost(dst.Service, dst.Namespace)
+	}
+
+	source, err := resolveAuthzSource(request.Source)
+	if err != nil {
+		return nil, err
+	}
+	request.Source = source
+
+	pods, err := findDestinationPods(dst.Namespace, dst.Workload, dst.Service)
+	if err != nil {
+		return nil, err
+	}
+	podLabels := pods[0].Labels
+
+	decision := &AuthzDecision{
+		Source:   source,
+		Policies: []AuthzPolicyResult{},
+		Notes:    []string{},
+	}
+
+	if !hasIstioSidecar(pods[0]) {
+		decision.Allowed = true
+		decision.Action = "ALLOW"
+		decision.Reason = "Destination has no Istio sidecar, so authorization policies are not enforced"
+		return decision, nil
+	}
+
+	// A plaintext client is rejected before authorization when the destination requires mTLS
+	if !source.MTLS {
+		peerAuthentications, err := getPeerAuthentications("")
+		if err != nil {
+			decision.Notes = append(decision.Notes, fmt.Sprintf("Could not check PeerAuthentication: %v", err))
+		} else if mode, policy, _ := effectiveMTLSMode(dst.Namespace, podLabels, peerAuthentications); mode == "STRICT" {
+			decision.Action = "DENY"
+			decision.Policy = policy
+			decision.Reason = "Destination requires mutual TLS and the source has no sidecar"
+			return decision, nil
+		}
+	}
+
+	policies, err := getAuthorizationPolicies("")
+	if err != nil {
+		return nil, err
+	}
+
+	var applicable []AuthorizationPolicy
+	for _, policy := range policies {
+		if policy.Namespace != dst.Namespace && policy.Namespace != istioRootNamespace {
+			continue
+		}
+		if len(policy.Selector) > 0 && !labels.SelectorFromSet(policy.Selector).Matches(labels.Set(podLabels)) {
+			continue
+		}
+		applicable = append(applicable, policy)
+	}
+
+	denied, allowed := -1, -1
+	hasAllow, denyUnknown, allowUnknown := false, false, false
+	for _, policy := range applicable {
+		result := AuthzPolicyResult{
+			Policy: policy.Namespace + "/" + policy.Name,
+			Action: policy.Action,
+		}
+		rule, indeterminate := authzPolicyMatch(policy, request, &decision.Notes)
+		if rule >= 0 {
+			result.Matched = true
+			result.Rule = &rule
+		}
+		result.Indeterminate = indeterminate
+		decision.Policies = append(decision.Policies, result)
+
+		switch policy.Action {
+		case "CUSTOM":
+			if result.Matched {
+				decision.Notes = append(decision.Notes, fmt.Sprintf("Request is also checked by external provider %q via %s", policy.Provider, result.Policy))
+			}
+		case "DENY":
+			if result.Matched && denied < 0 {
+				denied = len(decision.Policies) - 1
+			}
+			denyUnknown = denyUnknown || result.Indeterminate
+		case "ALLOW":
+			hasAllow = true
+			if result.Matched && allowed < 0 {
+				allowed = len(decision.Policies) - 1
+			}
+			allowUnknown = allowUnknown || result.Indeterminate
+		}
+	}
+
+	switch {
+	case denied >= 0:
+		decision.Action = "DENY"
+		decision.Policy = decision.Policies[denied].Policy
+		decision.Rule = decision.Policies[denied].Rule
+		decision.Reason = "Request matches a DENY policy"
+	case denyUnknown:
+		decision.Action = "UNKNOWN"
+		decision.Reason = "A DENY policy may match, depending on conditions that cannot be evaluated offline"
+	case !hasAllow:
+		decision.Allowed = true
+		decision.Action = "ALLOW"
+		decision.Reason = "No ALLOW policies apply to the destination, so requests are allowed by default"
+	case allowed >= 0:
+		decision.Allowed = true
+		decision.Action = "ALLOW"
+		decision.Policy = decision.Policies[allowed].Policy
+		decision.Rule = decision.Policies[allowed].Rule
+		decision.Reason = "Request matches an ALLOW policy"
+	case allowUnknown:
+		decision.Action = "UNKNOWN"
+		decision.Reason = "No ALLOW policy matches for certain; one may, depending on conditions that cannot be evaluated offline"
+	default:
+		decision.Action = "DENY"
+		decision.Reason = "ALLOW policies apply to the destination but none match the request"
+	}
+
+	return decision, nil
+}
+
+func registerAuthorizationPolicyRoutes(e *echo.Echo) {
+	// List AuthorizationPolicies, optionally filtered by namespace
+	e.GET("/api/istio/authorizationpolicies", func(c echo.Context) error {
+		policies, err := getAuthorizationPolicies(c.QueryParam("namespace"))
+		if err != nil {
+			log.Printf("Error listing AuthorizationPolicies: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list AuthorizationPolicies: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"authorization_policies": policies,
+			"count":                  len(policies),
+		})
+	})
+
+	// Evaluate whether a request would be allowed
+	e.POST("/api/istio/authorizationpolicies/evaluate", func(c echo.Context) error {
+		var request AuthzEvaluation
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid evaluation payload",
+			})
+		}
+
+		if errors := validateAuthzEvaluation(request); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		decision, err := evaluateAuthorization(request)
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": err.Error(),
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to evaluate authorization: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, decision)
+	})
+
+	// Validate an AuthorizationPolicy without applying it
+	e.POST("/api/istio/authorizationpolicies/validate", func(c echo.Context) error {
+		var policy AuthorizationPolicy
+		if err := c.Bind(&policy); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid AuthorizationPolicy payload",
+			})
+		}
+		normalizeAuthorizationPolicy(&policy)
+
+		if errors := dryRunAuthorizationPolicy(policy, false); len(errors) > 0 {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"valid":   true,
+			"message": "AuthorizationPolicy is valid",
+		})
+	})
+
+	// Get a single AuthorizationPolicy
+	e.GET("/api/istio/authorizationpolicies/:namespace/:name", func(c echo.Context) error {
+		policy, err := getAuthorizationPolicy(c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "AuthorizationPolicy not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get AuthorizationPolicy: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, policy)
+	})
+
+	// Create an AuthorizationPolicy
+	e.POST("/api/istio/authorizationpolicies", func(c echo.Context) error {
+		var policy AuthorizationPolicy
+		if err := c.Bind(&policy); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid AuthorizationPolicy payload",
+			})
+		}
+		normalizeAuthorizationPolicy(&policy)
+
+		if errors := dryRunAuthorizationPolicy(policy, true); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		output, err := kubectlCreate(authorizationPolicyManifest(policy), false)
+		if err != nil {
+			log.Printf("Error creating AuthorizationPolicy: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to create AuthorizationPolicy: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusCreated, map[string]interface{}{
+			"success":              true,
+			"message":              "AuthorizationPolicy created successfully",
+			"output":               output,
+			"authorization_policy": policy,
+		})
+	})
+
+	// Update an AuthorizationPolicy
+	e.PUT("/api/istio/authorizationpolicies/:namespace/:name", func(c echo.Context) error {
+		var policy AuthorizationPolicy
+		if err := c.Bind(&policy); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid AuthorizationPolicy payload",
+			})
+		}
+		policy.Namespace = c.Param("namespace")
+		policy.Name = c.Param("name")
+		normalizeAuthorizationPolicy(&policy)
+
+		if _, err := getAuthorizationPolicy(policy.Namespace, policy.Name); err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "AuthorizationPolicy not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get AuthorizationPolicy: %v", err),
+			})
+		}
+
+		if errors := dryRunAuthorizationPolicy(policy, false); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		output, err := kubectlApply(authorizationPolicyManifest(policy), false)
+		if err != nil {
+			log.Printf("Error updating AuthorizationPolicy: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to update AuthorizationPolicy: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success":              true,
+			"message":              "AuthorizationPolicy updated successfully",
+			"output":               output,
+			"authorization_policy": policy,
+		})
+	})
+
+	// Delete an AuthorizationPolicy
+	e.DELETE("/api/istio/authorizationpolicies/:namespace/:name", func(c echo.Context) error {
+		output, err := kubectlDeleteResource("authorizationpolicies.security.istio.io", c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "AuthorizationPolicy not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to delete AuthorizationPolicy: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": output,
+		})
+	})
+}
@@ -0,0 +1,658 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type DestinationRule struct {
+	Name          string         `json:"name"`
+	Namespace     string         `json:"namespace"`
+	Host          string         `json:"host"`
+	ExportTo      []string       `json:"export_to,omitempty"`
+	TrafficPolicy *TrafficPolicy `json:"traffic_policy,omitempty"`
+	Subsets       []Subset       `json:"subsets"`
+
+	// Spec fields not modelled above, such as workloadSelector, carried over
+	// from the live resource on update
+	extra map[string]json.RawMessage
+}
+
+// DestinationRule policy types, following the Istio CRD schema. Subsets and
+// traffic policies decoded from JSON keep the original document and encode
+// back to it unchanged, like HTTPRoute, so fields the structs do not model
+// (portLevelSettings, localityLbSetting, tls.caCertificates, ...) survive a
+// GET/PUT round trip.
+
+type Subset struct {
+	Name          string            `json:"name"`
+	Labels        map[string]string `json:"labels"`
+	TrafficPolicy *TrafficPolicy    `json:"trafficPolicy,omitempty"`
+
+	raw json.RawMessage
+}
+
+// subsetFields has the fields of Subset without its JSON methods
+type subsetFields Subset
+
+func (s *Subset) UnmarshalJSON(data []byte) error {
+	var fields subsetFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*s = Subset(fields)
+	s.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (s Subset) MarshalJSON() ([]byte, error) {
+	if s.raw != nil {
+		return s.raw, nil
+	}
+	return json.Marshal(subsetFields(s))
+}
+
+// Replace the labels of a subset, keeping its other fields
+func (s *Subset) setLabels(labels map[string]string) error {
+	s.Labels = labels
+	if s.raw == nil {
+		return nil
+	}
+	extra, err := unmodelledFields(s.raw, "labels")
+	if err != nil {
+		return err
+	}
+	raw, err := withUnmodelledFields(map[string]interface{}{"labels": labels}, extra)
+	if err != nil {
+		return err
+	}
+	s.raw = raw
+	return nil
+}
+
+type TrafficPolicy struct {
+	LoadBalancer     *LoadBalancerSettings   `json:"loadBalancer,omitempty"`
+	ConnectionPool   *ConnectionPoolSettings `json:"connectionPool,omitempty"`
+	OutlierDetection *OutlierDetection       `json:"outlierDetection,omitempty"`
+	TLS              *ClientTLSSettings      `json:"tls,omitempty"`
+
+	raw json.RawMessage
+}
+
+// trafficPolicyFields has the fields of TrafficPolicy without its JSON methods
+type trafficPolicyFields TrafficPolicy
+
+func (p *TrafficPolicy) UnmarshalJSON(data []byte) error {
+	var fields trafficPolicyFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*p = TrafficPolicy(fields)
+	p.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (p TrafficPolicy) MarshalJSON() ([]byte, error) {
+	if p.raw != nil {
+		return p.raw, nil
+	}
+	return json.Marshal(trafficPolicyFields(p))
+}
+
+type LoadBalancerSettings struct {
+	Simple         string            `json:"simple,omitempty"`
+	ConsistentHash *ConsistentHashLB `json:"consistentHash,omitempty"`
+}
+
+type ConsistentHashLB struct {
+	HTTPHeaderName         string `json:"httpHeaderName,omitempty"`
+	HTTPQueryParameterName string `json:"httpQueryParameterName,omitempty"`
+	UseSourceIP            bool   `json:"useSourceIp,omitempty"`
+	MinimumRingSize        uint64 `json:"minimumRingSize,omitempty"`
+}
+
+type ConnectionPoolSettings struct {
+	TCP  *TCPSettings  `json:"tcp,omitempty"`
+	HTTP *HTTPSettings `json:"http,omitempty"`
+}
+
+type TCPSettings struct {
+	MaxConnections int32  `json:"maxConnections,omitempty"`
+	ConnectTimeout string `json:"connectTimeout,omitempty"`
+}
+
+type HTTPSettings struct {
+	HTTP1MaxPendingRequests  int32  `json:"http1MaxPendingRequests,omitempty"`
+	HTTP2MaxRequests         int32  `json:"http2MaxRequests,omitempty"`
+	MaxRequestsPerConnection int32  `json:"maxRequestsPerConnection,omitempty"`
+	MaxRetries               int32  `json:"maxRetries,omitempty"`
+	IdleTimeout              string `json:"idleTimeout,omitempty"`
+}
+
+type OutlierDetection struct {
+	Consecutive5xxErrors     *uint32 `json:"consecutive5xxErrors,omitempty"`
+	ConsecutiveGatewayErrors *uint32 `json:"consecutiveGatewayErrors,omitempty"`
+	Interval                 string  `json:"interval,omitempty"`
+	BaseEjectionTime         string  `json:"baseEjectionTime,omitempty"`
+	MaxEjectionPercent       int32   `json:"maxEjectionPercent,omitempty"`
+	MinHealthPercent         int32   `json:"minHealthPercent,omitempty"`
+}
+
+type ClientTLSSettings struct {
+	Mode           string `json:"mode"`
+	CredentialName string `json:"credentialName,omitempty"`
+	SNI            string `json:"sni,omitempty"`
+}
+
+// SubsetIssue flags a VirtualService route pointing at a subset that no
+// DestinationRule defines. Envoy answers such routes with 503 NR.
+type SubsetIssue struct {
+	VirtualService string `json:"virtual_service"`
+	Namespace      string `json:"namespace"`
+	Route          string `json:"route"`
+	Host           string `json:"host"`
+	Subset         string `json:"subset"`
+	Message        string `json:"message"`
+}
+
+type destinationRuleSpec struct {
+	Host          string         `json:"host"`
+	ExportTo      []string       `json:"exportTo,omitempty"`
+	TrafficPolicy *TrafficPolicy `json:"trafficPolicy,omitempty"`
+	Subsets       []Subset       `json:"subsets,omitempty"`
+}
+
+var validLoadBalancers = map[string]bool{
+	"UNSPECIFIED":   true,
+	"ROUND_ROBIN":   true,
+	"LEAST_REQUEST": true,
+	"LEAST_CONN":    true,
+	"RANDOM":        true,
+	"PASSTHROUGH":   true,
+}
+
+// Get Destination Rules using kubectl, across all namespaces when namespace is empty
+func getDestinationRules(namespace string) ([]DestinationRule, error) {
+	items, err := kubectlListResources("destinationrules.networking.istio.io", namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	destinationRules := []DestinationRule{}
+	for _, item := range items {
+		dr, err := destinationRuleFromResource(item)
+		if err != nil {
+			log.Printf("Skipping DestinationRule %s/%s: %v", item.Metadata.Namespace, item.Metadata.Name, err)
+			continue
+		}
+		destinationRules = append(destinationRules, *dr)
+	}
+
+	return destinationRules, nil
+}
+
+func getDestinationRule(namespace, name string) (*DestinationRule, error) {
+	resource, err := kubectlGetResource("destinationrules.networking.istio.io", namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return destinationRuleFromResource(*resource)
+}
+
+func destinationRuleFromResource(resource k8sRawResource) (*DestinationRule, error) {
+	var spec destinationRuleSpec
+	if err := json.Unmarshal(resource.Spec, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %v", err)
+	}
+	extra, err := unmodelledFields(resource.Spec, "host", "exportTo", "trafficPolicy", "subsets")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %v", err)
+	}
+
+	subsets := spec.Subsets
+	if subsets == nil {
+		subsets = []Subset{}
+	}
+
+	return &DestinationRule{
+		Name:          resource.Metadata.Name,
+		Namespace:     resource.Metadata.Namespace,
+		Host:          spec.Host,
+		ExportTo:      spec.ExportTo,
+		TrafficPolicy: spec.TrafficPolicy,
+		Subsets:       subsets,
+		extra:         extra,
+	}, nil
+}
+
+func destinationRuleManifest(dr DestinationRule) k8sResource {
+	var spec interface{} = destinationRuleSpec{
+		Host:          dr.Host,
+		ExportTo:      dr.ExportTo,
+		TrafficPolicy: dr.TrafficPolicy,
+		Subsets:       dr.Subsets,
+	}
+	if len(dr.extra) > 0 {
+		if merged, err := withUnmodelledFields(spec, dr.extra); err == nil {
+			spec = merged
+		}
+	}
+
+	return k8sResource{
+		APIVersion: "networking.istio.io/v1beta1",
+		Kind:       "DestinationRule",
+		Metadata: k8sObjectMeta{
+			Name:      dr.Name,
+			Namespace: dr.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: spec,
+	}
+}
+
+func validateDestinationRule(dr DestinationRule) []string {
+	var errors []string
+
+	if dr.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	if dr.Host == "" {
+		errors = append(errors, "host is required")
+	}
+
+	errors = append(errors, validateTrafficPolicy("traffic_policy", dr.TrafficPolicy)...)
+
+	seen := make(map[string]bool)
+	for i, subset := range dr.Subsets {
+		field := fmt.Sprintf("subsets[%d]", i)
+		if subset.Name == "" {
+			errors = append(errors, fmt.Sprintf("%s: name is required", field))
+		} else if seen[subset.Name] {
+			errors = append(errors, fmt.Sprintf("%s: duplicate subset name %q", field, subset.Name))
+		}
+		seen[subset.Name] = true
+
+		// A subset without labels selects every endpoint of the host, which
+		// Istio accepts and egress gateway rules rely on
+		errors = append(errors, validateTrafficPolicy(field+".trafficPolicy", subset.TrafficPolicy)...)
+	}
+
+	return errors
+}
+
+func validateTrafficPolicy(field string, policy *TrafficPolicy) []string {
+	if policy == nil {
+		return nil
+	}
+
+	var errors []string
+	checkDuration := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			errors = append(errors, fmt.Sprintf("%s.%s: invalid duration %q", field, name, value))
+		}
+	}
+
+	if lb := policy.LoadBalancer; lb != nil {
+		if lb.Simple != "" && lb.ConsistentHash != nil {
+			errors = append(errors, fmt.Sprintf("%s.loadBalancer: simple and consistentHash are mutually exclusive", field))
+		}
+		if lb.Simple != "" && !validLoadBalancers[lb.Simple] {
+			errors = append(errors, fmt.Sprintf("%s.loadBalancer.simple: unknown policy %q", field, lb.Simple))
+		}
+	}
+
+	if pool := policy.ConnectionPool; pool != nil {
+		if pool.TCP != nil {
+			checkDuration("connectionPool.tcp.connectTimeout", pool.TCP.ConnectTimeout)
+		}
+		if pool.HTTP != nil {
+			checkDuration("connectionPool.http.idleTimeout", pool.HTTP.IdleTimeout)
+		}
+	}
+
+	if od := policy.OutlierDetection; od != nil {
+		checkDuration("outlierDetection.interval", od.Interval)
+		checkDuration("outlierDetection.baseEjectionTime", od.BaseEjectionTime)
+		if od.MaxEjectionPercent < 0 || od.MaxEjectionPercent > 100 {
+			errors = append(errors, fmt.Sprintf("%s.outlierDetection.maxEjectionPercent must be between 0 and 100", field))
+		}
+		if od.MinHealthPercent < 0 || od.MinHealthPercent > 100 {
+			errors = append(errors, fmt.Sprintf("%s.outlierDetection.minHealthPercent must be between 0 and 100", field))
+		}
+	}
+
+	return errors
+}
+
+func dryRunDestinationRule(dr DestinationRule, create bool) []string {
+	if errors := validateDestinationRule(dr); len(errors) > 0 {
+		return errors
+	}
+	if err := kubectlDryRun(destinationRuleManifest(dr), create); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// Derive subsets for a service from the version labels of the pods it selects
+func deriveSubsets(namespace, service, versionLabel string) ([]Subset, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := clientset.CoreV1().Services(namespace).Get(context.Background(), service, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(svc.Spec.Selector) == 0 {
+		return nil, fmt.Errorf("service %s/%s has no pod selector", namespace, service)
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]bool)
+	for _, pod := range pods.Items {
+		if version := pod.Labels[versionLabel]; version != "" {
+			versions[version] = true
+		}
+	}
+
+	subsets := []Subset{}
+	for version := range versions {
+		subsets = append(subsets, Subset{
+			Name:   version,
+			Labels: map[string]string{versionLabel: version},
+		})
+	}
+	sort.Slice(subsets, func(i, j int) bool { return subsets[i].Name < subsets[j].Name })
+
+	return subsets, nil
+}
+
+// Expand a short service name the way Istio does, relative to the namespace
+// of the resource it appears in
+func fqdnForHost(host, namespace string) string {
+	if host == "" || strings.Contains(host, ".") || strings.Contains(host, "*") {
+		return host
+	}
+	return fmt.Sprintf("%s.%s.svc.cluster.local", host, namespace)
+}
+
+// Find VirtualService routes that reference subsets no DestinationRule defines
+func findSubsetIssues(virtualServices []VirtualService, destinationRules []DestinationRule) []SubsetIssue {
+	defined := make(map[string]map[string]bool)
+	for _, dr := range destinationRules {
+		host := fqdnForHost(dr.Host, dr.Namespace)
+		if defined[host] == nil {
+			defined[host] = make(map[string]bool)
+		}
+		for _, subset := range dr.Subsets {
+			defined[host][subset.Name] = true
+		}
+	}
+
+	issues := []SubsetIssue{}
+	check := func(vs VirtualService, route string, dest Destination) {
+		if dest.Subset == "" {
+			return
+		}
+		host := fqdnForHost(dest.Host, vs.Namespace)
+		subsets, hasRule := defined[host]
+		if hasRule && subsets[dest.Subset] {
+			return
+		}
+
+		message := fmt.Sprintf("subset %q is not defined in the DestinationRule for %s", dest.Subset, host)
+		if !hasRule {
+			message = fmt.Sprintf("no DestinationRule defines subsets for %s", host)
+		}
+		issues = append(issues, SubsetIssue{
+			VirtualService: vs.Name,
+			Namespace:      vs.Namespace,
+			Route:          route,
+			Host:           dest.Host,
+			Subset:         dest.Subset,
+			Message:        message,
+		})
+	}
+
+	for _, vs := range virtualServices {
+		for i, route := range vs.HTTP {
+			for j, dest := range route.Route {
+				check(vs, fmt.Sprintf("http[%d].route[%d]", i, j), dest.Destination)
+			}
+			if route.Mirror != nil {
+				check(vs, fmt.Sprintf("http[%d].mirror", i), *route.Mirror)
+			}
+		}
+	}
+
+	return issues
+}
+
+func getSubsetIssues() ([]SubsetIssue, error) {
+	virtualServices, err := getVirtualServices("")
+	if err != nil {
+		return nil, err
+	}
+	destinationRules, err := getDestinationRules("")
+	if err != nil {
+		return nil, err
+	}
+	return findSubsetIssues(virtualServices, destinationRules), nil
+}
+
+func registerDestinationRuleRoutes(e *echo.Echo) {
+	// List DestinationRules, optionally filtered by namespace
+	e.GET("/api/istio/destinationrules", func(c echo.Context) error {
+		destinationRules, err := getDestinationRules(c.QueryParam("namespace"))
+		if err != nil {
+			log.Printf("Error listing DestinationRules: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list DestinationRules: %v", err),
+			})
+		}
+
+		issues, err := getSubsetIssues()
+		if err != nil {
+			log.Printf("Warning: Could not check subset references: %v", err)
+			issues = []SubsetIssue{}
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"destination_rules": destinationRules,
+			"subset_issues":     issues,
+			"count":             len(destinationRules),
+		})
+	})
+
+	// VirtualService routes referencing undefined subsets
+	e.GET("/api/istio/destinationrules/subset-issues", func(c echo.Context) error {
+		issues, err := getSubsetIssues()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to check subset references: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"issues": issues,
+			"count":  len(issues),
+		})
+	})
+
+	// Suggest subsets for a service from its pods' version labels
+	e.GET("/api/istio/services/:namespace/:service/subsets", func(c echo.Context) error {
+		versionLabel := c.QueryParam("label")
+		if versionLabel == "" {
+			versionLabel = "version"
+		}
+
+		subsets, err := deriveSubsets(c.Param("namespace"), c.Param("service"), versionLabel)
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "Service not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to derive subsets: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"subsets": subsets,
+			"count":   len(subsets),
+		})
+	})
+
+	// Get a single DestinationRule
+	e.GET("/api/istio/destinationrules/:namespace/:name", func(c echo.Context) error {
+		dr, err := getDestinationRule(c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "DestinationRule not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get DestinationRule: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, dr)
+	})
+
+	// Create a DestinationRule. With derive_subsets=true and no subsets in the
+	// payload, subsets are generated from the host's pod version labels.
+	e.POST("/api/istio/destinationrules", func(c echo.Context) error {
+		var dr DestinationRule
+		if err := c.Bind(&dr); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid DestinationRule payload",
+			})
+		}
+		if dr.Namespace == "" {
+			dr.Namespace = "default"
+		}
+
+		if c.QueryParam("derive_subsets") == "true" && len(dr.Subsets) == 0 {
+			service := strings.Split(dr.Host, ".")[0]
+			subsets, err := deriveSubsets(dr.Namespace, service, "version")
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{
+					"error": fmt.Sprintf("Failed to derive subsets: %v", err),
+				})
+			}
+			dr.Subsets = subsets
+		}
+
+		if errors := dryRunDestinationRule(dr, true); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		output, err := kubectlCreate(destinationRuleManifest(dr), false)
+		if err != nil {
+			log.Printf("Error creating DestinationRule: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to create DestinationRule: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusCreated, map[string]interface{}{
+			"success":          true,
+			"message":          "DestinationRule created successfully",
+			"output":           output,
+			"destination_rule": dr,
+		})
+	})
+
+	// Update a DestinationRule
+	e.PUT("/api/istio/destinationrules/:namespace/:name", func(c echo.Context) error {
+		var dr DestinationRule
+		if err := c.Bind(&dr); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid DestinationRule payload",
+			})
+		}
+		dr.Namespace = c.Param("namespace")
+		dr.Name = c.Param("name")
+
+		live, err := getDestinationRule(dr.Namespace, dr.Name)
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "DestinationRule not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get DestinationRule: %v", err),
+			})
+		}
+		dr.extra = live.extra
+
+		if errors := dryRunDestinationRule(dr, false); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		output, err := kubectlApply(destinationRuleManifest(dr), false)
+		if err != nil {
+			log.Printf("Error updating DestinationRule: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to update DestinationRule: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success":          true,
+			"message":          "DestinationRule updated successfully",
+			"output":           output,
+			"destination_rule": dr,
+		})
+	})
+
+	// Delete a DestinationRule
+	e.DELETE("/api/istio/destinationrules/:namespace/:name", func(c echo.Context) error {
+		output, err := kubectlDeleteResource("destinationrules.networking.istio.io", c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "DestinationRule not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to delete DestinationRule: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": output,
+		})
+	})
+}
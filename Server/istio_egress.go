@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Egress gateway wizard. Routes traffic for one external host from the
+// sidecars through the egress gateway, following the Istio egress gateway
+// task: a ServiceEntry for the host, a Gateway on the egress workload, a
+// DestinationRule subset for the gateway, and a VirtualService with one route
+// from the mesh to the gateway and one from the gateway to the host.
+
+type EgressRequest struct {
+	Host            string            `json:"host"`
+	Port            uint32            `json:"port"`
+	Protocol        string            `json:"protocol"`
+	Namespace       string            `json:"namespace"`
+	GatewaySelector map[string]string `json:"gateway_selector,omitempty"`
+}
+
+type EgressResources struct {
+	Name            string          `json:"name"`
+	Namespace       string          `json:"namespace"`
+	EgressGateway   string          `json:"egress_gateway"`
+	ServiceEntry    ServiceEntry    `json:"service_entry"`
+	Gateway         Gateway         `json:"gateway"`
+	DestinationRule DestinationRule `json:"destination_rule"`
+	VirtualService  VirtualService  `json:"virtual_service"`
+}
+
+var defaultEgressGatewaySelector = map[string]string{"istio": "egressgateway"}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func egressResourceName(host string) string {
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(host), "-"), "-")
+	if len(name) > 56 {
+		name = strings.TrimRight(name[:56], "-")
+	}
+	return name + "-egress"
+}
+
+// FQDN of the Service in front of the egress gateway pods
+func egressGatewayServiceHost(selector map[string]string) (string, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return "", err
+	}
+
+	pods, err := gatewayWorkloadPods(selector)
+	if err != nil {
+		return "", err
+	}
+	if len(pods) == 0 {
+		return "", fmt.Errorf("no egress gateway pods match %s; install Istio with egress gateways enabled", labels.SelectorFromSet(selector).String())
+	}
+
+	for _, namespace := range podNamespaces(pods) {
+		services, err := clientset.CoreV1().Services(namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return "", err
+		}
+		for _, svc := range services.Items {
+			if len(svc.Spec.Selector) == 0 {
+				continue
+			}
+			for _, pod := range pods {
+				if pod.Namespace == namespace && labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+					return fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace), nil
+				}
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no Service selects the egress gateway pods")
+}
+
+func validateEgressRequest(request EgressRequest) []string {
+	var errors []string
+
+	if request.Host == "" {
+		errors = append(errors, "host is required")
+	} else if strings.Contains(request.Host, "*") {
+		errors = append(errors, "host must not be a wildcard")
+	}
+	if request.Port < 1 || request.Port > 65535 {
+		errors = append(errors, "port must be between 1 and 65535")
+	}
+	if request.Protocol != "TLS" && request.Protocol != "HTTP" {
+		errors = append(errors, fmt.Sprintf("unsupported protocol %q (use TLS or HTTP)", request.Protocol))
+	}
+
+	return errors
+}
+
+// Build the four resources for an external host. TLS traffic passes through
+// the gateway untouched and is routed on SNI; HTTP is routed on port.
+func buildEgressResources(request EgressRequest, egressHost string) EgressResources {
+	name := egressResourceName(request.Host)
+	portName := fmt.Sprintf("%s-%d", strings.ToLower(request.Protocol), request.Port)
+	subset := "egress"
+
+	resources := EgressResources{
+		Name:          name,
+		Namespace:     request.Namespace,
+		EgressGateway: egressHost,
+		ServiceEntry: ServiceEntry{
+			Name:       name,
+			Namespace:  request.Namespace,
+			Hosts:      []string{request.Host},
+			Ports:      []ServiceEntryPort{{Number: request.Port, Protocol: request.Protocol, Name: portName}},
+			Location:   "MESH_EXTERNAL",
+			Resolution: "DNS",
+		},
+		Gateway: Gateway{
+			Name:      name,
+			Namespace: request.Namespace,
+			Selector:  request.GatewaySelector,
+			Servers: []GatewayServer{{
+				Port:  GatewayPort{Number: int32(request.Port), Protocol: request.Protocol, Name: portName},
+				Hosts: []string{request.Host},
+			}},
+		},
+		DestinationRule: DestinationRule{
+			Name:      name,
+			Namespace: request.Namespace,
+			Host:      egressHost,
+			Subsets:   []Subset{{Name: subset}},
+		},
+		VirtualService: VirtualService{
+			Name:      name,
+			Namespace: request.Namespace,
+			Hosts:     []string{request.Host},
+			Gateways:  []string{"mesh", name},
+		},
+	}
+	summarizeGateway(&resources.Gateway)
+
+	toGateway := Destination{Host: egressHost, Subset: subset, Port: &PortSelector{Number: request.Port}}
+	toHost := Destination{Host: request.Host, Port: &PortSelector{Number: request.Port}}
+
+	if request.Protocol == "TLS" {
+		resources.Gateway.Servers[0].TLS = &ServerTLSSettings{Mode: "PASSTHROUGH"}
+		tlsRoute := func(gateway string, dest Destination) map[string]interface{} {
+			return map[string]interface{}{
+				"match": []map[string]interface{}{{
+					"gateways": []string{gateway},
+					"port":     request.Port,
+					"sniHosts": []string{request.Host},
+				}},
+				"route": []HTTPRouteDestination{{Destination: dest, Weight: 100}},
+			}
+		}
+		resources.VirtualService.TLS = []map[string]interface{}{
+			tlsRoute("mesh", toGateway),
+			tlsRoute(name, toHost),
+		}
+	} else {
+		resources.VirtualService.HTTP = []HTTPRoute{
+			{
+				Name:  "mesh-to-egress-gateway",
+				Match: []HTTPMatchRequest{{Gateways: []string{"mesh"}, Port: request.Port}},
+				Route: []HTTPRouteDestination{{Destination: toGateway, Weight: 100}},
+			},
+			{
+				Name:  "egress-gateway-to-external",
+				Match: []HTTPMatchRequest{{Gateways: []string{name}, Port: request.Port}},
+				Route: []HTTPRouteDestination{{Destination: toHost, Weight: 100}},
+			},
+		}
+	}
+
+	return resources
+}
+
+func (r EgressResources) manifests() []k8sResource {
+	return []k8sResource{
+		serviceEntryManifest(r.ServiceEntry),
+		gatewayManifest(r.Gateway),
+		destinationRuleManifest(r.DestinationRule),
+		virtualServiceManifest(r.VirtualService),
+	}
+}
+
+// Check the resources locally, without the API server
+func (r EgressResources) validateLocal() []string {
+	var errors []string
+	errors = append(errors, validateServiceEntry(r.ServiceEntry)...)
+	errors = append(errors, validateGateway(r.Gateway)...)
+	errors = append(errors, validateDestinationRule(r.DestinationRule)...)
+	errors = append(errors, validateVirtualService(r.VirtualService)...)
+	return errors
+}
+
+func (r EgressResources) validate() []string {
+	errors := r.validateLocal()
+	if len(errors) > 0 {
+		return errors
+	}
+
+	for _, manifest := range r.manifests() {
+		if err := kubectlDryRun(manifest, true); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", manifest.Kind, err))
+		}
+	}
+	return errors
+}
+
+// Create all resources, deleting the ones already created if a later one fails
+func createEgressResources(resources EgressResources) ([]string, error) {
+	var outputs []string
+	var created []k8sResource
+
+	for _, manifest := range resources.manifests() {
+		output, err := kubectlCreate(manifest, false)
+		if err != nil {
+			for i := len(created) - 1; i >= 0; i-- {
+				if _, delErr := kubectlDeleteResource(created[i].Kind, created[i].Metadata.Namespace, created[i].Metadata.Name); delErr != nil {
+					log.Printf("Failed to roll back %s %s/%s: %v", created[i].Kind, created[i].Metadata.Namespace, created[i].Metadata.Name, delErr)
+				}
+			}
+			return outputs, fmt.Errorf("failed to create %s: %v", manifest.Kind, err)
+		}
+		outputs = append(outputs, output)
+		created = append(created, manifest)
+	}
+
+	return outputs, nil
+}
+
+func registerEgressRoutes(e *echo.Echo) {
+	// Preview or create the egress gateway resources for an external host
+	e.POST("/api/istio/egress", func(c echo.Context) error {
+		var request EgressRequest
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid egress payload",
+			})
+		}
+		request.Protocol = strings.ToUpper(request.Protocol)
+		if request.Protocol == "" {
+			request.Protocol = "TLS"
+		}
+		if request.Port == 0 {
+			request.Port = 443
+			if request.Protocol == "HTTP" {
+				request.Port = 80
+			}
+		}
+		if request.Namespace == "" {
+			request.Namespace = istioRootNamespace
+		}
+		if len(request.GatewaySelector) == 0 {
+			request.GatewaySelector = defaultEgressGatewaySelector
+		}
+
+		if errors := validateEgressRequest(request); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		egressHost, err := egressGatewayServiceHost(request.GatewaySelector)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("Egress gateway not available: %v", err),
+			})
+		}
+
+		resources := buildEgressResources(request, egressHost)
+		if errors := resources.validate(); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":     false,
+				"errors":    errors,
+				"resources": resources,
+			})
+		}
+
+		if c.QueryParam("dry_run") == "true" {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"valid":     true,
+				"message":   "Egress resources are valid",
+				"resources": resources,
+			})
+		}
+
+		outputs, err := createEgressResources(resources)
+		if err != nil {
+			log.Printf("Error creating egress resources for %s: %v", request.Host, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		policy, err := getOutboundTrafficPolicy()
+		if err != nil {
+			log.Printf("Warning: Could not read outbound traffic policy: %v", err)
+		}
+
+		return c.JSON(http.StatusCreated, map[string]interface{}{
+			"success":                 true,
+			"message":                 fmt.Sprintf("Traffic to %s now leaves the mesh through %s", request.Host, egressHost),
+			"output":                  strings.Join(outputs, "\n"),
+			"resources":               resources,
+			"outbound_traffic_policy": policy,
+		})
+	})
+
+	// Remove the resources created by the wizard for a host. Resources that
+	// share the name but were not created by Meshify are left in place and
+	// reported as skipped.
+	e.DELETE("/api/istio/egress/:namespace/:name", func(c echo.Context) error {
+		namespace, name := c.Param("namespace"), c.Param("name")
+
+		var outputs []string
+		var errors []string
+		skipped := []string{}
+		for _, kind := range []string{
+			"virtualservices.networking.istio.io",
+			"destinationrules.networking.istio.io",
+			"gateways.networking.istio.io",
+			"serviceentries.networking.istio.io",
+		} {
+			resource, err := kubectlGetResource(kind, namespace, name)
+			if err != nil {
+				if !isNotFoundError(err) {
+					errors = append(errors, fmt.Sprintf("%s: %v", kind, err))
+				}
+				continue
+			}
+			if resource.Metadata.Labels["app.kubernetes.io/managed-by"] != "meshify" {
+				skipped = append(skipped, fmt.Sprintf("%s %s/%s is not managed by Meshify", resource.Kind, namespace, name))
+				continue
+			}
+
+			output, err := kubectlDeleteResource(kind, namespace, name)
+			if err != nil {
+				if !isNotFoundError(err) {
+					errors = append(errors, fmt.Sprintf("%s: %v", kind, err))
+				}
+				continue
+			}
+			outputs = append(outputs, output)
+		}
+
+		if len(errors) > 0 {
+			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+				"error":   "Failed to delete some egress resources",
+				"errors":  errors,
+				"skipped": skipped,
+			})
+		}
+		if len(outputs) == 0 && len(skipped) > 0 {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":   "No Meshify-managed egress resources with this name",
+				"skipped": skipped,
+			})
+		}
+		if len(outputs) == 0 {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Egress resources not found",
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": strings.Join(outputs, "\n"),
+			"skipped": skipped,
+		})
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+// The wizard's resources must pass the same checks the create route runs;
+// the server-side dry run that follows needs a cluster and is not covered
+func TestBuildEgressResourcesValidate(t *testing.T) {
+	for _, protocol := range []string{"TLS", "HTTP"} {
+		request := EgressRequest{
+			Host:            "api.example.com",
+			Port:            443,
+			Protocol:        protocol,
+			Namespace:       "default",
+			GatewaySelector: defaultEgressGatewaySelector,
+		}
+		if protocol == "HTTP" {
+			request.Port = 80
+		}
+		if errors := validateEgressRequest(request); len(errors) > 0 {
+			t.Fatalf("%s: request rejected: %v", protocol, errors)
+		}
+
+		resources := buildEgressResources(request, "istio-egressgateway.istio-system.svc.cluster.local")
+		if errors := resources.validateLocal(); len(errors) > 0 {
+			t.Errorf("%s: built resources fail validation: %v", protocol, errors)
+		}
+	}
+}
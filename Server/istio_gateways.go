@@ -0,0 +1,860 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Server types follow the Istio Gateway CRD field names. Servers and their
+// TLS settings keep the fields they do not model (defaultEndpoint,
+// cipherSuites, verifyCertificateSpki, ...) when decoded and write them back
+// out, so a GET/PUT round trip does not strip them while edits to the
+// modelled fields still apply.
+
+type GatewayServer struct {
+	Name  string             `json:"name,omitempty"`
+	Port  GatewayPort        `json:"port"`
+	Bind  string             `json:"bind,omitempty"`
+	Hosts []string           `json:"hosts"`
+	TLS   *ServerTLSSettings `json:"tls,omitempty"`
+
+	extra map[string]json.RawMessage
+}
+
+// gatewayServerFields has the fields of GatewayServer without its JSON methods
+type gatewayServerFields GatewayServer
+
+func (s *GatewayServer) UnmarshalJSON(data []byte) error {
+	var fields gatewayServerFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	extra, err := unmodelledFields(data, "name", "port", "bind", "hosts", "tls")
+	if err != nil {
+		return err
+	}
+	*s = GatewayServer(fields)
+	s.extra = extra
+	return nil
+}
+
+func (s GatewayServer) MarshalJSON() ([]byte, error) {
+	return withUnmodelledFields(gatewayServerFields(s), s.extra)
+}
+
+type GatewayPort struct {
+	Number   int32  `json:"number"`
+	Protocol string `json:"protocol"`
+	Name     string `json:"name"`
+}
+
+type ServerTLSSettings struct {
+	HTTPSRedirect      bool     `json:"httpsRedirect,omitempty"`
+	Mode               string   `json:"mode,omitempty"`
+	CredentialName     string   `json:"credentialName,omitempty"`
+	SubjectAltNames    []string `json:"subjectAltNames,omitempty"`
+	MinProtocolVersion string   `json:"minProtocolVersion,omitempty"`
+	MaxProtocolVersion string   `json:"maxProtocolVersion,omitempty"`
+
+	extra map[string]json.RawMessage
+}
+
+// serverTLSFields has the fields of ServerTLSSettings without its JSON methods
+type serverTLSFields ServerTLSSettings
+
+func (t *ServerTLSSettings) UnmarshalJSON(data []byte) error {
+	var fields serverTLSFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	extra, err := unmodelledFields(data, "httpsRedirect", "mode", "credentialName", "subjectAltNames", "minProtocolVersion", "maxProtocolVersion")
+	if err != nil {
+		return err
+	}
+	*t = ServerTLSSettings(fields)
+	t.extra = extra
+	return nil
+}
+
+func (t ServerTLSSettings) MarshalJSON() ([]byte, error) {
+	return withUnmodelledFields(serverTLSFields(t), t.extra)
+}
+
+type gatewaySpec struct {
+	Selector map[string]string `json:"selector,omitempty"`
+	Servers  []GatewayServer   `json:"servers"`
+}
+
+// TLS Secret usable as a Gateway credentialName
+type TLSSecret struct {
+	Name      string     `json:"name"`
+	Namespace string     `json:"namespace"`
+	Type      string     `json:"type"`
+	DNSNames  []string   `json:"dns_names,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+	HasCACert bool       `json:"has_ca_cert"`
+}
+
+// VirtualService host that a bound Gateway does not serve, or a binding to a
+// Gateway that does not exist
+type GatewayHostIssue struct {
+	VirtualService string `json:"virtual_service"`
+	Namespace      string `json:"namespace"`
+	Gateway        string `json:"gateway"`
+	Host           string `json:"host,omitempty"`
+	Message        string `json:"message"`
+}
+
+var validGatewayProtocols = map[string]bool{
+	"HTTP":  true,
+	"HTTPS": true,
+	"HTTP2": true,
+	"GRPC":  true,
+	"TCP":   true,
+	"TLS":   true,
+	"MONGO": true,
+}
+
+var validServerTLSModes = map[string]bool{
+	"PASSTHROUGH":      true,
+	"SIMPLE":           true,
+	"MUTUAL":           true,
+	"AUTO_PASSTHROUGH": true,
+	"ISTIO_MUTUAL":     true,
+	"OPTIONAL_MUTUAL":  true,
+}
+
+var defaultGatewaySelector = map[string]string{"istio": "ingressgateway"}
+
+func getGateways(namespace string) ([]Gateway, error) {
+	items, err := kubectlListResources("gateways.networking.istio.io", namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	gateways := []Gateway{}
+	for _, item := range items {
+		gw, err := gatewayFromResource(item)
+		if err != nil {
+			log.Printf("Skipping Gateway %s/%s: %v", item.Metadata.Namespace, item.Metadata.Name, err)
+			continue
+		}
+		gateways = append(gateways, *gw)
+	}
+
+	return gateways, nil
+}
+
+func getGateway(namespace, name string) (*Gateway, error) {
+	resource, err := kubectlGetResource("gateways.networking.istio.io", namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return gatewayFromResource(*resource)
+}
+
+func gatewayFromResource(resource k8sRawResource) (*Gateway, error) {
+	var spec gatewaySpec
+	if err := json.Unmarshal(resource.Spec, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %v", err)
+	}
+
+	gw := &Gateway{
+		Name:      resource.Metadata.Name,
+		Namespace: resource.Metadata.Namespace,
+		Selector:  spec.Selector,
+		Servers:   spec.Servers,
+	}
+	summarizeGateway(gw)
+	return gw, nil
+}
+
+// Fill the flat hosts/port fields from the servers
+func summarizeGateway(gw *Gateway) {
+	if gw.Servers == nil {
+		gw.Servers = []GatewayServer{}
+	}
+
+	gw.Hosts = []string{}
+	gw.Port = 0
+	seen := make(map[string]bool)
+	for _, server := range gw.Servers {
+		if gw.Port == 0 {
+			gw.Port = server.Port.Number
+		}
+		for _, host := range server.Hosts {
+			if !seen[host] {
+				seen[host] = true
+				gw.Hosts = append(gw.Hosts, host)
+			}
+		}
+	}
+}
+
+func gatewayManifest(gw Gateway) k8sResource {
+	return k8sResource{
+		APIVersion: "networking.istio.io/v1beta1",
+		Kind:       "Gateway",
+		Metadata: k8sObjectMeta{
+			Name:      gw.Name,
+			Namespace: gw.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: gatewaySpec{
+			Selector: gw.Selector,
+			Servers:  gw.Servers,
+		},
+	}
+}
+
+func validateGateway(gw Gateway) []string {
+	var errors []string
+
+	if gw.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	if len(gw.Servers) == 0 {
+		errors = append(errors, "at least one server is required")
+	}
+
+	protocols := make(map[string]string)
+	names := make(map[string]bool)
+	for i, server := range gw.Servers {
+		prefix := fmt.Sprintf("servers[%d]", i)
+
+		if server.Port.Number < 1 || server.Port.Number > 65535 {
+			errors = append(errors, fmt.Sprintf("%s: port number must be between 1 and 65535", prefix))
+		}
+		if server.Port.Name == "" {
+			errors = append(errors, fmt.Sprintf("%s: port name is required", prefix))
+		} else if names[server.Port.Name] {
+			errors = append(errors, fmt.Sprintf("%s: port name %q is used by another server", prefix, server.Port.Name))
+		}
+		names[server.Port.Name] = true
+
+		protocol := strings.ToUpper(server.Port.Protocol)
+		if !validGatewayProtocols[protocol] {
+			errors = append(errors, fmt.Sprintf("%s: unsupported protocol %q", prefix, server.Port.Protocol))
+		}
+		key := fmt.Sprintf("%s:%d", server.Bind, server.Port.Number)
+		if existing, ok := protocols[key]; ok && existing != protocol {
+			errors = append(errors, fmt.Sprintf("%s: port %d is already used with protocol %s", prefix, server.Port.Number, existing))
+		}
+		protocols[key] = protocol
+
+		if len(server.Hosts) == 0 {
+			errors = append(errors, fmt.Sprintf("%s: at least one host is required", prefix))
+		}
+		for _, host := range server.Hosts {
+			if err := validateGatewayHost(host); err != "" {
+				errors = append(errors, fmt.Sprintf("%s: %s", prefix, err))
+			}
+		}
+
+		if server.TLS == nil {
+			if protocol == "HTTPS" || protocol == "TLS" {
+				errors = append(errors, fmt.Sprintf("%s: %s servers require tls settings", prefix, protocol))
+			}
+			continue
+		}
+		if server.TLS.HTTPSRedirect && protocol != "HTTP" {
+			errors = append(errors, fmt.Sprintf("%s: httpsRedirect is only valid on HTTP servers", prefix))
+		}
+		if protocol == "HTTPS" || protocol == "TLS" {
+			if !validServerTLSModes[server.TLS.Mode] {
+				errors = append(errors, fmt.Sprintf("%s: unsupported TLS mode %q", prefix, server.TLS.Mode))
+			}
+			if (server.TLS.Mode == "SIMPLE" || server.TLS.Mode == "MUTUAL" || server.TLS.Mode == "OPTIONAL_MUTUAL") && server.TLS.CredentialName == "" {
+				errors = append(errors, fmt.Sprintf("%s: TLS mode %s requires a credentialName", prefix, server.TLS.Mode))
+			}
+		}
+	}
+
+	return errors
+}
+
+// Gateway hosts are "[namespace/]dnsName" where the namespace may be "*" or "."
+func validateGatewayHost(host string) string {
+	dnsName := host
+	if parts := strings.SplitN(host, "/", 2); len(parts) == 2 {
+		if parts[0] == "" {
+			return fmt.Sprintf("host %q has an empty namespace", host)
+		}
+		dnsName = parts[1]
+	}
+	if dnsName == "" {
+		return fmt.Sprintf("host %q has an empty name", host)
+	}
+	if strings.Contains(dnsName[1:], "*") || (strings.HasPrefix(dnsName, "*") && dnsName != "*" && !strings.HasPrefix(dnsName, "*.")) {
+		return fmt.Sprintf("host %q may only use a leading wildcard", host)
+	}
+	return ""
+}
+
+// Namespaces running pods the gateway selector matches. TLS secrets and the
+// ingress Service live alongside these pods.
+func gatewayWorkloadPods(selector map[string]string) ([]corev1.Pod, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(selector) == 0 {
+		selector = defaultGatewaySelector
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(selector).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+func podNamespaces(pods []corev1.Pod) []string {
+	seen := make(map[string]bool)
+	var namespaces []string
+	for _, pod := range pods {
+		if !seen[pod.Namespace] {
+			seen[pod.Namespace] = true
+			namespaces = append(namespaces, pod.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Check that every credentialName resolves to a usable Secret next to the gateway workload
+func checkGatewayCredentials(gw Gateway) []string {
+	var errors []string
+
+	var credentialServers []int
+	for i, server := range gw.Servers {
+		if server.TLS != nil && server.TLS.CredentialName != "" {
+			credentialServers = append(credentialServers, i)
+		}
+	}
+	if len(credentialServers) == 0 {
+		return nil
+	}
+
+	pods, err := gatewayWorkloadPods(gw.Selector)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to find gateway workload: %v", err)}
+	}
+	namespaces := podNamespaces(pods)
+	if len(namespaces) == 0 {
+		return []string{"no gateway workload matches the selector, so credentialName cannot be resolved"}
+	}
+
+	for _, namespace := range namespaces {
+		secrets, err := getTLSSecrets(namespace)
+		if err != nil {
+			return []string{fmt.Sprintf("failed to list TLS secrets in %s: %v", namespace, err)}
+		}
+		available := make(map[string]TLSSecret)
+		for _, secret := range secrets {
+			available[secret.Name] = secret
+		}
+
+		for _, i := range credentialServers {
+			tls := gw.Servers[i].TLS
+			secret, ok := available[tls.CredentialName]
+			if !ok {
+				errors = append(errors, fmt.Sprintf("servers[%d]: TLS secret %q not found in gateway namespace %s", i, tls.CredentialName, namespace))
+				continue
+			}
+			if tls.Mode == "MUTUAL" || tls.Mode == "OPTIONAL_MUTUAL" {
+				if _, hasCA := available[tls.CredentialName+"-cacert"]; !secret.HasCACert && !hasCA {
+					errors = append(errors, fmt.Sprintf("servers[%d]: TLS mode %s needs ca.crt in %q or a %q secret", i, tls.Mode, tls.CredentialName, tls.CredentialName+"-cacert"))
+				}
+			}
+		}
+	}
+
+	return errors
+}
+
+func dryRunGateway(gw Gateway, create bool) []string {
+	if errors := validateGateway(gw); len(errors) > 0 {
+		return errors
+	}
+	if errors := checkGatewayCredentials(gw); len(errors) > 0 {
+		return errors
+	}
+	if err := kubectlDryRun(gatewayManifest(gw), create); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// Secrets holding a certificate and key in either the kubernetes.io/tls or
+// the generic cert/key layout Istio accepts
+func getTLSSecrets(namespace string) ([]TLSSecret, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := clientset.CoreV1().Secrets(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []TLSSecret{}
+	for _, secret := range secrets.Items {
+		cert := secret.Data["tls.crt"]
+		if cert == nil {
+			cert = secret.Data["cert"]
+		}
+		hasKey := secret.Data["tls.key"] != nil || secret.Data["key"] != nil
+		_, hasCA := secret.Data["ca.crt"]
+		if _, hasCACert := secret.Data["cacert"]; hasCACert {
+			hasCA = true
+		}
+
+		// CA-only secrets are listed so MUTUAL servers can be checked
+		if (cert == nil || !hasKey) && !hasCA {
+			continue
+		}
+
+		tlsSecret := TLSSecret{
+			Name:      secret.Name,
+			Namespace: secret.Namespace,
+			Type:      string(secret.Type),
+			HasCACert: hasCA,
+		}
+		if block, _ := pem.Decode(cert); block != nil {
+			if parsed, err := x509.ParseCertificate(block.Bytes); err == nil {
+				notAfter := parsed.NotAfter
+				tlsSecret.NotAfter = &notAfter
+				tlsSecret.DNSNames = parsed.DNSNames
+			}
+		}
+		result = append(result, tlsSecret)
+	}
+
+	return result, nil
+}
+
+// External addresses of the Services in front of the gateway workload:
+// load balancer IPs or hostnames, then any external IPs
+func getGatewayAddresses(selector map[string]string) ([]string, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := gatewayWorkloadPods(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []string{}
+	seen := make(map[string]bool)
+	add := func(address string) {
+		if address != "" && !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+
+	for _, namespace := range podNamespaces(pods) {
+		services, err := clientset.CoreV1().Services(namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, svc := range services.Items {
+			if len(svc.Spec.Selector) == 0 {
+				continue
+			}
+			selects := false
+			for _, pod := range pods {
+				if pod.Namespace == namespace && labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+					selects = true
+					break
+				}
+			}
+			if !selects {
+				continue
+			}
+
+			for _, ingress := range svc.Status.LoadBalancer.Ingress {
+				add(ingress.IP)
+				add(ingress.Hostname)
+			}
+			for _, ip := range svc.Spec.ExternalIPs {
+				add(ip)
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
+// Match a VirtualService host against a gateway server host pattern
+func gatewayHostMatches(pattern, host string) bool {
+	pattern, host = strings.ToLower(pattern), strings.ToLower(host)
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return host == pattern || strings.HasSuffix(host, pattern[1:])
+	}
+	return pattern == host
+}
+
+// Whether a gateway exposes a VirtualService host in the VirtualService's namespace
+func gatewayServesHost(gw Gateway, vsNamespace, host string) bool {
+	for _, server := range gw.Servers {
+		for _, gatewayHost := range server.Hosts {
+			namespace, dnsName := "*", gatewayHost
+			if parts := strings.SplitN(gatewayHost, "/", 2); len(parts) == 2 {
+				namespace, dnsName = parts[0], parts[1]
+			}
+			if namespace == "." {
+				namespace = gw.Namespace
+			}
+			if namespace != "*" && namespace != vsNamespace {
+				continue
+			}
+			if gatewayHostMatches(dnsName, host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Resolve a VirtualService gateway reference to namespace/name
+func gatewayReference(ref, vsNamespace string) string {
+	if strings.Contains(ref, "/") {
+		return ref
+	}
+	return vsNamespace + "/" + ref
+}
+
+// Find VirtualService hosts that their bound gateways do not serve
+func findGatewayHostIssues(virtualServices []VirtualService, gateways []Gateway) []GatewayHostIssue {
+	byRef := make(map[string]Gateway)
+	for _, gw := range gateways {
+		byRef[gw.Namespace+"/"+gw.Name] = gw
+	}
+
+	issues := []GatewayHostIssue{}
+	for _, vs := range virtualServices {
+		for _, ref := range vs.Gateways {
+			if ref == "mesh" {
+				continue
+			}
+			key := gatewayReference(ref, vs.Namespace)
+			gw, ok := byRef[key]
+			if !ok {
+				issues = append(issues, GatewayHostIssue{
+					VirtualService: vs.Name,
+					Namespace:      vs.Namespace,
+					Gateway:        key,
+					Message:        fmt.Sprintf("gateway %s does not exist", key),
+				})
+				continue
+			}
+			for _, host := range vs.Hosts {
+				if !gatewayServesHost(gw, vs.Namespace, host) {
+					issues = append(issues, GatewayHostIssue{
+						VirtualService: vs.Name,
+						Namespace:      vs.Namespace,
+						Gateway:        key,
+						Host:           host,
+						Message:        fmt.Sprintf("host %s is not served by any server on gateway %s", host, key),
+					})
+				}
+			}
+		}
+	}
+
+	return issues
+}
+
+func getGatewayHostIssues() ([]GatewayHostIssue, error) {
+	virtualServices, err := getVirtualServices("")
+	if err != nil {
+		return nil, err
+	}
+	gateways, err := getGateways("")
+	if err != nil {
+		return nil, err
+	}
+	return findGatewayHostIssues(virtualServices, gateways), nil
+}
+
+// Host issues for VirtualServices bound to one gateway, given its pending spec
+func gatewayHostWarnings(gw Gateway) []GatewayHostIssue {
+	virtualServices, err := getVirtualServices("")
+	if err != nil {
+		log.Printf("Warning: Could not check VirtualService hosts: %v", err)
+		return []GatewayHostIssue{}
+	}
+
+	key := gw.Namespace + "/" + gw.Name
+	var bound []VirtualService
+	for _, vs := range virtualServices {
+		for _, ref := range vs.Gateways {
+			if gatewayReference(ref, vs.Namespace) == key {
+				bound = append(bound, vs)
+				break
+			}
+		}
+	}
+	return findGatewayHostIssues(bound, []Gateway{gw})
+}
+
+func normalizeGateway(gw *Gateway) {
+	if gw.Namespace == "" {
+		gw.Namespace = "default"
+	}
+	if len(gw.Selector) == 0 {
+		gw.Selector = map[string]string{}
+		for key, value := range defaultGatewaySelector {
+			gw.Selector[key] = value
+		}
+	}
+	for i := range gw.Servers {
+		gw.Servers[i].Port.Protocol = strings.ToUpper(gw.Servers[i].Port.Protocol)
+		if gw.Servers[i].Port.Name == "" {
+			gw.Servers[i].Port.Name = fmt.Sprintf("%s-%d", strings.ToLower(gw.Servers[i].Port.Protocol), gw.Servers[i].Port.Number)
+		}
+	}
+	gw.Addresses = nil
+	summarizeGateway(gw)
+}
+
+func registerGatewayRoutes(e *echo.Echo) {
+	// List Gateways with their resolved external addresses
+	e.GET("/api/istio/gateways", func(c echo.Context) error {
+		gateways, err := getGateways(c.QueryParam("namespace"))
+		if err != nil {
+			log.Printf("Error listing Gateways: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list Gateways: %v", err),
+			})
+		}
+
+		for i := range gateways {
+			addresses, err := getGatewayAddresses(gateways[i].Selector)
+			if err != nil {
+				log.Printf("Warning: Could not resolve address for gateway %s/%s: %v", gateways[i].Namespace, gateways[i].Name, err)
+				continue
+			}
+			gateways[i].Addresses = addresses
+		}
+
+		issues, err := getGatewayHostIssues()
+		if err != nil {
+			log.Printf("Warning: Could not check gateway hosts: %v", err)
+			issues = []GatewayHostIssue{}
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"gateways":    gateways,
+			"host_issues": issues,
+			"count":       len(gateways),
+		})
+	})
+
+	// VirtualService hosts not served by their gateways
+	e.GET("/api/istio/gateways/host-issues", func(c echo.Context) error {
+		issues, err := getGatewayHostIssues()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to check gateway hosts: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"issues": issues,
+			"count":  len(issues),
+		})
+	})
+
+	// TLS secrets available as credentialName, from the ingress gateway namespace by default
+	e.GET("/api/istio/gateways/tls-secrets", func(c echo.Context) error {
+		namespace := c.QueryParam("namespace")
+		if namespace == "" {
+			namespace = istioRootNamespace
+		}
+
+		secrets, err := getTLSSecrets(namespace)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list TLS secrets: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"secrets": secrets,
+			"count":   len(secrets),
+		})
+	})
+
+	// Validate a Gateway without applying it
+	e.POST("/api/istio/gateways/validate", func(c echo.Context) error {
+		var gw Gateway
+		if err := c.Bind(&gw); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid Gateway payload",
+			})
+		}
+		normalizeGateway(&gw)
+
+		if errors := dryRunGateway(gw, false); len(errors) > 0 {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"valid":       true,
+			"message":     "Gateway is valid",
+			"host_issues": gatewayHostWarnings(gw),
+		})
+	})
+
+	// Get a single Gateway
+	e.GET("/api/istio/gateways/:namespace/:name", func(c echo.Context) error {
+		gw, err := getGateway(c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "Gateway not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get Gateway: %v", err),
+			})
+		}
+
+		if addresses, err := getGatewayAddresses(gw.Selector); err == nil {
+			gw.Addresses = addresses
+		} else {
+			log.Printf("Warning: Could not resolve address for gateway %s/%s: %v", gw.Namespace, gw.Name, err)
+		}
+
+		return c.JSON(http.StatusOK, gw)
+	})
+
+	// Create a Gateway
+	e.POST("/api/istio/gateways", func(c echo.Context) error {
+		var gw Gateway
+		if err := c.Bind(&gw); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid Gateway payload",
+			})
+		}
+		normalizeGateway(&gw)
+
+		if errors := dryRunGateway(gw, true); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		output, err := kubectlCreate(gatewayManifest(gw), false)
+		if err != nil {
+			log.Printf("Error creating Gateway: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to create Gateway: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusCreated, map[string]interface{}{
+			"success":     true,
+			"message":     "Gateway created successfully",
+			"output":      output,
+			"gateway":     gw,
+			"host_issues": gatewayHostWarnings(gw),
+		})
+	})
+
+	// Update a Gateway
+	e.PUT("/api/istio/gateways/:namespace/:name", func(c echo.Context) error {
+		var gw Gateway
+		if err := c.Bind(&gw); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid Gateway payload",
+			})
+		}
+		gw.Namespace = c.Param("namespace")
+		gw.Name = c.Param("name")
+		normalizeGateway(&gw)
+
+		if _, err := getGateway(gw.Namespace, gw.Name); err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "Gateway not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get Gateway: %v", err),
+			})
+		}
+
+		if errors := dryRunGateway(gw, false); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		output, err := kubectlApply(gatewayManifest(gw), false)
+		if err != nil {
+			log.Printf("Error updating Gateway: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to update Gateway: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success":     true,
+			"message":     "Gateway updated successfully",
+			"output":      output,
+			"gateway":     gw,
+			"host_issues": gatewayHostWarnings(gw),
+		})
+	})
+
+	// Delete a Gateway
+	e.DELETE("/api/istio/gateways/:namespace/:name", func(c echo.Context) error {
+		output, err := kubectlDeleteResource("gateways.networking.istio.io", c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "Gateway not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to delete Gateway: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": output,
+		})
+	})
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// Configurable istioctl install. Options map onto istioctl flags: the
+// profile and each set entry become --set, and the overlay is written to an
+// IstioOperator file passed with -f.
+
+type IstioInstallOptions struct {
+	Profile             string                 `json:"profile"`
+	Revision            string                 `json:"revision,omitempty"`
+	Overlay             map[string]interface{} `json:"overlay,omitempty"`
+	Set                 map[string]string      `json:"set,omitempty"`
+	InjectionNamespaces []string               `json:"injection_namespaces"`
+}
+
+type IstioProfile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Resource in a generated install manifest
+type ManifestResource struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+var istioProfiles = []IstioProfile{
+	{Name: "default", Description: "Production defaults: istiod and an ingress gateway"},
+	{Name: "demo", Description: "Showcases Istio features with ingress and egress gateways and high trace sampling"},
+	{Name: "minimal", Description: "Only istiod, for use with separately managed gateways"},
+	{Name: "ambient", Description: "Sidecar-less data plane with istiod, istio-cni and ztunnel"},
+}
+
+func isIstioProfile(name string) bool {
+	for _, profile := range istioProfiles {
+		if profile.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeIstioInstallOptions(options *IstioInstallOptions) {
+	if options.Profile == "" {
+		options.Profile = "default"
+	}
+	// Without an explicit list keep the previous behaviour of injecting default
+	if options.InjectionNamespaces == nil {
+		options.InjectionNamespaces = []string{"default"}
+	}
+}
+
+func validateIstioInstallOptions(options IstioInstallOptions) []string {
+	var errors []string
+
+	if !isIstioProfile(options.Profile) {
+		errors = append(errors, fmt.Sprintf("unsupported profile %q (use default, demo, minimal or ambient)", options.Profile))
+	}
+
+	if kind, ok := options.Overlay["kind"]; ok && kind != "IstioOperator" {
+		errors = append(errors, fmt.Sprintf("overlay kind must be IstioOperator, got %v", kind))
+	}
+
+	for key, value := range options.Set {
+		if key == "" || strings.ContainsAny(key, " =") {
+			errors = append(errors, fmt.Sprintf("invalid set key %q", key))
+		}
+		if key == "profile" {
+			errors = append(errors, "use the profile field instead of set.profile")
+		}
+		if strings.ContainsAny(value, "\n") {
+			errors = append(errors, fmt.Sprintf("set value for %q must be a single line", key))
+		}
+	}
+
+	if options.Revision != "" {
+		if msgs := validation.IsDNS1123Label(options.Revision); len(msgs) > 0 {
+			errors = append(errors, fmt.Sprintf("invalid revision %q: %s", options.Revision, strings.Join(msgs, ", ")))
+		}
+	}
+
+	for _, namespace := range options.InjectionNamespaces {
+		if msgs := validation.IsDNS1123Label(namespace); len(msgs) > 0 {
+			errors = append(errors, fmt.Sprintf("invalid namespace %q: %s", namespace, strings.Join(msgs, ", ")))
+		}
+	}
+
+	return errors
+}
+
+// Check that the injection namespaces exist before touching the cluster
+func checkInjectionNamespaces(namespaces []string) []string {
+	if len(namespaces) == 0 {
+		return nil
+	}
+
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var errors []string
+	for _, namespace := range namespaces {
+		if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{}); err != nil {
+			errors = append(errors, fmt.Sprintf("namespace %s: %v", namespace, err))
+		}
+	}
+	return errors
+}
+
+// Write the overlay as an IstioOperator document. A bare spec is wrapped.
+func writeIstioOperatorOverlay(overlay map[string]interface{}) (string, func(), error) {
+	if len(overlay) == 0 {
+		return "", func() {}, nil
+	}
+
+	operator := overlay
+	if _, ok := overlay["spec"]; !ok {
+		operator = map[string]interface{}{
+			"apiVersion": "install.istio.io/v1alpha1",
+			"kind":       "IstioOperator",
+			"spec":       overlay,
+		}
+	}
+
+	data, err := json.Marshal(operator)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode overlay: %v", err)
+	}
+
+	file, err := ioutil.TempFile("", "istio-operator-*.yaml")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create overlay file: %v", err)
+	}
+	cleanup := func() {
+		os.Remove(file.Name())
+	}
+
+	// JSON is valid YAML, so istioctl reads it as is
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write overlay file: %v", err)
+	}
+	file.Close()
+
+	return file.Name(), cleanup, nil
+}
+
+// istioctl flags shared by install and manifest generate
+func istioOperatorArgs(options IstioInstallOptions) ([]string, func(), error) {
+	args := []string{"--set", "profile=" + options.Profile}
+
+	overlayPath, cleanup, err := writeIstioOperatorOverlay(options.Overlay)
+	if err != nil {
+		return nil, nil, err
+	}
+	if overlayPath != "" {
+		args = append(args, "-f", overlayPath)
+	}
+
+	// A revisioned control plane runs alongside the default one, so only an
+	// unrevisioned install takes over the default injection webhook
+	if options.Revision != "" {
+		args = append(args, "--set", "revision="+options.Revision)
+	} else if _, ok := options.Set["values.defaultRevision"]; !ok {
+		args = append(args, "--set", "values.defaultRevision=default")
+	}
+
+	keys := make([]string, 0, len(options.Set))
+	for key := range options.Set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--set", fmt.Sprintf("%s=%s", key, options.Set[key]))
+	}
+
+	return args, cleanup, nil
+}
+
+// Render the manifest istioctl would install, without applying it
+func generateIstioManifest(istioctl string, options IstioInstallOptions) (string, []ManifestResource, error) {
+	args, cleanup, err := istioOperatorArgs(options)
+	if err != nil {
+		return "", nil, err
+	}
+	defer cleanup()
+
+	cmd := exec.Command(istioctl, append([]string{"manifest", "generate"}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", nil, fmt.Errorf("istioctl manifest generate failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", nil, fmt.Errorf("istioctl manifest generate failed: %v", err)
+	}
+
+	manifest := string(output)
+	return manifest, summarizeManifest(manifest), nil
+}
+
+func summarizeManifest(manifest string) []ManifestResource {
+	resources := []ManifestResource{}
+	for _, doc := range strings.Split(manifest, "\n---") {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		var resource struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &resource); err != nil || resource.Kind == "" {
+			continue
+		}
+		resources = append(resources, ManifestResource{
+			Kind:      resource.Kind,
+			Name:      resource.Metadata.Name,
+			Namespace: resource.Metadata.Namespace,
+		})
+	}
+	return resources
+}
+
+// Label a namespace for the data plane of the installed profile. Ambient
+// namespaces join the mesh through ztunnel instead of sidecar injection, and
+// revisioned installs are selected with istio.io/rev.
+func enableIstioDataPlane(namespace string, options IstioInstallOptions) error {
+	labels := []string{"istio-injection=enabled"}
+	switch {
+	case options.Profile == "ambient":
+		labels = []string{"istio.io/dataplane-mode=ambient"}
+	case options.Revision != "":
+		labels = []string{"istio-injection-", "istio.io/rev=" + options.Revision}
+	}
+	args := append([]string{"label", "namespace", namespace}, labels...)
+	_, err := runKubectl(nil, append(args, "--overwrite")...)
+	return err
+}
+
+func installIstio(istioctl string, options IstioInstallOptions) (string, []string, error) {
+	log.Printf("Installing Istio with profile %s...", options.Profile)
+
+	args, cleanup, err := istioOperatorArgs(options)
+	if err != nil {
+		return "", nil, err
+	}
+	defer cleanup()
+
+	installCmd := exec.Command(istioctl, append(append([]string{"install"}, args...), "-y")...)
+	output, err := installCmd.CombinedOutput()
+	if err != nil {
+		return string(output), nil, fmt.Errorf("failed to install Istio: %v, output: %s", err, output)
+	}
+
+	log.Printf("Istio installation completed: %s", output)
+
+	var labelled []string
+	for _, namespace := range options.InjectionNamespaces {
+		if err := enableIstioDataPlane(namespace, options); err != nil {
+			log.Printf("Warning: Failed to label namespace %s: %v", namespace, err)
+			continue
+		}
+		labelled = append(labelled, namespace)
+	}
+
+	return string(output), labelled, nil
+}
+
+func registerIstioInstallRoutes(e *echo.Echo) {
+	// Available install profiles
+	e.GET("/api/istio/install/profiles", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"profiles": istioProfiles,
+			"count":    len(istioProfiles),
+		})
+	})
+
+	// Preview the manifest an install would apply
+	e.POST("/api/istio/install/preview", func(c echo.Context) error {
+		var options IstioInstallOptions
+		if err := c.Bind(&options); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid install options",
+			})
+		}
+		normalizeIstioInstallOptions(&options)
+
+		if errors := validateIstioInstallOptions(options); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+		if err := checkIstioCLI(); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Istio CLI not found. Please download it first.",
+			})
+		}
+
+		manifest, resources, err := generateIstioManifest("istioctl", options)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"profile":              options.Profile,
+			"injection_namespaces": options.InjectionNamespaces,
+			"manifest":             manifest,
+			"resources":            resources,
+			"count":                len(resources),
+		})
+	})
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Mutual TLS posture from PeerAuthentication policies. Istio resolves the
+// mode for a workload from the most specific policy that sets one: workload
+// selector, then namespace-wide, then mesh-wide in the root namespace, and
+// falls back to PERMISSIVE.
+
+const istioRootNamespace = "istio-system"
+
+type PeerAuthentication struct {
+	Name          string            `json:"name"`
+	Namespace     string            `json:"namespace"`
+	Level         string            `json:"level"`
+	Selector      map[string]string `json:"selector,omitempty"`
+	Mode          string            `json:"mode"`
+	PortLevelMTLS map[string]string `json:"port_level_mtls,omitempty"`
+}
+
+type WorkloadMTLS struct {
+	Name       string            `json:"name"`
+	Namespace  string            `json:"namespace"`
+	Pods       int               `json:"pods"`
+	HasSidecar bool              `json:"has_sidecar"`
+	Mode       string            `json:"mode"`
+	Policy     string            `json:"policy"`
+	PortModes  map[string]string `json:"port_modes,omitempty"`
+}
+
+type MTLSPrecheck struct {
+	Namespace        string         `json:"namespace"`
+	Ready            bool           `json:"ready"`
+	ClientsVerified  bool           `json:"clients_verified"`
+	CurrentMode      string         `json:"current_mode"`
+	PodsWithoutProxy []string       `json:"pods_without_proxy"`
+	PlaintextClients []MTLSClient   `json:"plaintext_clients"`
+	Warnings         []string       `json:"warnings"`
+	Workloads        []WorkloadMTLS `json:"workloads"`
+}
+
+// MTLSClient is a source workload seen sending plaintext into a namespace
+type MTLSClient struct {
+	Workload    string  `json:"workload"`
+	Namespace   string  `json:"namespace"`
+	RequestRate float64 `json:"request_rate"`
+}
+
+type peerAuthenticationSpec struct {
+	Selector *struct {
+		MatchLabels map[string]string `json:"matchLabels"`
+	} `json:"selector,omitempty"`
+	MTLS *struct {
+		Mode string `json:"mode"`
+	} `json:"mtls,omitempty"`
+	PortLevelMTLS map[string]struct {
+		Mode string `json:"mode"`
+	} `json:"portLevelMtls,omitempty"`
+}
+
+func getPeerAuthentications(namespace string) ([]PeerAuthentication, error) {
+	items, err := kubectlListResources("peerauthentications.security.istio.io", namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := []PeerAuthentication{}
+	for _, item := range items {
+		var spec peerAuthenticationSpec
+		if err := json.Unmarshal(item.Spec, &spec); err != nil {
+			log.Printf("Skipping PeerAuthentication %s/%s: %v", item.Metadata.Namespace, item.Metadata.Name, err)
+			continue
+		}
+
+		policy := PeerAuthentication{
+			Name:      item.Metadata.Name,
+			Namespace: item.Metadata.Namespace,
+			Mode:      "UNSET",
+		}
+		if spec.MTLS != nil && spec.MTLS.Mode != "" {
+			policy.Mode = spec.MTLS.Mode
+		}
+		if spec.Selector != nil && len(spec.Selector.MatchLabels) > 0 {
+			policy.Selector = spec.Selector.MatchLabels
+		}
+		if len(spec.PortLevelMTLS) > 0 {
+			policy.PortLevelMTLS = make(map[string]string)
+			for port, setting := range spec.PortLevelMTLS {
+				policy.PortLevelMTLS[port] = setting.Mode
+			}
+		}
+
+		switch {
+		case policy.Selector != nil:
+			policy.Level = "workload"
+		case policy.Namespace == istioRootNamespace:
+			policy.Level = "mesh"
+		default:
+			policy.Level = "namespace"
+		}
+
+		policies = append(policies, policy)
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		if policies[i].Namespace != policies[j].Namespace {
+			return policies[i].Namespace < policies[j].Namespace
+		}
+		return policies[i].Name < policies[j].Name
+	})
+
+	return policies, nil
+}
+
+// Name of the workload that owns a pod, e.g. the Deployment behind a ReplicaSet
+func podWorkloadName(pod corev1.Pod) string {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		if owner.Kind == "ReplicaSet" {
+			if hash := pod.Labels["pod-template-hash"]; hash != "" {
+				return strings.TrimSuffix(owner.Name, "-"+hash)
+			}
+		}
+		return owner.Name
+	}
+	return pod.Name
+}
+
+func hasIstioSidecar(pod corev1.Pod) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == "istio-proxy" {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve the effective mode for a set of pod labels in a namespace
+func effectiveMTLSMode(namespace string, podLabels map[string]string, policies []PeerAuthentication) (string, string, map[string]string) {
+	var workload, namespaceWide, meshWide *PeerAuthentication
+	for i := range policies {
+		policy := &policies[i]
+		switch {
+		case policy.Level == "mesh":
+			if meshWide == nil {
+				meshWide = policy
+			}
+		case policy.Namespace != namespace:
+			continue
+		case policy.Level == "namespace":
+			if namespaceWide == nil {
+				namespaceWide = policy
+			}
+		case workload == nil && labels.SelectorFromSet(policy.Selector).Matches(labels.Set(podLabels)):
+			workload = policy
+		}
+	}
+
+	mode, source := "PERMISSIVE", "default"
+	var portModes map[string]string
+	for _, policy := range []*PeerAuthentication{workload, namespaceWide, meshWide} {
+		if policy == nil {
+			continue
+		}
+		if portModes == nil && policy.Level == "workload" && len(policy.PortLevelMTLS) > 0 {
+			portModes = policy.PortLevelMTLS
+		}
+		if policy.Mode != "UNSET" {
+			mode, source = policy.Mode, policy.Namespace+"/"+policy.Name
+			break
+		}
+	}
+
+	return mode, source, portModes
+}
+
+// Effective mTLS mode for every workload, across all namespaces when namespace is empty
+func getWorkloadMTLS(namespace string) ([]WorkloadMTLS, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := getPeerAuthentications("")
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	byWorkload := make(map[string]*WorkloadMTLS)
+	var keys []string
+	for _, pod := range pods.Items {
+		if pod.Spec.HostNetwork || pod.Namespace == "kube-system" {
+			continue
+		}
+
+		key := pod.Namespace + "/" + podWorkloadName(pod)
+		workload, exists := byWorkload[key]
+		if !exists {
+			mode, source, portModes := effectiveMTLSMode(pod.Namespace, pod.Labels, policies)
+			workload = &WorkloadMTLS{
+				Name:       podWorkloadName(pod),
+				Namespace:  pod.Namespace,
+				HasSidecar: true,
+				Mode:       mode,
+				Policy:     source,
+				PortModes:  portModes,
+			}
+			byWorkload[key] = workload
+			keys = append(keys, key)
+		}
+
+		workload.Pods++
+		if !hasIstioSidecar(pod) {
+			workload.HasSidecar = false
+		}
+	}
+
+	sort.Strings(keys)
+	workloads := []WorkloadMTLS{}
+	for _, key := range keys {
+		workload := byWorkload[key]
+		// Without a sidecar nothing enforces or originates mTLS
+		if !workload.HasSidecar {
+			workload.Mode = "DISABLE"
+			workload.Policy = "no sidecar"
+		}
+		workloads = append(workloads, *workload)
+	}
+
+	return workloads, nil
+}
+
+// Check whether a namespace can move to STRICT without breaking callers.
+// Pods in the namespace need sidecars to originate mTLS to their neighbours,
+// and Istio telemetry shows which clients currently send plaintext.
+func precheckStrictMTLS(namespace string) (*MTLSPrecheck, error) {
+	workloads, err := getWorkloadMTLS(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := getPeerAuthentications("")
+	if err != nil {
+		return nil, err
+	}
+	currentMode, _, _ := effectiveMTLSMode(namespace, nil, policies)
+
+	precheck := &MTLSPrecheck{
+		Namespace:        namespace,
+		CurrentMode:      currentMode,
+		PodsWithoutProxy: []string{},
+		PlaintextClients: []MTLSClient{},
+		Warnings:         []string{},
+		Workloads:        workloads,
+	}
+
+	for _, workload := range workloads {
+		if !workload.HasSidecar {
+			precheck.PodsWithoutProxy = append(precheck.PodsWithoutProxy, workload.Name)
+		}
+	}
+
+	query := fmt.Sprintf(`sum by (source_workload, source_workload_namespace) (rate(istio_requests_total{reporter="destination",destination_workload_namespace="%s",connection_security_policy!="mutual_tls"}[1h]))`, namespace)
+	samples, err := queryPrometheus(query)
+	if err != nil {
+		precheck.Warnings = append(precheck.Warnings, fmt.Sprintf("Could not check client traffic in Prometheus: %v", err))
+	} else {
+		precheck.ClientsVerified = true
+	}
+	for _, sample := range samples {
+		if sample.Value <= 0 {
+			continue
+		}
+		precheck.PlaintextClients = append(precheck.PlaintextClients, MTLSClient{
+			Workload:    sample.Metric["source_workload"],
+			Namespace:   sample.Metric["source_workload_namespace"],
+			RequestRate: sample.Value,
+		})
+	}
+
+	// Without telemetry plaintext clients cannot be ruled out
+	precheck.Ready = precheck.ClientsVerified && len(precheck.PodsWithoutProxy) == 0 && len(precheck.PlaintextClients) == 0
+	return precheck, nil
+}
+
+// Set the namespace-wide PeerAuthentication to STRICT, reusing an existing
+// namespace-wide policy so the namespace never ends up with two
+func applyStrictMTLS(namespace string) (string, error) {
+	policies, err := getPeerAuthentications(namespace)
+	if err != nil {
+		return "", err
+	}
+
+	name := "default"
+	var portLevel map[string]interface{}
+	for _, policy := range policies {
+		if policy.Level == "namespace" || (policy.Level == "mesh" && namespace == istioRootNamespace) {
+			name = policy.Name
+			if len(policy.PortLevelMTLS) > 0 {
+				portLevel = make(map[string]interface{})
+				for port, mode := range policy.PortLevelMTLS {
+					portLevel[port] = map[string]string{"mode": mode}
+				}
+			}
+			break
+		}
+	}
+
+	spec := map[string]interface{}{
+		"mtls": map[string]string{"mode": "STRICT"},
+	}
+	if portLevel != nil {
+		spec["portLevelMtls"] = portLevel
+	}
+
+	manifest := k8sResource{
+		APIVersion: "security.istio.io/v1beta1",
+		Kind:       "PeerAuthentication",
+		Metadata: k8sObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: spec,
+	}
+
+	if err := kubectlDryRun(manifest, false); err != nil {
+		return "", err
+	}
+	return kubectlApply(manifest, false)
+}
+
+// Summary of mesh mTLS posture for the Istio status endpoint
+func getMTLSSummary() (map[string]interface{}, error) {
+	policies, err := getPeerAuthentications("")
+	if err != nil {
+		return nil, err
+	}
+
+	meshMode, _, _ := effectiveMTLSMode("", nil, policies)
+	modes := map[string]int{}
+	namespaces := map[string]string{}
+	for _, policy := range policies {
+		if policy.Level == "namespace" {
+			namespaces[policy.Namespace] = policy.Mode
+		}
+		modes[policy.Mode]++
+	}
+
+	return map[string]interface{}{
+		"mesh_mode":       meshMode,
+		"policies":        len(policies),
+		"policy_modes":    modes,
+		"namespace_modes": namespaces,
+	}, nil
+}
+
+func registerMTLSRoutes(e *echo.Echo) {
+	// List PeerAuthentication policies at every level
+	e.GET("/api/istio/mtls/policies", func(c echo.Context) error {
+		policies, err := getPeerAuthentications(c.QueryParam("namespace"))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list PeerAuthentication policies: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"policies": policies,
+			"count":    len(policies),
+		})
+	})
+
+	// Effective mTLS mode per workload
+	e.GET("/api/istio/mtls/workloads", func(c echo.Context) error {
+		workloads, err := getWorkloadMTLS(c.QueryParam("namespace"))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to compute mTLS modes: %v", err),
+			})
+		}
+
+		counts := map[string]int{}
+		for _, workload := range workloads {
+			counts[workload.Mode]++
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"workloads": workloads,
+			"modes":     counts,
+			"count":     len(workloads),
+		})
+	})
+
+	// Check whether a namespace is ready for STRICT mTLS
+	e.GET("/api/istio/mtls/namespaces/:namespace/precheck", func(c echo.Context) error {
+		precheck, err := precheckStrictMTLS(c.Param("namespace"))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to run mTLS precheck: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, precheck)
+	})
+
+	// Switch a namespace to STRICT mTLS once the precheck passes
+	e.POST("/api/istio/mtls/namespaces/:namespace/strict", func(c echo.Context) error {
+		namespace := c.Param("namespace")
+		var request struct {
+			Force bool `json:"force"`
+		}
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid request payload",
+			})
+		}
+
+		precheck, err := precheckStrictMTLS(namespace)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to run mTLS precheck: %v", err),
+			})
+		}
+		if !precheck.Ready && !request.Force {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"success":  false,
+				"message":  "Namespace is not ready for STRICT mTLS; fix the precheck findings or retry with force",
+				"precheck": precheck,
+			})
+		}
+
+		output, err := applyStrictMTLS(namespace)
+		if err != nil {
+			log.Printf("Error enabling STRICT mTLS in %s: %v", namespace, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to apply PeerAuthentication: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success":  true,
+			"message":  fmt.Sprintf("Namespace %s now requires mutual TLS", namespace),
+			"output":   output,
+			"precheck": precheck,
+		})
+	})
+}
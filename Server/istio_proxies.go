@@ -0,0 +1,385 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// Data-plane debugging. Sync state comes from the istioctl proxy-status table
+// and the config dump from the Envoy admin API inside the istio-proxy
+// container, reached through pilot-agent so nothing needs port-forwarding.
+
+type ProxySyncStatus struct {
+	Name      string `json:"name"`
+	Pod       string `json:"pod"`
+	Namespace string `json:"namespace"`
+	Cluster   string `json:"cluster,omitempty"`
+	CDS       string `json:"cds"`
+	LDS       string `json:"lds"`
+	EDS       string `json:"eds"`
+	RDS       string `json:"rds"`
+	ECDS      string `json:"ecds,omitempty"`
+	Istiod    string `json:"istiod"`
+	Version   string `json:"version"`
+	Synced    bool   `json:"synced"`
+}
+
+type EnvoyCluster struct {
+	Name      string `json:"name"`
+	Type      string `json:"type,omitempty"`
+	Direction string `json:"direction,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	Subset    string `json:"subset,omitempty"`
+	Host      string `json:"host,omitempty"`
+}
+
+type EnvoyListener struct {
+	Name         string `json:"name"`
+	Address      string `json:"address"`
+	Port         int    `json:"port"`
+	FilterChains int    `json:"filter_chains"`
+}
+
+type EnvoyRoute struct {
+	Name     string         `json:"name,omitempty"`
+	Match    string         `json:"match"`
+	Clusters map[string]int `json:"clusters,omitempty"`
+}
+
+type EnvoyVirtualHost struct {
+	Name    string       `json:"name"`
+	Domains []string     `json:"domains"`
+	Routes  []EnvoyRoute `json:"routes"`
+}
+
+type EnvoyRouteConfig struct {
+	Name         string             `json:"name"`
+	VirtualHosts []EnvoyVirtualHost `json:"virtual_hosts"`
+}
+
+type EnvoyConfigSummary struct {
+	Clusters  []EnvoyCluster     `json:"clusters"`
+	Listeners []EnvoyListener    `json:"listeners"`
+	Routes    []EnvoyRouteConfig `json:"routes"`
+}
+
+// xDS states that count as in sync with istiod
+func isProxySynced(state string) bool {
+	return state == "" || strings.HasPrefix(state, "SYNCED") || strings.HasPrefix(state, "NOT SENT") || strings.HasPrefix(state, "IGNORED")
+}
+
+// Parse the proxy-status table. Columns are located from the header because
+// values such as "NOT SENT" contain spaces and older istioctl releases have
+// no CLUSTER or ECDS column.
+func parseProxyStatus(output string) []ProxySyncStatus {
+	statuses := []ProxySyncStatus{}
+
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) < 2 {
+		return statuses
+	}
+
+	header := lines[0]
+	var columns []string
+	var starts []int
+	for i := 0; i < len(header); {
+		if header[i] == ' ' {
+			i++
+			continue
+		}
+		end := i
+		for end < len(header) && header[end] != ' ' {
+			end++
+		}
+		columns = append(columns, header[i:end])
+		starts = append(starts, i)
+		i = end
+	}
+
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		values := make(map[string]string)
+		for i, column := range columns {
+			if starts[i] >= len(line) {
+				break
+			}
+			end := len(line)
+			if i+1 < len(starts) && starts[i+1] < end {
+				end = starts[i+1]
+			}
+			values[column] = strings.TrimSpace(line[starts[i]:end])
+		}
+
+		status := ProxySyncStatus{
+			Name:    values["NAME"],
+			Cluster: values["CLUSTER"],
+			CDS:     values["CDS"],
+			LDS:     values["LDS"],
+			EDS:     values["EDS"],
+			RDS:     values["RDS"],
+			ECDS:    values["ECDS"],
+			Istiod:  values["ISTIOD"],
+			Version: values["VERSION"],
+		}
+		status.Pod = status.Name
+		if dot := strings.LastIndex(status.Name, "."); dot > 0 {
+			status.Pod, status.Namespace = status.Name[:dot], status.Name[dot+1:]
+		}
+		status.Synced = isProxySynced(status.CDS) && isProxySynced(status.LDS) &&
+			isProxySynced(status.EDS) && isProxySynced(status.RDS) && isProxySynced(status.ECDS)
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+func getProxySyncStatus() ([]ProxySyncStatus, error) {
+	cmd := exec.Command("istioctl", "proxy-status")
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("istioctl proxy-status failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("istioctl proxy-status failed: %v", err)
+	}
+	return parseProxyStatus(string(output)), nil
+}
+
+// Fetch the full Envoy config dump of a pod's sidecar
+func getEnvoyConfigDump(namespace, pod string) (map[string]interface{}, error) {
+	output, err := runKubectl(nil, "exec", pod, "-n", namespace, "-c", "istio-proxy", "--",
+		"pilot-agent", "request", "GET", "config_dump")
+	if err != nil {
+		return nil, err
+	}
+
+	var dump map[string]interface{}
+	if err := json.Unmarshal(output, &dump); err != nil {
+		return nil, fmt.Errorf("failed to parse config dump: %v", err)
+	}
+	return dump, nil
+}
+
+// Istio names clusters direction|port|subset|host
+func parseEnvoyClusterName(name string) EnvoyCluster {
+	cluster := EnvoyCluster{Name: name}
+	parts := strings.Split(name, "|")
+	if len(parts) == 4 {
+		cluster.Direction = parts[0]
+		cluster.Port, _ = strconv.Atoi(parts[1])
+		cluster.Subset = parts[2]
+		cluster.Host = parts[3]
+	}
+	return cluster
+}
+
+func jsonMap(value interface{}, path ...string) map[string]interface{} {
+	current, _ := value.(map[string]interface{})
+	for _, key := range path {
+		if current == nil {
+			return nil
+		}
+		current, _ = current[key].(map[string]interface{})
+	}
+	return current
+}
+
+func jsonList(value map[string]interface{}, key string) []interface{} {
+	if value == nil {
+		return nil
+	}
+	list, _ := value[key].([]interface{})
+	return list
+}
+
+func jsonString(value map[string]interface{}, key string) string {
+	if value == nil {
+		return ""
+	}
+	s, _ := value[key].(string)
+	return s
+}
+
+func jsonInt(value map[string]interface{}, key string) int {
+	if value == nil {
+		return 0
+	}
+	n, _ := value[key].(float64)
+	return int(n)
+}
+
+func describeRouteMatch(match map[string]interface{}) string {
+	for _, key := range []string{"prefix", "path"} {
+		if value, ok := match[key].(string); ok {
+			return key + " " + value
+		}
+	}
+	if regex := jsonMap(match, "safe_regex"); regex != nil {
+		return "regex " + jsonString(regex, "regex")
+	}
+	return "any"
+}
+
+// Reduce a config dump to the clusters, listeners and routes
+func summarizeEnvoyConfig(dump map[string]interface{}) EnvoyConfigSummary {
+	summary := EnvoyConfigSummary{
+		Clusters:  []EnvoyCluster{},
+		Listeners: []EnvoyListener{},
+		Routes:    []EnvoyRouteConfig{},
+	}
+
+	for _, entry := range jsonList(dump, "configs") {
+		config := jsonMap(entry)
+		configType := jsonString(config, "@type")
+
+		switch {
+		case strings.HasSuffix(configType, "ClustersConfigDump"):
+			for _, key := range []string{"static_clusters", "dynamic_active_clusters"} {
+				for _, item := range jsonList(config, key) {
+					raw := jsonMap(item, "cluster")
+					cluster := parseEnvoyClusterName(jsonString(raw, "name"))
+					cluster.Type = jsonString(raw, "type")
+					summary.Clusters = append(summary.Clusters, cluster)
+				}
+			}
+
+		case strings.HasSuffix(configType, "ListenersConfigDump"):
+			for _, item := range jsonList(config, "dynamic_listeners") {
+				raw := jsonMap(item, "active_state", "listener")
+				if raw == nil {
+					continue
+				}
+				socket := jsonMap(raw, "address", "socket_address")
+				summary.Listeners = append(summary.Listeners, EnvoyListener{
+					Name:         jsonString(raw, "name"),
+					Address:      jsonString(socket, "address"),
+					Port:         jsonInt(socket, "port_value"),
+					FilterChains: len(jsonList(raw, "filter_chains")),
+				})
+			}
+
+		case strings.HasSuffix(configType, "RoutesConfigDump"):
+			var routeConfigs []interface{}
+			for _, item := range jsonList(config, "static_route_configs") {
+				routeConfigs = append(routeConfigs, item)
+			}
+			for _, item := range jsonList(config, "dynamic_route_configs") {
+				routeConfigs = append(routeConfigs, item)
+			}
+
+			for _, item := range routeConfigs {
+				raw := jsonMap(item, "route_config")
+				routeConfig := EnvoyRouteConfig{Name: jsonString(raw, "name"), VirtualHosts: []EnvoyVirtualHost{}}
+
+				for _, vh := range jsonList(raw, "virtual_hosts") {
+					rawHost := jsonMap(vh)
+					host := EnvoyVirtualHost{Name: jsonString(rawHost, "name"), Domains: []string{}, Routes: []EnvoyRoute{}}
+					for _, domain := range jsonList(rawHost, "domains") {
+						if s, ok := domain.(string); ok {
+							host.Domains = append(host.Domains, s)
+						}
+					}
+
+					for _, r := range jsonList(rawHost, "routes") {
+						rawRoute := jsonMap(r)
+						route := EnvoyRoute{
+							Name:     jsonString(rawRoute, "name"),
+							Match:    describeRouteMatch(jsonMap(rawRoute, "match")),
+							Clusters: map[string]int{},
+						}
+						action := jsonMap(rawRoute, "route")
+						if cluster := jsonString(action, "cluster"); cluster != "" {
+							route.Clusters[cluster] = 100
+						}
+						for _, wc := range jsonList(jsonMap(action, "weighted_clusters"), "clusters") {
+							weighted := jsonMap(wc)
+							route.Clusters[jsonString(weighted, "name")] = jsonInt(weighted, "weight")
+						}
+						host.Routes = append(host.Routes, route)
+					}
+
+					routeConfig.VirtualHosts = append(routeConfig.VirtualHosts, host)
+				}
+
+				summary.Routes = append(summary.Routes, routeConfig)
+			}
+		}
+	}
+
+	return summary
+}
+
+func registerIstioProxyRoutes(e *echo.Echo) {
+	// Per-proxy xDS sync state
+	e.GET("/api/istio/proxies", func(c echo.Context) error {
+		if err := checkIstioCLI(); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Istio CLI not found. Please download it first.",
+			})
+		}
+
+		statuses, err := getProxySyncStatus()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		namespace := c.QueryParam("namespace")
+		proxies := []ProxySyncStatus{}
+		stale := 0
+		for _, status := range statuses {
+			if namespace != "" && status.Namespace != namespace {
+				continue
+			}
+			if !status.Synced {
+				stale++
+			}
+			proxies = append(proxies, status)
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"proxies": proxies,
+			"count":   len(proxies),
+			"stale":   stale,
+		})
+	})
+
+	// Envoy clusters, listeners and routes of one sidecar. ?raw=true returns
+	// the unprocessed config dump.
+	e.GET("/api/istio/proxies/:namespace/:pod/config", func(c echo.Context) error {
+		namespace, pod := c.Param("namespace"), c.Param("pod")
+
+		dump, err := getEnvoyConfigDump(namespace, pod)
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": fmt.Sprintf("Pod %s/%s or its istio-proxy container not found", namespace, pod),
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to fetch Envoy config dump: %v", err),
+			})
+		}
+
+		if c.QueryParam("raw") == "true" {
+			return c.JSON(http.StatusOK, dump)
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"namespace": namespace,
+			"pod":       pod,
+			"config":    summarizeEnvoyConfig(dump),
+		})
+	})
+}
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Revision-based Istio upgrades. Releases come from a local mirror directory
+// of extracted istio-<version> trees or istio-<version>-*.tar.gz archives;
+// each one can run as its own istiod revision while namespaces move across
+// with istio.io/rev labels.
+
+const defaultIstioVersion = "1.17.2"
+
+type IstioVersion struct {
+	Version   string   `json:"version"`
+	Path      string   `json:"path"`
+	Extracted bool     `json:"extracted"`
+	Revisions []string `json:"revisions,omitempty"`
+}
+
+type IstioRevision struct {
+	Revision   string   `json:"revision"`
+	Version    string   `json:"version"`
+	Istiod     string   `json:"istiod"`
+	Ready      string   `json:"ready"`
+	Namespaces []string `json:"namespaces"`
+	Proxies    int      `json:"proxies"`
+}
+
+type NamespaceMigration struct {
+	Namespace      string         `json:"namespace"`
+	TargetRevision string         `json:"target_revision"`
+	Proxies        map[string]int `json:"proxies"`
+	Pending        []string       `json:"pending"`
+	WithoutProxy   []string       `json:"without_proxy"`
+	Complete       bool           `json:"complete"`
+}
+
+var istioReleasePattern = regexp.MustCompile(`^istio-(\d+\.\d+\.\d+(?:-[0-9A-Za-z.]+)??)(?:-(?:linux|osx|win).*\.tar\.gz)?$`)
+
+// Version accepted from clients, the same shape as the version in a release name
+var istioVersionPattern = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.]+)?$`)
+
+// Mirror directory from ISTIO_MIRROR_DIR, defaulting to ~/.meshify/istio
+func getIstioMirrorDir() string {
+	if dir := os.Getenv("ISTIO_MIRROR_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".meshify", "istio")
+}
+
+// Order versions numerically, so 1.20.0 sorts after 1.9.3
+func compareIstioVersions(a, b string) int {
+	partsA := strings.FieldsFunc(a, func(r rune) bool { return r == '.' || r == '-' })
+	partsB := strings.FieldsFunc(b, func(r rune) bool { return r == '.' || r == '-' })
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		numA, errA := strconv.Atoi(partsA[i])
+		numB, errB := strconv.Atoi(partsB[i])
+		switch {
+		case errA == nil && errB == nil && numA != numB:
+			if numA < numB {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && partsA[i] != partsB[i]:
+			return strings.Compare(partsA[i], partsB[i])
+		}
+	}
+	return len(partsA) - len(partsB)
+}
+
+func revisionForVersion(version string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(version)
+}
+
+func getMirrorVersions() ([]IstioVersion, error) {
+	dir := getIstioMirrorDir()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []IstioVersion{}, nil
+		}
+		return nil, err
+	}
+
+	byVersion := make(map[string]*IstioVersion)
+	for _, entry := range entries {
+		match := istioReleasePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		version := match[1]
+
+		if entry.IsDir() {
+			if _, err := os.Stat(filepath.Join(path, "bin", "istioctl")); err != nil {
+				continue
+			}
+			byVersion[version] = &IstioVersion{Version: version, Path: path, Extracted: true}
+		} else if strings.HasSuffix(entry.Name(), ".tar.gz") && byVersion[version] == nil {
+			byVersion[version] = &IstioVersion{Version: version, Path: path}
+		}
+	}
+
+	versions := []IstioVersion{}
+	for _, version := range byVersion {
+		versions = append(versions, *version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareIstioVersions(versions[i].Version, versions[j].Version) > 0
+	})
+	return versions, nil
+}
+
+// istioctl binary for a mirrored version, extracting its archive on first use
+func istioctlForVersion(version string) (string, error) {
+	versions, err := getMirrorVersions()
+	if err != nil {
+		return "", err
+	}
+
+	for _, v := range versions {
+		if v.Version != version {
+			continue
+		}
+		if !v.Extracted {
+			log.Printf("Extracting %s...", v.Path)
+			cmd := exec.Command("tar", "-xzf", v.Path, "-C", getIstioMirrorDir())
+			if output, err := cmd.CombinedOutput(); err != nil {
+				return "", fmt.Errorf("failed to extract %s: %v, output: %s", v.Path, err, output)
+			}
+		}
+		binary := filepath.Join(getIstioMirrorDir(), "istio-"+version, "bin", "istioctl")
+		if _, err := os.Stat(binary); err != nil {
+			return "", fmt.Errorf("istioctl not found for version %s: %v", version, err)
+		}
+		return binary, nil
+	}
+
+	return "", fmt.Errorf("version %s not found in mirror %s", version, getIstioMirrorDir())
+}
+
+// Revision the data plane of a namespace is selected for, or "" when not injected
+func namespaceRevision(namespace corev1.Namespace) string {
+	if revision := namespace.Labels["istio.io/rev"]; revision != "" {
+		return revision
+	}
+	if namespace.Labels["istio-injection"] == "enabled" {
+		return "default"
+	}
+	return ""
+}
+
+// Revision of the control plane that injected a pod's proxy
+func proxyRevision(pod corev1.Pod) string {
+	if revision := pod.Labels["istio.io/rev"]; revision != "" {
+		return revision
+	}
+	var status struct {
+		Revision string `json:"revision"`
+	}
+	if raw := pod.Annotations["sidecar.istio.io/status"]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &status); err == nil && status.Revision != "" {
+			return status.Revision
+		}
+	}
+	return "default"
+}
+
+func getIstioRevisions() ([]IstioRevision, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, err := clientset.AppsV1().Deployments(istioRootNamespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: "app=istiod",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []IstioRevision{}
+	for _, deployment := range deployments.Items {
+		revision := IstioRevision{
+			Revision:   deployment.Labels["istio.io/rev"],
+			Istiod:     deployment.Name,
+			Namespaces: []string{},
+			Ready:      fmt.Sprintf("%d/%d", deployment.Status.ReadyReplicas, deployment.Status.Replicas),
+		}
+		if revision.Revision == "" {
+			revision.Revision = "default"
+		}
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			if container.Name == "discovery" {
+				if i := strings.LastIndex(container.Image, ":"); i >= 0 {
+					revision.Version = strings.TrimSuffix(container.Image[i+1:], "-distroless")
+				}
+			}
+		}
+
+		for _, namespace := range namespaces.Items {
+			if namespaceRevision(namespace) == revision.Revision {
+				revision.Namespaces = append(revision.Namespaces, namespace.Name)
+			}
+		}
+		for _, pod := range pods.Items {
+			if hasIstioSidecar(pod) && proxyRevision(pod) == revision.Revision {
+				revision.Proxies++
+			}
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return compareIstioVersions(revisions[i].Version, revisions[j].Version) < 0
+	})
+	return revisions, nil
+}
+
+// Compare each injected namespace's target revision with the revisions of the proxies running in it
+func getRevisionMigration(namespaceFilter string) ([]NamespaceMigration, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := []NamespaceMigration{}
+	for _, namespace := range namespaces.Items {
+		if namespaceFilter != "" && namespace.Name != namespaceFilter {
+			continue
+		}
+		target := namespaceRevision(namespace)
+		if target == "" {
+			continue
+		}
+
+		pods, err := clientset.CoreV1().Pods(namespace.Name).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		migration := NamespaceMigration{
+			Namespace:      namespace.Name,
+			TargetRevision: target,
+			Proxies:        map[string]int{},
+			Pending:        []string{},
+			WithoutProxy:   []string{},
+		}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			if !hasIstioSidecar(pod) {
+				migration.WithoutProxy = append(migration.WithoutProxy, pod.Name)
+				continue
+			}
+			revision := proxyRevision(pod)
+			migration.Proxies[revision]++
+			if revision != target {
+				migration.Pending = append(migration.Pending, pod.Name)
+			}
+		}
+		migration.Complete = len(migration.Pending) == 0 && len(migration.WithoutProxy) == 0
+		migrations = append(migrations, migration)
+	}
+
+	return migrations, nil
+}
+
+func findIstioRevision(revisions []IstioRevision, name string) *IstioRevision {
+	for i := range revisions {
+		if revisions[i].Revision == name {
+			return &revisions[i]
+		}
+	}
+	return nil
+}
+
+// Point a namespace at a revision and optionally restart its deployments so
+// their pods are re-injected with the new proxy
+func moveNamespaceToRevision(namespace, revision string, restart bool) (string, error) {
+	output, err := runKubectl(nil, "label", "namespace", namespace, "istio-injection-", "istio.io/rev="+revision, "--overwrite")
+	if err != nil {
+		return "", err
+	}
+	result := strings.TrimSpace(string(output))
+
+	if restart {
+		restartOutput, err := runKubectl(nil, "rollout", "restart", "deployment", "-n", namespace)
+		if err != nil {
+			return result, fmt.Errorf("namespace relabelled but restart failed: %v", err)
+		}
+		result += "\n" + strings.TrimSpace(string(restartOutput))
+	}
+
+	return result, nil
+}
+
+func registerIstioRevisionRoutes(e *echo.Echo) {
+	// Releases available in the local mirror
+	e.GET("/api/istio/versions", func(c echo.Context) error {
+		versions, err := getMirrorVersions()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to read Istio mirror: %v", err),
+			})
+		}
+
+		if revisions, err := getIstioRevisions(); err == nil {
+			for i := range versions {
+				for _, revision := range revisions {
+					if revision.Version == versions[i].Version {
+						versions[i].Revisions = append(versions[i].Revisions, revision.Revision)
+					}
+				}
+			}
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"mirror":   getIstioMirrorDir(),
+			"versions": versions,
+			"count":    len(versions),
+		})
+	})
+
+	// Control-plane revisions running in the cluster
+	e.GET("/api/istio/revisions", func(c echo.Context) error {
+		revisions, err := getIstioRevisions()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list Istio revisions: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"revisions": revisions,
+			"count":     len(revisions),
+		})
+	})
+
+	// Install a mirrored version as a new revision next to the existing control plane
+	e.POST("/api/istio/revisions", func(c echo.Context) error {
+		var request struct {
+			Version string `json:"version"`
+			IstioInstallOptions
+		}
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid revision payload",
+			})
+		}
+		if request.Version == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "version is required",
+			})
+		}
+
+		options := request.IstioInstallOptions
+		if options.Revision == "" {
+			options.Revision = revisionForVersion(request.Version)
+		}
+		// Namespaces move to the new revision one at a time afterwards
+		options.InjectionNamespaces = []string{}
+		normalizeIstioInstallOptions(&options)
+
+		if errors := validateIstioInstallOptions(options); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		revisions, err := getIstioRevisions()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list Istio revisions: %v", err),
+			})
+		}
+		if findIstioRevision(revisions, options.Revision) != nil {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": fmt.Sprintf("Revision %s is already installed", options.Revision),
+			})
+		}
+
+		istioctl, err := istioctlForVersion(request.Version)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		if c.QueryParam("dry_run") == "true" {
+			manifest, resources, err := generateIstioManifest(istioctl, options)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{
+					"error": err.Error(),
+				})
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"revision":  options.Revision,
+				"manifest":  manifest,
+				"resources": resources,
+				"count":     len(resources),
+			})
+		}
+
+		output, _, err := installIstio(istioctl, options)
+		if err != nil {
+			log.Printf("Error installing Istio revision %s: %v", options.Revision, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to install revision: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusCreated, map[string]interface{}{
+			"success":  true,
+			"message":  fmt.Sprintf("Istio %s installed as revision %s", request.Version, options.Revision),
+			"revision": options.Revision,
+			"output":   output,
+		})
+	})
+
+	// Proxy migration progress per injected namespace
+	e.GET("/api/istio/revisions/migration", func(c echo.Context) error {
+		migrations, err := getRevisionMigration(c.QueryParam("namespace"))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to check proxy migration: %v", err),
+			})
+		}
+
+		complete := true
+		for _, migration := range migrations {
+			if !migration.Complete {
+				complete = false
+			}
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"namespaces": migrations,
+			"complete":   complete,
+			"count":      len(migrations),
+		})
+	})
+
+	// Move a namespace to another revision
+	e.PUT("/api/istio/namespaces/:namespace/revision", func(c echo.Context) error {
+		namespace := c.Param("namespace")
+		var request struct {
+			Revision string `json:"revision"`
+			Restart  bool   `json:"restart"`
+		}
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid revision payload",
+			})
+		}
+
+		revisions, err := getIstioRevisions()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list Istio revisions: %v", err),
+			})
+		}
+		if findIstioRevision(revisions, request.Revision) == nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("Revision %q is not installed", request.Revision),
+			})
+		}
+
+		output, err := moveNamespaceToRevision(namespace, request.Revision, request.Restart)
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "Namespace not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to move namespace: %v", err),
+			})
+		}
+
+		message := fmt.Sprintf("Namespace %s now uses revision %s", namespace, request.Revision)
+		if !request.Restart {
+			message += "; restart its workloads to pick up the new proxy"
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": message,
+			"output":  output,
+		})
+	})
+
+	// Remove a revision once no namespace or proxy uses it
+	e.DELETE("/api/istio/revisions/:revision", func(c echo.Context) error {
+		name := c.Param("revision")
+		force := c.QueryParam("force") == "true"
+
+		revisions, err := getIstioRevisions()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list Istio revisions: %v", err),
+			})
+		}
+		revision := findIstioRevision(revisions, name)
+		if revision == nil {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Revision not found",
+			})
+		}
+		if len(revisions) == 1 {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Cannot remove the only control-plane revision; use the Istio uninstall instead",
+			})
+		}
+		if len(revision.Namespaces) > 0 {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":      "Namespaces still target this revision",
+				"namespaces": revision.Namespaces,
+			})
+		}
+		if revision.Proxies > 0 && !force {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":   fmt.Sprintf("%d proxies still run revision %s; restart them or retry with force=true", revision.Proxies, name),
+				"proxies": revision.Proxies,
+			})
+		}
+
+		istioctl := "istioctl"
+		if binary, err := istioctlForVersion(revision.Version); err == nil {
+			istioctl = binary
+		}
+
+		cmd := exec.Command(istioctl, "uninstall", "--revision", name, "-y")
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			log.Printf("Error removing Istio revision %s: %v, output: %s", name, err, output)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to remove revision: %v, output: %s", err, output),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Revision %s removed", name),
+			"output":  string(output),
+		})
+	})
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+type ServiceEntry struct {
+	Name       string             `json:"name"`
+	Namespace  string             `json:"namespace"`
+	Hosts      []string           `json:"hosts"`
+	Addresses  []string           `json:"addresses,omitempty"`
+	Ports      []ServiceEntryPort `json:"ports"`
+	Location   string             `json:"location"`
+	Resolution string             `json:"resolution"`
+	Endpoints  []WorkloadEntry    `json:"endpoints,omitempty"`
+	ExportTo   []string           `json:"export_to,omitempty"`
+}
+
+// Port and endpoint types follow the Istio CRD field names
+
+type ServiceEntryPort struct {
+	Number     uint32 `json:"number"`
+	Protocol   string `json:"protocol"`
+	Name       string `json:"name"`
+	TargetPort uint32 `json:"targetPort,omitempty"`
+}
+
+type WorkloadEntry struct {
+	Address  string            `json:"address"`
+	Ports    map[string]uint32 `json:"ports,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Network  string            `json:"network,omitempty"`
+	Locality string            `json:"locality,omitempty"`
+	Weight   uint32            `json:"weight,omitempty"`
+}
+
+type serviceEntrySpec struct {
+	Hosts      []string           `json:"hosts"`
+	Addresses  []string           `json:"addresses,omitempty"`
+	Ports      []ServiceEntryPort `json:"ports,omitempty"`
+	Location   string             `json:"location,omitempty"`
+	Resolution string             `json:"resolution"`
+	Endpoints  []WorkloadEntry    `json:"endpoints,omitempty"`
+	ExportTo   []string           `json:"exportTo,omitempty"`
+}
+
+// Mesh-wide outbound traffic policy from the istio mesh config
+type OutboundTrafficPolicy struct {
+	Mode        string `json:"mode"`
+	Source      string `json:"source"`
+	Description string `json:"description"`
+}
+
+var validServiceEntryResolutions = map[string]bool{
+	"NONE":            true,
+	"STATIC":          true,
+	"DNS":             true,
+	"DNS_ROUND_ROBIN": true,
+}
+
+func getServiceEntries(namespace string) ([]ServiceEntry, error) {
+	items, err := kubectlListResources("serviceentries.networking.istio.io", namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceEntries := []ServiceEntry{}
+	for _, item := range items {
+		se, err := serviceEntryFromResource(item)
+		if err != nil {
+			log.Printf("Skipping ServiceEntry %s/%s: %v", item.Metadata.Namespace, item.Metadata.Name, err)
+			continue
+		}
+		serviceEntries = append(serviceEntries, *se)
+	}
+
+	return serviceEntries, nil
+}
+
+func getServiceEntry(namespace, name string) (*ServiceEntry, error) {
+	resource, err := kubectlGetResource("serviceentries.networking.istio.io", namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return serviceEntryFromResource(*resource)
+}
+
+func serviceEntryFromResource(resource k8sRawResource) (*ServiceEntry, error) {
+	var spec serviceEntrySpec
+	if err := json.Unmarshal(resource.Spec, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %v", err)
+	}
+
+	se := &ServiceEntry{
+		Name:       resource.Metadata.Name,
+		Namespace:  resource.Metadata.Namespace,
+		Hosts:      spec.Hosts,
+		Addresses:  spec.Addresses,
+		Ports:      spec.Ports,
+		Location:   spec.Location,
+		Resolution: spec.Resolution,
+		Endpoints:  spec.Endpoints,
+		ExportTo:   spec.ExportTo,
+	}
+	if se.Location == "" {
+		se.Location = "MESH_EXTERNAL"
+	}
+	if se.Ports == nil {
+		se.Ports = []ServiceEntryPort{}
+	}
+	return se, nil
+}
+
+func serviceEntryManifest(se ServiceEntry) k8sResource {
+	return k8sResource{
+		APIVersion: "networking.istio.io/v1beta1",
+		Kind:       "ServiceEntry",
+		Metadata: k8sObjectMeta{
+			Name:      se.Name,
+			Namespace: se.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: serviceEntrySpec{
+			Hosts:      se.Hosts,
+			Addresses:  se.Addresses,
+			Ports:      se.Ports,
+			Location:   se.Location,
+			Resolution: se.Resolution,
+			Endpoints:  se.Endpoints,
+			ExportTo:   se.ExportTo,
+		},
+	}
+}
+
+func validateServiceEntry(se ServiceEntry) []string {
+	var errors []string
+
+	if se.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	if len(se.Hosts) == 0 {
+		errors = append(errors, "at least one host is required")
+	}
+	for _, host := range se.Hosts {
+		if host == "*" {
+			errors = append(errors, "host \"*\" is not allowed")
+		}
+		if strings.Contains(host, "/") {
+			errors = append(errors, fmt.Sprintf("host %q must not include a namespace", host))
+		}
+	}
+	for _, address := range se.Addresses {
+		if !validIPBlock(address) {
+			errors = append(errors, fmt.Sprintf("invalid address %q", address))
+		}
+	}
+
+	if se.Location != "MESH_EXTERNAL" && se.Location != "MESH_INTERNAL" {
+		errors = append(errors, fmt.Sprintf("unsupported location %q (use MESH_EXTERNAL or MESH_INTERNAL)", se.Location))
+	}
+	if !validServiceEntryResolutions[se.Resolution] {
+		errors = append(errors, fmt.Sprintf("unsupported resolution %q (use NONE, STATIC, DNS or DNS_ROUND_ROBIN)", se.Resolution))
+	}
+
+	names := make(map[string]bool)
+	for i, port := range se.Ports {
+		if port.Number < 1 || port.Number > 65535 {
+			errors = append(errors, fmt.Sprintf("ports[%d]: number must be between 1 and 65535", i))
+		}
+		if port.Name == "" {
+			errors = append(errors, fmt.Sprintf("ports[%d]: name is required", i))
+		} else if names[port.Name] {
+			errors = append(errors, fmt.Sprintf("ports[%d]: name %q is used by another port", i, port.Name))
+		}
+		names[port.Name] = true
+		if !validGatewayProtocols[port.Protocol] {
+			errors = append(errors, fmt.Sprintf("ports[%d]: unsupported protocol %q", i, port.Protocol))
+		}
+	}
+
+	switch se.Resolution {
+	case "STATIC":
+		if len(se.Endpoints) == 0 {
+			errors = append(errors, "STATIC resolution requires endpoints")
+		}
+		for i, endpoint := range se.Endpoints {
+			if net.ParseIP(endpoint.Address) == nil && !strings.HasPrefix(endpoint.Address, "unix://") {
+				errors = append(errors, fmt.Sprintf("endpoints[%d]: STATIC resolution requires an IP address, got %q", i, endpoint.Address))
+			}
+		}
+	case "DNS", "DNS_ROUND_ROBIN":
+		if len(se.Endpoints) == 0 {
+			for _, host := range se.Hosts {
+				if strings.HasPrefix(host, "*") {
+					errors = append(errors, fmt.Sprintf("DNS resolution without endpoints cannot use wildcard host %q", host))
+				}
+			}
+		}
+	case "NONE":
+		if len(se.Endpoints) > 0 {
+			errors = append(errors, "NONE resolution does not use endpoints")
+		}
+	}
+
+	for i, endpoint := range se.Endpoints {
+		if endpoint.Address == "" {
+			errors = append(errors, fmt.Sprintf("endpoints[%d]: address is required", i))
+		}
+		for name := range endpoint.Ports {
+			if !names[name] {
+				errors = append(errors, fmt.Sprintf("endpoints[%d]: port %q is not declared in ports", i, name))
+			}
+		}
+	}
+
+	return errors
+}
+
+func dryRunServiceEntry(se ServiceEntry, create bool) []string {
+	if errors := validateServiceEntry(se); len(errors) > 0 {
+		return errors
+	}
+	if err := kubectlDryRun(serviceEntryManifest(se), create); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+func normalizeServiceEntry(se *ServiceEntry) {
+	if se.Namespace == "" {
+		se.Namespace = "default"
+	}
+	if se.Location == "" {
+		se.Location = "MESH_EXTERNAL"
+	}
+	if se.Resolution == "" {
+		se.Resolution = "DNS"
+	}
+	for i := range se.Ports {
+		se.Ports[i].Protocol = strings.ToUpper(se.Ports[i].Protocol)
+		if se.Ports[i].Name == "" {
+			se.Ports[i].Name = fmt.Sprintf("%s-%d", strings.ToLower(se.Ports[i].Protocol), se.Ports[i].Number)
+		}
+	}
+}
+
+// Read outboundTrafficPolicy from the mesh config in the istio ConfigMap.
+// Istio defaults to ALLOW_ANY when the field is unset.
+func getOutboundTrafficPolicy() (*OutboundTrafficPolicy, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &OutboundTrafficPolicy{Mode: "ALLOW_ANY", Source: "default"}
+
+	configMap, err := clientset.CoreV1().ConfigMaps(istioRootNamespace).Get(context.Background(), "istio", metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var meshConfig struct {
+		OutboundTrafficPolicy struct {
+			Mode string `json:"mode"`
+		} `json:"outboundTrafficPolicy"`
+	}
+	if err := yaml.Unmarshal([]byte(configMap.Data["mesh"]), &meshConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse mesh config: %v", err)
+	}
+	if mode := meshConfig.OutboundTrafficPolicy.Mode; mode != "" {
+		policy.Mode = mode
+		policy.Source = fmt.Sprintf("configmap %s/istio", istioRootNamespace)
+	}
+
+	if policy.Mode == "REGISTRY_ONLY" {
+		policy.Description = "Sidecars only reach external hosts that have a ServiceEntry"
+	} else {
+		policy.Description = "Sidecars pass through traffic to any external host"
+	}
+	return policy, nil
+}
+
+func registerServiceEntryRoutes(e *echo.Echo) {
+	// List ServiceEntries together with the mesh outbound traffic policy
+	e.GET("/api/istio/serviceentries", func(c echo.Context) error {
+		serviceEntries, err := getServiceEntries(c.QueryParam("namespace"))
+		if err != nil {
+			log.Printf("Error listing ServiceEntries: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list ServiceEntries: %v", err),
+			})
+		}
+
+		response := map[string]interface{}{
+			"service_entries": serviceEntries,
+			"count":           len(serviceEntries),
+		}
+		if policy, err := getOutboundTrafficPolicy(); err == nil {
+			response["outbound_traffic_policy"] = policy
+		} else {
+			log.Printf("Warning: Could not read outbound traffic policy: %v", err)
+		}
+
+		return c.JSON(http.StatusOK, response)
+	})
+
+	// Mesh outbound traffic policy mode
+	e.GET("/api/istio/outbound-traffic-policy", func(c echo.Context) error {
+		policy, err := getOutboundTrafficPolicy()
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "Istio mesh config not found; is Istio installed?",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to read outbound traffic policy: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, policy)
+	})
+
+	// Validate a ServiceEntry without applying it
+	e.POST("/api/istio/serviceentries/validate", func(c echo.Context) error {
+		var se ServiceEntry
+		if err := c.Bind(&se); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid ServiceEntry payload",
+			})
+		}
+		normalizeServiceEntry(&se)
+
+		if errors := dryRunServiceEntry(se, false); len(errors) > 0 {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"valid":   true,
+			"message": "ServiceEntry is valid",
+		})
+	})
+
+	// Get a single ServiceEntry
+	e.GET("/api/istio/serviceentries/:namespace/:name", func(c echo.Context) error {
+		se, err := getServiceEntry(c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "ServiceEntry not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get ServiceEntry: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, se)
+	})
+
+	// Create a ServiceEntry
+	e.POST("/api/istio/serviceentries", func(c echo.Context) error {
+		var se ServiceEntry
+		if err := c.Bind(&se); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid ServiceEntry payload",
+			})
+		}
+		normalizeServiceEntry(&se)
+
+		if errors := dryRunServiceEntry(se, true); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		output, err := kubectlCreate(serviceEntryManifest(se), false)
+		if err != nil {
+			log.Printf("Error creating ServiceEntry: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to create ServiceEntry: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusCreated, map[string]interface{}{
+			"success":       true,
+			"message":       "ServiceEntry created successfully",
+			"output":        output,
+			"service_entry": se,
+		})
+	})
+
+	// Update a ServiceEntry
+	e.PUT("/api/istio/serviceentries/:namespace/:name", func(c echo.Context) error {
+		var se ServiceEntry
+		if err := c.Bind(&se); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid ServiceEntry payload",
+			})
+		}
+		se.Namespace = c.Param("namespace")
+		se.Name = c.Param("name")
+		normalizeServiceEntry(&se)
+
+		if _, err := getServiceEntry(se.Namespace, se.Name); err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "ServiceEntry not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get ServiceEntry: %v", err),
+			})
+		}
+
+		if errors := dryRunServiceEntry(se, false); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		output, err := kubectlApply(serviceEntryManifest(se), false)
+		if err != nil {
+			log.Printf("Error updating ServiceEntry: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to update ServiceEntry: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success":       true,
+			"message":       "ServiceEntry updated successfully",
+			"output":        output,
+			"service_entry": se,
+		})
+	})
+
+	// Delete a ServiceEntry
+	e.DELETE("/api/istio/serviceentries/:namespace/:name", func(c echo.Context) error {
+		output, err := kubectlDeleteResource("serviceentries.networking.istio.io", c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "ServiceEntry not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to delete ServiceEntry: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": output,
+		})
+	})
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Istio uninstall is destructive, so it runs in two steps: the plan endpoint
+// reports what will be removed and issues a short-lived confirmation token,
+// and the uninstall endpoint only proceeds with that token. CRDs and the
+// root namespace, with whatever else lives in it, are only deleted on
+// explicit request (purge_crds, delete_namespace).
+
+const uninstallTokenTTL = 5 * time.Minute
+
+var istioDataPlaneLabels = []string{"istio-injection", "istio.io/rev", "istio.io/dataplane-mode"}
+
+type IstioUninstallPlan struct {
+	Token       string            `json:"token"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	Revisions   []IstioRevision   `json:"revisions"`
+	Namespaces  []string          `json:"namespaces"`
+	CRDs        []string          `json:"crds"`
+	SidecarPods []SidecarWorkload `json:"sidecar_workloads"`
+	// Deleted only with delete_namespace=true
+	RootNamespace RootNamespaceContents `json:"root_namespace"`
+}
+
+// Objects in the Istio root namespace that deleting it would take along
+type RootNamespaceContents struct {
+	Name       string   `json:"name"`
+	Exists     bool     `json:"exists"`
+	Secrets    []string `json:"secrets"`
+	ConfigMaps []string `json:"config_maps"`
+	Workloads  []string `json:"workloads"`
+	Services   []string `json:"services"`
+}
+
+// Workload whose pods still run an istio-proxy and need a restart
+type SidecarWorkload struct {
+	Namespace      string `json:"namespace"`
+	Workload       string `json:"workload"`
+	Pods           int    `json:"pods"`
+	RestartCommand string `json:"restart_command"`
+}
+
+var (
+	uninstallTokens      = make(map[string]time.Time)
+	uninstallTokensMutex sync.Mutex
+)
+
+func issueUninstallToken() (string, time.Time, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(buf)
+	expiresAt := time.Now().Add(uninstallTokenTTL)
+
+	uninstallTokensMutex.Lock()
+	defer uninstallTokensMutex.Unlock()
+	for existing, expiry := range uninstallTokens {
+		if time.Now().After(expiry) {
+			delete(uninstallTokens, existing)
+		}
+	}
+	uninstallTokens[token] = expiresAt
+
+	return token, expiresAt, nil
+}
+
+// Tokens are single use
+func consumeUninstallToken(token string) bool {
+	uninstallTokensMutex.Lock()
+	defer uninstallTokensMutex.Unlock()
+
+	expiry, ok := uninstallTokens[token]
+	if !ok {
+		return false
+	}
+	delete(uninstallTokens, token)
+	return time.Now().Before(expiry)
+}
+
+// Namespaces carrying any Istio data-plane label
+func getIstioLabelledNamespaces() ([]string, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var labelled []string
+	for _, namespace := range namespaces.Items {
+		for _, label := range istioDataPlaneLabels {
+			if _, ok := namespace.Labels[label]; ok {
+				labelled = append(labelled, namespace.Name)
+				break
+			}
+		}
+	}
+	return labelled, nil
+}
+
+func getSidecarWorkloads() ([]SidecarWorkload, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	byWorkload := make(map[string]*SidecarWorkload)
+	for _, pod := range pods.Items {
+		if !hasIstioSidecar(pod) || pod.Namespace == istioRootNamespace {
+			continue
+		}
+
+		name := podWorkloadName(pod)
+		key := pod.Namespace + "/" + name
+		if byWorkload[key] == nil {
+			kind := "deployment"
+			for _, owner := range pod.OwnerReferences {
+				switch owner.Kind {
+				case "StatefulSet":
+					kind = "statefulset"
+				case "DaemonSet":
+					kind = "daemonset"
+				}
+			}
+			byWorkload[key] = &SidecarWorkload{
+				Namespace:      pod.Namespace,
+				Workload:       name,
+				RestartCommand: fmt.Sprintf("kubectl rollout restart %s/%s -n %s", kind, name, pod.Namespace),
+			}
+		}
+		byWorkload[key].Pods++
+	}
+
+	workloads := []SidecarWorkload{}
+	for _, workload := range byWorkload {
+		workloads = append(workloads, *workload)
+	}
+	sort.Slice(workloads, func(i, j int) bool {
+		if workloads[i].Namespace != workloads[j].Namespace {
+			return workloads[i].Namespace < workloads[j].Namespace
+		}
+		return workloads[i].Workload < workloads[j].Workload
+	})
+	return workloads, nil
+}
+
+func getIstioCRDs() ([]string, error) {
+	output, err := runKubectl(nil, "get", "crd", "-o", "name")
+	if err != nil {
+		return nil, err
+	}
+
+	var crds []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		name := strings.TrimPrefix(strings.TrimSpace(line), "customresourcedefinition.apiextensions.k8s.io/")
+		if strings.HasSuffix(name, ".istio.io") {
+			crds = append(crds, name)
+		}
+	}
+	return crds, nil
+}
+
+func getRootNamespaceContents() (RootNamespaceContents, error) {
+	contents := RootNamespaceContents{
+		Name:       istioRootNamespace,
+		Secrets:    []string{},
+		ConfigMaps: []string{},
+		Workloads:  []string{},
+		Services:   []string{},
+	}
+
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return contents, err
+	}
+	ctx := context.Background()
+
+	if _, err := clientset.CoreV1().Namespaces().Get(ctx, istioRootNamespace, metav1.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return contents, nil
+		}
+		return contents, err
+	}
+	contents.Exists = true
+
+	secrets, err := clientset.CoreV1().Secrets(istioRootNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return contents, err
+	}
+	for _, secret := range secrets.Items {
+		// Token secrets go away with their service accounts anyway
+		if secret.Type != corev1.SecretTypeServiceAccountToken {
+			contents.Secrets = append(contents.Secrets, secret.Name)
+		}
+	}
+
+	configMaps, err := clientset.CoreV1().ConfigMaps(istioRootNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return contents, err
+	}
+	for _, configMap := range configMaps.Items {
+		if configMap.Name != "kube-root-ca.crt" {
+			contents.ConfigMaps = append(contents.ConfigMaps, configMap.Name)
+		}
+	}
+
+	deployments, err := clientset.AppsV1().Deployments(istioRootNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return contents, err
+	}
+	for _, deployment := range deployments.Items {
+		contents.Workloads = append(contents.Workloads, "deployment/"+deployment.Name)
+	}
+	daemonSets, err := clientset.AppsV1().DaemonSets(istioRootNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return contents, err
+	}
+	for _, daemonSet := range daemonSets.Items {
+		contents.Workloads = append(contents.Workloads, "daemonset/"+daemonSet.Name)
+	}
+
+	services, err := clientset.CoreV1().Services(istioRootNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return contents, err
+	}
+	for _, service := range services.Items {
+		contents.Services = append(contents.Services, service.Name)
+	}
+
+	return contents, nil
+}
+
+func buildIstioUninstallPlan() (*IstioUninstallPlan, error) {
+	plan := &IstioUninstallPlan{}
+
+	revisions, err := getIstioRevisions()
+	if err != nil {
+		return nil, err
+	}
+	plan.Revisions = revisions
+
+	if plan.Namespaces, err = getIstioLabelledNamespaces(); err != nil {
+		return nil, err
+	}
+	if plan.SidecarPods, err = getSidecarWorkloads(); err != nil {
+		return nil, err
+	}
+	if plan.CRDs, err = getIstioCRDs(); err != nil {
+		return nil, err
+	}
+	if plan.RootNamespace, err = getRootNamespaceContents(); err != nil {
+		return nil, err
+	}
+	if plan.Namespaces == nil {
+		plan.Namespaces = []string{}
+	}
+	if plan.CRDs == nil {
+		plan.CRDs = []string{}
+	}
+
+	return plan, nil
+}
+
+func registerIstioUninstallRoutes(e *echo.Echo) {
+	// Describe what an uninstall removes and issue a confirmation token
+	e.POST("/api/istio/uninstall/plan", func(c echo.Context) error {
+		plan, err := buildIstioUninstallPlan()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to build uninstall plan: %v", err),
+			})
+		}
+
+		token, expiresAt, err := issueUninstallToken()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to issue confirmation token: %v", err),
+			})
+		}
+		plan.Token = token
+		plan.ExpiresAt = expiresAt
+
+		return c.JSON(http.StatusOK, plan)
+	})
+
+	// Purge the control plane and the data-plane labels, and optionally the
+	// CRDs (purge_crds=true) and the root namespace (delete_namespace=true)
+	e.DELETE("/api/istio/uninstall", func(c echo.Context) error {
+		if !consumeUninstallToken(c.QueryParam("confirm")) {
+			return c.JSON(http.StatusPreconditionFailed, map[string]string{
+				"error": "A valid confirmation token is required; request one from POST /api/istio/uninstall/plan",
+			})
+		}
+		purgeCRDs := c.QueryParam("purge_crds") == "true"
+		deleteNamespace := c.QueryParam("delete_namespace") == "true"
+
+		if err := checkIstioCLI(); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Istio CLI not found. Please download it first.",
+			})
+		}
+
+		log.Println("Uninstalling Istio...")
+		uninstallCmd := exec.Command("istioctl", "uninstall", "--purge", "-y")
+		uninstallOutput, err := uninstallCmd.CombinedOutput()
+		if err != nil {
+			log.Printf("Error uninstalling Istio: %v, output: %s", err, uninstallOutput)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to uninstall Istio: %v, output: %s", err, uninstallOutput),
+			})
+		}
+
+		var warnings []string
+		namespaceDeleted := false
+		if deleteNamespace {
+			if _, err := runKubectl(nil, "delete", "namespace", istioRootNamespace, "--ignore-not-found"); err != nil {
+				warnings = append(warnings, fmt.Sprintf("Failed to delete namespace %s: %v", istioRootNamespace, err))
+			} else {
+				namespaceDeleted = true
+			}
+		}
+
+		unlabelled := []string{}
+		namespaces, err := getIstioLabelledNamespaces()
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Failed to list labelled namespaces: %v", err))
+		}
+		for _, namespace := range namespaces {
+			args := []string{"label", "namespace", namespace}
+			for _, label := range istioDataPlaneLabels {
+				args = append(args, label+"-")
+			}
+			if _, err := runKubectl(nil, args...); err != nil {
+				warnings = append(warnings, fmt.Sprintf("Failed to remove Istio labels from %s: %v", namespace, err))
+				continue
+			}
+			unlabelled = append(unlabelled, namespace)
+		}
+
+		deletedCRDs := []string{}
+		if purgeCRDs {
+			crds, err := getIstioCRDs()
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("Failed to list Istio CRDs: %v", err))
+			}
+			for _, crd := range crds {
+				if _, err := runKubectl(nil, "delete", "crd", crd, "--ignore-not-found"); err != nil {
+					warnings = append(warnings, fmt.Sprintf("Failed to delete CRD %s: %v", crd, err))
+					continue
+				}
+				deletedCRDs = append(deletedCRDs, crd)
+			}
+		}
+
+		workloads, err := getSidecarWorkloads()
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Failed to check remaining sidecars: %v", err))
+			workloads = []SidecarWorkload{}
+		}
+		if warnings == nil {
+			warnings = []string{}
+		}
+
+		message := "Istio uninstalled successfully"
+		if len(workloads) > 0 {
+			message = fmt.Sprintf("Istio uninstalled; %d workloads still run sidecars and need restarting", len(workloads))
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success":               true,
+			"message":               message,
+			"output":                string(uninstallOutput),
+			"unlabelled_namespaces": unlabelled,
+			"deleted_crds":          deletedCRDs,
+			"namespace_deleted":     namespaceDeleted,
+			"sidecar_workloads":     workloads,
+			"warnings":              warnings,
+		})
+	})
+}
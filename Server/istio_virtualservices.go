@@ -0,0 +1,524 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// Istio VirtualService route types. Field names follow the Istio CRD schema
+// (networking.istio.io/v1beta1). The structs only model what validation and
+// analysis need; see HTTPRoute for how the remaining fields are kept.
+
+// An HTTP route. Routes decoded from JSON keep the original document and
+// encode back to it unchanged, so fields the struct does not model (headers,
+// corsPolicy, mirrors, ...) survive a GET/PUT round trip. Routes built in code
+// have no original document and are encoded from the typed fields.
+type HTTPRoute struct {
+	Name             string                 `json:"name,omitempty"`
+	Match            []HTTPMatchRequest     `json:"match,omitempty"`
+	Route            []HTTPRouteDestination `json:"route,omitempty"`
+	Redirect         *HTTPRedirect          `json:"redirect,omitempty"`
+	DirectResponse   json.RawMessage        `json:"directResponse,omitempty"`
+	Delegate         *Delegate              `json:"delegate,omitempty"`
+	Rewrite          *HTTPRewrite           `json:"rewrite,omitempty"`
+	Timeout          string                 `json:"timeout,omitempty"`
+	Retries          *HTTPRetry             `json:"retries,omitempty"`
+	Mirror           *Destination           `json:"mirror,omitempty"`
+	MirrorPercentage *Percent               `json:"mirrorPercentage,omitempty"`
+	Fault            *HTTPFaultInjection    `json:"fault,omitempty"`
+
+	raw json.RawMessage
+}
+
+// httpRouteFields has the fields of HTTPRoute without its JSON methods
+type httpRouteFields HTTPRoute
+
+func (r *HTTPRoute) UnmarshalJSON(data []byte) error {
+	var fields httpRouteFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*r = HTTPRoute(fields)
+	r.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (r HTTPRoute) MarshalJSON() ([]byte, error) {
+	if r.raw != nil {
+		return r.raw, nil
+	}
+	return json.Marshal(httpRouteFields(r))
+}
+
+type HTTPMatchRequest struct {
+	Name           string                 `json:"name,omitempty"`
+	URI            *StringMatch           `json:"uri,omitempty"`
+	Method         *StringMatch           `json:"method,omitempty"`
+	Authority      *StringMatch           `json:"authority,omitempty"`
+	Headers        map[string]StringMatch `json:"headers,omitempty"`
+	WithoutHeaders map[string]StringMatch `json:"withoutHeaders,omitempty"`
+	QueryParams    map[string]StringMatch `json:"queryParams,omitempty"`
+	IgnoreURICase  bool                   `json:"ignoreUriCase,omitempty"`
+	SourceLabels   map[string]string      `json:"sourceLabels,omitempty"`
+	Port           uint32                 `json:"port,omitempty"`
+	Gateways       []string               `json:"gateways,omitempty"`
+}
+
+type Delegate struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type StringMatch struct {
+	Exact  string `json:"exact,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Regex  string `json:"regex,omitempty"`
+}
+
+type HTTPRouteDestination struct {
+	Destination Destination `json:"destination"`
+	Weight      int32       `json:"weight,omitempty"`
+}
+
+type Destination struct {
+	Host   string        `json:"host"`
+	Subset string        `json:"subset,omitempty"`
+	Port   *PortSelector `json:"port,omitempty"`
+}
+
+type PortSelector struct {
+	Number uint32 `json:"number"`
+}
+
+type HTTPRedirect struct {
+	URI          string `json:"uri,omitempty"`
+	Authority    string `json:"authority,omitempty"`
+	RedirectCode uint32 `json:"redirectCode,omitempty"`
+}
+
+type HTTPRewrite struct {
+	URI       string `json:"uri,omitempty"`
+	Authority string `json:"authority,omitempty"`
+}
+
+type HTTPRetry struct {
+	Attempts      int32  `json:"attempts"`
+	PerTryTimeout string `json:"perTryTimeout,omitempty"`
+	RetryOn       string `json:"retryOn,omitempty"`
+}
+
+type Percent struct {
+	Value float64 `json:"value"`
+}
+
+type HTTPFaultInjection struct {
+	Delay *FaultDelay `json:"delay,omitempty"`
+	Abort *FaultAbort `json:"abort,omitempty"`
+}
+
+type FaultDelay struct {
+	FixedDelay string   `json:"fixedDelay"`
+	Percentage *Percent `json:"percentage,omitempty"`
+}
+
+type FaultAbort struct {
+	HTTPStatus int32    `json:"httpStatus"`
+	Percentage *Percent `json:"percentage,omitempty"`
+}
+
+// virtualServiceSpec is the CRD spec as stored in the cluster
+type virtualServiceSpec struct {
+	Hosts    []string                 `json:"hosts"`
+	Gateways []string                 `json:"gateways,omitempty"`
+	ExportTo []string                 `json:"exportTo,omitempty"`
+	HTTP     []HTTPRoute              `json:"http,omitempty"`
+	TCP      []map[string]interface{} `json:"tcp,omitempty"`
+	TLS      []map[string]interface{} `json:"tls,omitempty"`
+}
+
+// Get Virtual Services using kubectl, across all namespaces when namespace is empty
+func getVirtualServices(namespace string) ([]VirtualService, error) {
+	items, err := kubectlListResources("virtualservices.networking.istio.io", namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	virtualServices := []VirtualService{}
+	for _, item := range items {
+		vs, err := virtualServiceFromResource(item)
+		if err != nil {
+			log.Printf("Skipping VirtualService %s/%s: %v", item.Metadata.Namespace, item.Metadata.Name, err)
+			continue
+		}
+		virtualServices = append(virtualServices, *vs)
+	}
+
+	return virtualServices, nil
+}
+
+func getVirtualService(namespace, name string) (*VirtualService, error) {
+	resource, err := kubectlGetResource("virtualservices.networking.istio.io", namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return virtualServiceFromResource(*resource)
+}
+
+func virtualServiceFromResource(resource k8sRawResource) (*VirtualService, error) {
+	var spec virtualServiceSpec
+	if err := json.Unmarshal(resource.Spec, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %v", err)
+	}
+
+	return &VirtualService{
+		Name:      resource.Metadata.Name,
+		Namespace: resource.Metadata.Namespace,
+		Hosts:     spec.Hosts,
+		Gateways:  spec.Gateways,
+		ExportTo:  spec.ExportTo,
+		HTTP:      spec.HTTP,
+		TCP:       spec.TCP,
+		TLS:       spec.TLS,
+	}, nil
+}
+
+func virtualServiceManifest(vs VirtualService) k8sResource {
+	return k8sResource{
+		APIVersion: "networking.istio.io/v1beta1",
+		Kind:       "VirtualService",
+		Metadata: k8sObjectMeta{
+			Name:      vs.Name,
+			Namespace: vs.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: virtualServiceSpec{
+			Hosts:    vs.Hosts,
+			Gateways: vs.Gateways,
+			ExportTo: vs.ExportTo,
+			HTTP:     vs.HTTP,
+			TCP:      vs.TCP,
+			TLS:      vs.TLS,
+		},
+	}
+}
+
+// Check a VirtualService for mistakes the API server would accept but Envoy
+// would reject or silently ignore
+func validateVirtualService(vs VirtualService) []string {
+	var errors []string
+
+	if vs.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	if len(vs.Hosts) == 0 {
+		errors = append(errors, "at least one host is required")
+	}
+
+	for i, route := range vs.HTTP {
+		field := fmt.Sprintf("http[%d]", i)
+
+		actions := 0
+		for _, set := range []bool{len(route.Route) > 0, route.Redirect != nil, len(route.DirectResponse) > 0, route.Delegate != nil} {
+			if set {
+				actions++
+			}
+		}
+		if actions == 0 {
+			errors = append(errors, fmt.Sprintf("%s: a route, redirect, directResponse or delegate is required", field))
+		}
+		if actions > 1 {
+			errors = append(errors, fmt.Sprintf("%s: route, redirect, directResponse and delegate are mutually exclusive", field))
+		}
+		if route.Delegate != nil && route.Delegate.Name == "" {
+			errors = append(errors, fmt.Sprintf("%s.delegate: name is required", field))
+		}
+		if route.Redirect != nil && route.Rewrite != nil {
+			errors = append(errors, fmt.Sprintf("%s: rewrite cannot be combined with redirect", field))
+		}
+
+		for j, match := range route.Match {
+			matchField := fmt.Sprintf("%s.match[%d]", field, j)
+			for name, sm := range map[string]*StringMatch{"uri": match.URI, "method": match.Method, "authority": match.Authority} {
+				if sm != nil {
+					errors = append(errors, validateStringMatch(matchField+"."+name, *sm)...)
+				}
+			}
+			for header, sm := range match.Headers {
+				errors = append(errors, validateStringMatch(matchField+".headers."+header, sm)...)
+			}
+			for header, sm := range match.WithoutHeaders {
+				errors = append(errors, validateStringMatch(matchField+".withoutHeaders."+header, sm)...)
+			}
+			for param, sm := range match.QueryParams {
+				errors = append(errors, validateStringMatch(matchField+".queryParams."+param, sm)...)
+			}
+		}
+
+		totalWeight := int32(0)
+		for j, dest := range route.Route {
+			if dest.Destination.Host == "" {
+				errors = append(errors, fmt.Sprintf("%s.route[%d]: destination host is required", field, j))
+			}
+			if dest.Weight < 0 || dest.Weight > 100 {
+				errors = append(errors, fmt.Sprintf("%s.route[%d]: weight must be between 0 and 100", field, j))
+			}
+			totalWeight += dest.Weight
+		}
+		if len(route.Route) > 1 && totalWeight != 100 {
+			errors = append(errors, fmt.Sprintf("%s: destination weights sum to %d, expected 100", field, totalWeight))
+		}
+
+		if route.Timeout != "" {
+			if _, err := time.ParseDuration(route.Timeout); err != nil {
+				errors = append(errors, fmt.Sprintf("%s.timeout: invalid duration %q", field, route.Timeout))
+			}
+		}
+
+		if route.Retries != nil {
+			if route.Retries.Attempts < 0 {
+				errors = append(errors, fmt.Sprintf("%s.retries.attempts must not be negative", field))
+			}
+			if route.Retries.PerTryTimeout != "" {
+				if _, err := time.ParseDuration(route.Retries.PerTryTimeout); err != nil {
+					errors = append(errors, fmt.Sprintf("%s.retries.perTryTimeout: invalid duration %q", field, route.Retries.PerTryTimeout))
+				}
+			}
+		}
+
+		if route.Mirror != nil && route.Mirror.Host == "" {
+			errors = append(errors, fmt.Sprintf("%s.mirror: host is required", field))
+		}
+		if route.MirrorPercentage != nil {
+			if route.Mirror == nil {
+				errors = append(errors, fmt.Sprintf("%s.mirrorPercentage is set without a mirror", field))
+			}
+			if route.MirrorPercentage.Value < 0 || route.MirrorPercentage.Value > 100 {
+				errors = append(errors, fmt.Sprintf("%s.mirrorPercentage must be between 0 and 100", field))
+			}
+		}
+
+		if fault := route.Fault; fault != nil {
+			if fault.Delay == nil && fault.Abort == nil {
+				errors = append(errors, fmt.Sprintf("%s.fault: a delay or abort is required", field))
+			}
+			if fault.Delay != nil {
+				if _, err := time.ParseDuration(fault.Delay.FixedDelay); err != nil {
+					errors = append(errors, fmt.Sprintf("%s.fault.delay.fixedDelay: invalid duration %q", field, fault.Delay.FixedDelay))
+				}
+				if p := fault.Delay.Percentage; p != nil && (p.Value < 0 || p.Value > 100) {
+					errors = append(errors, fmt.Sprintf("%s.fault.delay.percentage must be between 0 and 100", field))
+				}
+			}
+			if fault.Abort != nil {
+				if fault.Abort.HTTPStatus < 200 || fault.Abort.HTTPStatus > 599 {
+					errors = append(errors, fmt.Sprintf("%s.fault.abort.httpStatus must be a valid HTTP status", field))
+				}
+				if p := fault.Abort.Percentage; p != nil && (p.Value < 0 || p.Value > 100) {
+					errors = append(errors, fmt.Sprintf("%s.fault.abort.percentage must be between 0 and 100", field))
+				}
+			}
+		}
+	}
+
+	return errors
+}
+
+func validateStringMatch(field string, sm StringMatch) []string {
+	set := 0
+	for _, v := range []string{sm.Exact, sm.Prefix, sm.Regex} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return []string{fmt.Sprintf("%s: exactly one of exact, prefix or regex is required", field)}
+	}
+
+	// Envoy uses RE2, the same dialect as Go's regexp package
+	if sm.Regex != "" {
+		if _, err := regexp.Compile(sm.Regex); err != nil {
+			return []string{fmt.Sprintf("%s: invalid regex: %v", field, err)}
+		}
+	}
+	return nil
+}
+
+// Validate a VirtualService locally and then with a server-side dry run.
+// The returned slice is empty when the resource is safe to apply.
+func dryRunVirtualService(vs VirtualService, create bool) []string {
+	if errors := validateVirtualService(vs); len(errors) > 0 {
+		return errors
+	}
+
+	if err := kubectlDryRun(virtualServiceManifest(vs), create); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+func registerVirtualServiceRoutes(e *echo.Echo) {
+	// List VirtualServices, optionally filtered by namespace
+	e.GET("/api/istio/virtualservices", func(c echo.Context) error {
+		virtualServices, err := getVirtualServices(c.QueryParam("namespace"))
+		if err != nil {
+			log.Printf("Error listing VirtualServices: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to list VirtualServices: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"virtual_services": virtualServices,
+			"count":            len(virtualServices),
+		})
+	})
+
+	// Get a single VirtualService
+	e.GET("/api/istio/virtualservices/:namespace/:name", func(c echo.Context) error {
+		vs, err := getVirtualService(c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "VirtualService not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get VirtualService: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, vs)
+	})
+
+	// Validate a VirtualService without applying it
+	e.POST("/api/istio/virtualservices/validate", func(c echo.Context) error {
+		var vs VirtualService
+		if err := c.Bind(&vs); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid VirtualService payload",
+			})
+		}
+		if vs.Namespace == "" {
+			vs.Namespace = "default"
+		}
+
+		if errors := dryRunVirtualService(vs, false); len(errors) > 0 {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"valid":   true,
+			"message": "VirtualService is valid",
+		})
+	})
+
+	// Create a VirtualService
+	e.POST("/api/istio/virtualservices", func(c echo.Context) error {
+		var vs VirtualService
+		if err := c.Bind(&vs); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid VirtualService payload",
+			})
+		}
+		if vs.Namespace == "" {
+			vs.Namespace = "default"
+		}
+
+		if errors := dryRunVirtualService(vs, true); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		output, err := kubectlCreate(virtualServiceManifest(vs), false)
+		if err != nil {
+			log.Printf("Error creating VirtualService: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to create VirtualService: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusCreated, map[string]interface{}{
+			"success":         true,
+			"message":         "VirtualService created successfully",
+			"output":          output,
+			"virtual_service": vs,
+		})
+	})
+
+	// Update a VirtualService
+	e.PUT("/api/istio/virtualservices/:namespace/:name", func(c echo.Context) error {
+		var vs VirtualService
+		if err := c.Bind(&vs); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid VirtualService payload",
+			})
+		}
+		vs.Namespace = c.Param("namespace")
+		vs.Name = c.Param("name")
+
+		if _, err := getVirtualService(vs.Namespace, vs.Name); err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "VirtualService not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get VirtualService: %v", err),
+			})
+		}
+
+		if errors := dryRunVirtualService(vs, false); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		output, err := kubectlApply(virtualServiceManifest(vs), false)
+		if err != nil {
+			log.Printf("Error updating VirtualService: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to update VirtualService: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success":         true,
+			"message":         "VirtualService updated successfully",
+			"output":          output,
+			"virtual_service": vs,
+		})
+	})
+
+	// Delete a VirtualService
+	e.DELETE("/api/istio/virtualservices/:namespace/:name", func(c echo.Context) error {
+		output, err := kubectlDeleteResource("virtualservices.networking.istio.io", c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "VirtualService not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to delete VirtualService: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": strings.TrimSpace(output),
+		})
+	})
+}
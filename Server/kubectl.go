@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Generic helpers for reading and writing Kubernetes resources. Typed core
+// resources go through client-go; mesh CRDs go through kubectl with JSON
+// manifests so Meshify does not need the generated clients for every mesh.
+
+type k8sObjectMeta struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// k8sResource is the manifest shape we pipe into kubectl
+type k8sResource struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   k8sObjectMeta `json:"metadata"`
+	Spec       interface{}   `json:"spec,omitempty"`
+}
+
+// k8sRawResource is a resource read back from kubectl with the spec left
+// undecoded so each caller can unmarshal it into its own type. Specs is
+// only used by Cilium policies, which may carry a list of specs.
+type k8sRawResource struct {
+	Kind     string          `json:"kind"`
+	Metadata k8sObjectMeta   `json:"metadata"`
+	Spec     json.RawMessage `json:"spec"`
+	Specs    json.RawMessage `json:"specs,omitempty"`
+	Status   json.RawMessage `json:"status,omitempty"`
+}
+
+type k8sRawList struct {
+	Items []k8sRawResource `json:"items"`
+}
+
+// Fields of a JSON object other than the modelled ones, so a typed spec can
+// be written back without dropping what it does not know about
+func unmodelledFields(object json.RawMessage, modelled ...string) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if len(object) == 0 {
+		return fields, nil
+	}
+	if err := json.Unmarshal(object, &fields); err != nil {
+		return nil, err
+	}
+	for _, key := range modelled {
+		delete(fields, key)
+	}
+	return fields, nil
+}
+
+// Encode a typed object and add back the fields kept by unmodelledFields.
+// Typed fields win when both are set.
+func withUnmodelledFields(object interface{}, extra map[string]json.RawMessage) (json.RawMessage, error) {
+	data, err := json.Marshal(object)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range extra {
+		if _, ok := fields[key]; !ok {
+			fields[key] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// Load the in-cluster config, falling back to the local kubeconfig
+func getKubeConfig() (*rest.Config, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		config, err = clientcmd.BuildConfigFromFlags("", os.Getenv("HOME")+"/.kube/config")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Kubernetes configuration: %v", err)
+		}
+	}
+	return config, nil
+}
+
+func getKubeClientset() (*kubernetes.Clientset, error) {
+	config, err := getKubeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes clientset: %v", err)
+	}
+
+	return clientset, nil
+}
+
+// List resources of a kind, across all namespaces when namespace is empty
+func kubectlListResources(kind, namespace string) ([]k8sRawResource, error) {
+	args := []string{"get", kind, "-o", "json"}
+	if namespace == "" {
+		args = append(args, "-A")
+	} else {
+		args = append(args, "-n", namespace)
+	}
+
+	output, err := runKubectl(nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var list k8sRawList
+	if err := json.Unmarshal(output, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s list: %v", kind, err)
+	}
+
+	return list.Items, nil
+}
+
+// Get a single resource by name
+func kubectlGetResource(kind, namespace, name string) (*k8sRawResource, error) {
+	output, err := runKubectl(nil, "get", kind, name, "-n", namespace, "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var resource k8sRawResource
+	if err := json.Unmarshal(output, &resource); err != nil {
+		return nil, fmt.Errorf("failed to parse %s %s/%s: %v", kind, namespace, name, err)
+	}
+
+	return &resource, nil
+}
+
+// Apply a manifest. With dryRun set the API server validates the object,
+// including admission webhooks, without persisting it.
+func kubectlApply(manifest interface{}, dryRun bool) (string, error) {
+	return kubectlWrite("apply", manifest, dryRun)
+}
+
+// Create a manifest, failing if the resource already exists
+func kubectlCreate(manifest interface{}, dryRun bool) (string, error) {
+	return kubectlWrite("create", manifest, dryRun)
+}
+
+// Validate a manifest with a server-side dry run of create or apply
+func kubectlDryRun(manifest interface{}, create bool) error {
+	write := kubectlApply
+	if create {
+		write = kubectlCreate
+	}
+	_, err := write(manifest, true)
+	return err
+}
+
+func kubectlWrite(verb string, manifest interface{}, dryRun bool) (string, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode manifest: %v", err)
+	}
+
+	args := []string{verb, "-f", "-"}
+	if dryRun {
+		args = append(args, "--dry-run=server")
+	}
+
+	output, err := runKubectl(data, args...)
+	return strings.TrimSpace(string(output)), err
+}
+
+// Patch a resource in place. patchType is json, merge or strategic; only the
+// fields named in the patch change, unlike apply, which writes the whole
+// object.
+func kubectlPatch(kind, namespace, name, patchType string, patch interface{}, dryRun bool) (string, error) {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode patch: %v", err)
+	}
+
+	args := []string{"patch", kind, name, "-n", namespace, "--type", patchType, "-p", string(data)}
+	if dryRun {
+		args = append(args, "--dry-run=server")
+	}
+
+	output, err := runKubectl(nil, args...)
+	return strings.TrimSpace(string(output)), err
+}
+
+// Delete a resource by name
+func kubectlDeleteResource(kind, namespace, name string) (string, error) {
+	output, err := runKubectl(nil, "delete", kind, name, "-n", namespace)
+	return strings.TrimSpace(string(output)), err
+}
+
+// Run kubectl, returning stdout on success and folding stderr into the error
+func runKubectl(stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("kubectl", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return output, fmt.Errorf("kubectl %s: %s", strings.Join(args[:min(len(args), 2)], " "), msg)
+	}
+
+	return output, nil
+}
+
+// Report whether a kubectl error means the resource does not exist
+func isNotFoundError(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "NotFound") || strings.Contains(err.Error(), "not found"))
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Linkerd identity certificates. The trust anchor is a long-lived root that
+// every proxy trusts; the issuer is an intermediate CA signed by it that the
+// identity controller uses to sign proxy certificates. Meshify generates both
+// at install time and keeps the trust anchor key in its own secret so the
+// issuer can later be rotated without touching the trust bundle.
+
+const (
+	linkerdNamespace           = "linkerd"
+	linkerdIssuerSecret        = "linkerd-identity-issuer"
+	linkerdTrustRootsConfig    = "linkerd-identity-trust-roots"
+	meshifyTrustAnchorSecret   = "meshify-linkerd-trust-anchor"
+	trustAnchorValidity        = 10 * 365 * 24 * time.Hour
+	issuerValidity             = 365 * 24 * time.Hour
+	defaultCertificateWarnDays = 30
+)
+
+type LinkerdCertificate struct {
+	Role          string    `json:"role"`
+	Subject       string    `json:"subject"`
+	Issuer        string    `json:"issuer"`
+	SerialNumber  string    `json:"serial_number"`
+	NotBefore     time.Time `json:"not_before"`
+	NotAfter      time.Time `json:"not_after"`
+	DaysRemaining int       `json:"days_remaining"`
+	Status        string    `json:"status"`
+}
+
+type LinkerdCertificateStatus struct {
+	TrustAnchors     []LinkerdCertificate `json:"trust_anchors"`
+	Issuer           *LinkerdCertificate  `json:"issuer"`
+	ExternalIssuer   bool                 `json:"external_issuer"`
+	AnchorKeyManaged bool                 `json:"anchor_key_managed"`
+	Warnings         []string             `json:"warnings"`
+}
+
+type IssuerRotationRequest struct {
+	ValidityHours   int    `json:"validity_hours"`
+	TrustAnchorCert string `json:"trust_anchor_cert"`
+	TrustAnchorKey  string `json:"trust_anchor_key"`
+}
+
+// PEM encoded certificate and key pair
+type linkerdCertPair struct {
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+type linkerdIdentityCerts struct {
+	TrustAnchor linkerdCertPair
+	Issuer      linkerdCertPair
+}
+
+func randomSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// Create a CA certificate. A nil parent makes it self-signed.
+func createCACertificate(commonName string, validity time.Duration, maxPathLen int, parent *x509.Certificate, parentKey crypto.Signer) (linkerdCertPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return linkerdCertPair{}, fmt.Errorf("failed to generate key: %v", err)
+	}
+	serial, err := randomSerialNumber()
+	if err != nil {
+		return linkerdCertPair{}, fmt.Errorf("failed to generate serial number: %v", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            maxPathLen,
+		MaxPathLenZero:        maxPathLen == 0,
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return linkerdCertPair{}, fmt.Errorf("failed to sign %s: %v", commonName, err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return linkerdCertPair{}, fmt.Errorf("failed to encode key: %v", err)
+	}
+
+	return linkerdCertPair{
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+func parseCertificatePEM(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// All certificates of a PEM bundle
+func parseCertificateBundle(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+	return certs, nil
+}
+
+// EC keys as Linkerd and step write them, or PKCS#8
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key found")
+	}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type")
+		}
+		return signer, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+func generateLinkerdIdentityCerts() (*linkerdIdentityCerts, error) {
+	anchor, err := createCACertificate("root.linkerd.cluster.local", trustAnchorValidity, 1, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := signLinkerdIssuer(anchor, issuerValidity)
+	if err != nil {
+		return nil, err
+	}
+	return &linkerdIdentityCerts{TrustAnchor: anchor, Issuer: issuer}, nil
+}
+
+func signLinkerdIssuer(anchor linkerdCertPair, validity time.Duration) (linkerdCertPair, error) {
+	anchorCert, err := parseCertificatePEM(anchor.CertPEM)
+	if err != nil {
+		return linkerdCertPair{}, fmt.Errorf("invalid trust anchor certificate: %v", err)
+	}
+	anchorKey, err := parsePrivateKeyPEM(anchor.KeyPEM)
+	if err != nil {
+		return linkerdCertPair{}, fmt.Errorf("invalid trust anchor key: %v", err)
+	}
+	if !anchorCert.IsCA {
+		return linkerdCertPair{}, fmt.Errorf("trust anchor is not a CA certificate")
+	}
+	if time.Now().Add(validity).After(anchorCert.NotAfter) {
+		return linkerdCertPair{}, fmt.Errorf("issuer would outlive the trust anchor, which expires %s", anchorCert.NotAfter.Format(time.RFC3339))
+	}
+	return createCACertificate("identity.linkerd.cluster.local", validity, 0, anchorCert, anchorKey)
+}
+
+// Write the certificates to a temporary directory and return the linkerd
+// install flags pointing at them along with a cleanup function
+func linkerdInstallCertArgs(certs *linkerdIdentityCerts) ([]string, func(), error) {
+	dir, err := os.MkdirTemp("", "meshify-linkerd-certs")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	files := map[string][]byte{
+		"ca.crt":     certs.TrustAnchor.CertPEM,
+		"issuer.crt": certs.Issuer.CertPEM,
+		"issuer.key": certs.Issuer.KeyPEM,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+	}
+
+	return []string{
+		"--identity-trust-anchors-file", filepath.Join(dir, "ca.crt"),
+		"--identity-issuer-certificate-file", filepath.Join(dir, "issuer.crt"),
+		"--identity-issuer-key-file", filepath.Join(dir, "issuer.key"),
+	}, cleanup, nil
+}
+
+// Keep the trust anchor key so the issuer can be rotated without supplying
+// it. Done unless the install opts out (store_trust_anchor_key: false).
+func storeLinkerdTrustAnchor(anchor linkerdCertPair) error {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      meshifyTrustAnchorSecret,
+			Namespace: linkerdNamespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       anchor.CertPEM,
+			corev1.TLSPrivateKeyKey: anchor.KeyPEM,
+		},
+	}
+
+	secrets := clientset.CoreV1().Secrets(linkerdNamespace)
+	if _, err := secrets.Create(context.Background(), secret, metav1.CreateOptions{}); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		_, err = secrets.Update(context.Background(), secret, metav1.UpdateOptions{})
+		return err
+	}
+	return nil
+}
+
+// Remove a trust anchor stored by an earlier install, which would no longer
+// match the one in use
+func deleteStoredLinkerdTrustAnchor() error {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return err
+	}
+	err = clientset.CoreV1().Secrets(linkerdNamespace).Delete(context.Background(), meshifyTrustAnchorSecret, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func loadLinkerdTrustAnchor() (*linkerdCertPair, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := clientset.CoreV1().Secrets(linkerdNamespace).Get(context.Background(), meshifyTrustAnchorSecret, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &linkerdCertPair{
+		CertPEM: secret.Data[corev1.TLSCertKey],
+		KeyPEM:  secret.Data[corev1.TLSPrivateKeyKey],
+	}, nil
+}
+
+func describeCertificate(role string, cert *x509.Certificate, warnDays int) LinkerdCertificate {
+	remaining := time.Until(cert.NotAfter)
+	description := LinkerdCertificate{
+		Role:          role,
+		Subject:       cert.Subject.CommonName,
+		Issuer:        cert.Issuer.CommonName,
+		SerialNumber:  cert.SerialNumber.Text(16),
+		NotBefore:     cert.NotBefore,
+		NotAfter:      cert.NotAfter,
+		DaysRemaining: int(remaining.Hours() / 24),
+		Status:        "valid",
+	}
+	switch {
+	case remaining <= 0:
+		description.Status = "expired"
+	case remaining < time.Duration(warnDays)*24*time.Hour:
+		description.Status = "expiring"
+	case time.Now().Before(cert.NotBefore):
+		description.Status = "not_yet_valid"
+	}
+	return description
+}
+
+func certificateWarning(cert LinkerdCertificate) string {
+	switch cert.Status {
+	case "expired":
+		return fmt.Sprintf("%s certificate %s expired on %s", cert.Role, cert.Subject, cert.NotAfter.Format("2006-01-02"))
+	case "expiring":
+		return fmt.Sprintf("%s certificate %s expires in %d days (%s)", cert.Role, cert.Subject, cert.DaysRemaining, cert.NotAfter.Format("2006-01-02"))
+	case "not_yet_valid":
+		return fmt.Sprintf("%s certificate %s is not valid before %s", cert.Role, cert.Subject, cert.NotBefore.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// Issuer certificate from the identity issuer secret. Linkerd's own scheme
+// stores crt.pem/key.pem; kubernetes.io/tls secrets (tls.crt/tls.key) mean an
+// external manager such as cert-manager owns the issuer.
+func getLinkerdIssuerSecret() (*corev1.Secret, []byte, bool, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	secret, err := clientset.CoreV1().Secrets(linkerdNamespace).Get(context.Background(), linkerdIssuerSecret, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if cert, ok := secret.Data[corev1.TLSCertKey]; ok {
+		return secret, cert, true, nil
+	}
+	return secret, secret.Data["crt.pem"], false, nil
+}
+
+func getLinkerdTrustBundle() ([]*x509.Certificate, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+	configMap, err := clientset.CoreV1().ConfigMaps(linkerdNamespace).Get(context.Background(), linkerdTrustRootsConfig, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return parseCertificateBundle([]byte(configMap.Data["ca-bundle.crt"]))
+}
+
+func getLinkerdCertificateStatus(warnDays int) (*LinkerdCertificateStatus, error) {
+	status := &LinkerdCertificateStatus{
+		TrustAnchors: []LinkerdCertificate{},
+		Warnings:     []string{},
+	}
+
+	anchors, err := getLinkerdTrustBundle()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust anchors: %v", err)
+	}
+	for _, anchor := range anchors {
+		status.TrustAnchors = append(status.TrustAnchors, describeCertificate("trust anchor", anchor, warnDays))
+	}
+
+	_, issuerPEM, external, err := getLinkerdIssuerSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issuer secret: %v", err)
+	}
+	status.ExternalIssuer = external
+	issuer, err := parseCertificatePEM(issuerPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse issuer certificate: %v", err)
+	}
+	issuerCert := describeCertificate("issuer", issuer, warnDays)
+	status.Issuer = &issuerCert
+
+	if _, err := loadLinkerdTrustAnchor(); err == nil {
+		status.AnchorKeyManaged = true
+	}
+
+	for _, cert := range append(status.TrustAnchors, issuerCert) {
+		if warning := certificateWarning(cert); warning != "" {
+			status.Warnings = append(status.Warnings, warning)
+		}
+	}
+
+	// Proxies reject an issuer that does not chain to a bundled anchor
+	pool := x509.NewCertPool()
+	for _, anchor := range anchors {
+		pool.AddCert(anchor)
+	}
+	if _, err := issuer.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		status.Warnings = append(status.Warnings, fmt.Sprintf("issuer certificate does not verify against the trust anchors: %v", err))
+	}
+
+	return status, nil
+}
+
+// Sign a new issuer with the trust anchor and replace the identity issuer
+// secret. The identity controller watches the secret and starts signing with
+// the new issuer; proxies pick it up as their certificates renew. Rotating
+// the trust anchor itself needs a bundle with both anchors and is not done
+// here.
+func rotateLinkerdIssuer(request IssuerRotationRequest) (*LinkerdCertificate, error) {
+	validity := issuerValidity
+	if request.ValidityHours > 0 {
+		validity = time.Duration(request.ValidityHours) * time.Hour
+	}
+
+	var anchor linkerdCertPair
+	if request.TrustAnchorCert != "" || request.TrustAnchorKey != "" {
+		anchor = linkerdCertPair{CertPEM: []byte(request.TrustAnchorCert), KeyPEM: []byte(request.TrustAnchorKey)}
+	} else {
+		stored, err := loadLinkerdTrustAnchor()
+		if err != nil {
+			if isNotFoundError(err) {
+				return nil, fmt.Errorf("no stored trust anchor key; provide trust_anchor_cert and trust_anchor_key")
+			}
+			return nil, err
+		}
+		anchor = *stored
+	}
+
+	anchorCert, err := parseCertificatePEM(anchor.CertPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trust anchor certificate: %v", err)
+	}
+	bundle, err := getLinkerdTrustBundle()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust anchors: %v", err)
+	}
+	trusted := false
+	for _, cert := range bundle {
+		if cert.Equal(anchorCert) {
+			trusted = true
+		}
+	}
+	if !trusted {
+		return nil, fmt.Errorf("trust anchor %s is not in the installed trust bundle", anchorCert.Subject.CommonName)
+	}
+
+	secret, _, external, err := getLinkerdIssuerSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issuer secret: %v", err)
+	}
+	if external {
+		return nil, fmt.Errorf("issuer secret is in kubernetes.io/tls format and managed externally")
+	}
+
+	issuer, err := signLinkerdIssuer(anchor, validity)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data["crt.pem"] = issuer.CertPEM
+	secret.Data["key.pem"] = issuer.KeyPEM
+	if _, err := clientset.CoreV1().Secrets(linkerdNamespace).Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to update issuer secret: %v", err)
+	}
+
+	cert, err := parseCertificatePEM(issuer.CertPEM)
+	if err != nil {
+		return nil, err
+	}
+	description := describeCertificate("issuer", cert, defaultCertificateWarnDays)
+	return &description, nil
+}
+
+func certificateWarnDays(c echo.Context) (int, error) {
+	value := c.QueryParam("warn_days")
+	if value == "" {
+		return defaultCertificateWarnDays, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid warn_days %q", value)
+	}
+	return days, nil
+}
+
+func registerLinkerdCertificateRoutes(e *echo.Echo) {
+	// Trust anchor and issuer expiry, with warnings for certificates that
+	// expire within warn_days
+	e.GET("/api/linkerd/certificates", func(c echo.Context) error {
+		warnDays, err := certificateWarnDays(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		status, err := getLinkerdCertificateStatus(warnDays)
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "Linkerd identity certificates not found; is Linkerd installed?",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get Linkerd certificates: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, status)
+	})
+
+	// Rotate the issuer certificate, signed by the stored or provided trust
+	// anchor
+	e.POST("/api/linkerd/certificates/issuer/rotate", func(c echo.Context) error {
+		var request IssuerRotationRequest
+		if err := bindOptional(c, &request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid rotation request",
+			})
+		}
+		if request.ValidityHours < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "validity_hours must not be negative",
+			})
+		}
+		if (request.TrustAnchorCert == "") != (request.TrustAnchorKey == "") {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "trust_anchor_cert and trust_anchor_key must be provided together",
+			})
+		}
+
+		issuer, err := rotateLinkerdIssuer(request)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to rotate issuer: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": "Issuer certificate rotated; the identity controller reloads it automatically",
+			"issuer":  issuer,
+		})
+	})
+}
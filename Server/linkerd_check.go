@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Structured linkerd check results. The JSON output groups checks by
+// category; each check has a result of success, warning or error and a hint
+// URL pointing at the troubleshooting docs.
+
+type LinkerdCheck struct {
+	Category string `json:"category"`
+	Check    string `json:"check"`
+	Status   string `json:"status"`
+	Hint     string `json:"hint,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type linkerdCheckOutput struct {
+	Success    bool `json:"success"`
+	Categories []struct {
+		CategoryName string `json:"categoryName"`
+		Checks       []struct {
+			Description string `json:"description"`
+			Hint        string `json:"hint"`
+			Error       string `json:"error"`
+			Result      string `json:"result"`
+		} `json:"checks"`
+	} `json:"categories"`
+}
+
+func parseLinkerdCheck(output []byte) ([]LinkerdCheck, error) {
+	var result linkerdCheckOutput
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse linkerd check output: %v", err)
+	}
+
+	checks := []LinkerdCheck{}
+	for _, category := range result.Categories {
+		for _, check := range category.Checks {
+			checks = append(checks, LinkerdCheck{
+				Category: category.CategoryName,
+				Check:    check.Description,
+				Status:   check.Result,
+				Hint:     check.Hint,
+				Error:    check.Error,
+			})
+		}
+	}
+	return checks, nil
+}
+
+// Run linkerd check. It exits non-zero when a check fails but still prints
+// the full JSON report, so the output is parsed regardless of the exit code.
+func runLinkerdCheck() ([]LinkerdCheck, error) {
+	cmd := exec.Command("linkerd", "check", "--output", "json", "--wait", "30s")
+	output, err := cmd.Output()
+	if len(output) == 0 && err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("linkerd check failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("linkerd check failed: %v", err)
+	}
+	return parseLinkerdCheck(output)
+}
+
+// Version of the installed control plane. Falls back to the created-by
+// annotation on the destination deployment ("linkerd/cli stable-2.14.1").
+func getLinkerdServerVersion() string {
+	cmd := exec.Command("linkerd", "version", "--server", "--short")
+	if output, err := cmd.Output(); err == nil {
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		if version := strings.TrimSpace(lines[len(lines)-1]); version != "" && version != "unavailable" {
+			return version
+		}
+	}
+
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return "Unknown"
+	}
+	deployment, err := clientset.AppsV1().Deployments("linkerd").Get(context.Background(), "linkerd-destination", metav1.GetOptions{})
+	if err != nil {
+		return "Unknown"
+	}
+	if fields := strings.Fields(deployment.Annotations["linkerd.io/created-by"]); len(fields) == 2 {
+		return fields[1]
+	}
+	return "Unknown"
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Linkerd proxy injection. The proxy injector reads linkerd.io/inject from
+// the pod template first and the namespace second, and only acts when a pod
+// is created, so changing either takes effect after a rollout restart.
+
+const linkerdInjectAnnotation = "linkerd.io/inject"
+
+var injectableKinds = map[string]string{
+	"deployment":   "deployment",
+	"deployments":  "deployment",
+	"statefulset":  "statefulset",
+	"statefulsets": "statefulset",
+	"daemonset":    "daemonset",
+	"daemonsets":   "daemonset",
+}
+
+type LinkerdInjectionRequest struct {
+	Enabled bool `json:"enabled"`
+	Restart bool `json:"restart"`
+}
+
+type LinkerdWorkloadInjection struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Annotation string `json:"annotation,omitempty"`
+	Enabled    bool   `json:"enabled"`
+	MeshedPods int    `json:"meshed_pods"`
+	TotalPods  int    `json:"total_pods"`
+}
+
+type LinkerdPodInjection struct {
+	Name         string `json:"name"`
+	Workload     string `json:"workload"`
+	Meshed       bool   `json:"meshed"`
+	Enabled      bool   `json:"enabled"`
+	NeedsRestart bool   `json:"needs_restart"`
+}
+
+type LinkerdInjectionStatus struct {
+	Namespace      string                     `json:"namespace"`
+	Annotation     string                     `json:"annotation,omitempty"`
+	Workloads      []LinkerdWorkloadInjection `json:"workloads"`
+	UnmeshedPods   []LinkerdPodInjection      `json:"unmeshed_pods"`
+	PendingRestart int                        `json:"pending_restart"`
+}
+
+// "enabled" and "ingress" both inject a proxy
+func injectionEnabled(podAnnotation, namespaceAnnotation string) bool {
+	value := namespaceAnnotation
+	if podAnnotation != "" {
+		value = podAnnotation
+	}
+	return value == "enabled" || value == "ingress"
+}
+
+func linkerdInjectionValue(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// Injectable workloads of a namespace with their pod template annotation
+func getInjectableWorkloads(namespace string) ([]LinkerdWorkloadInjection, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	var workloads []LinkerdWorkloadInjection
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments.Items {
+		workloads = append(workloads, LinkerdWorkloadInjection{Kind: "deployment", Name: d.Name, Annotation: d.Spec.Template.Annotations[linkerdInjectAnnotation]})
+	}
+	statefulSets, err := clientset.AppsV1().StatefulSets(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statefulSets.Items {
+		workloads = append(workloads, LinkerdWorkloadInjection{Kind: "statefulset", Name: s.Name, Annotation: s.Spec.Template.Annotations[linkerdInjectAnnotation]})
+	}
+	daemonSets, err := clientset.AppsV1().DaemonSets(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range daemonSets.Items {
+		workloads = append(workloads, LinkerdWorkloadInjection{Kind: "daemonset", Name: d.Name, Annotation: d.Spec.Template.Annotations[linkerdInjectAnnotation]})
+	}
+
+	return workloads, nil
+}
+
+func getLinkerdInjectionStatus(namespace string) (*LinkerdInjectionStatus, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := clientset.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	workloads, err := getInjectableWorkloads(namespace)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	status := &LinkerdInjectionStatus{
+		Namespace:    namespace,
+		Annotation:   ns.Annotations[linkerdInjectAnnotation],
+		Workloads:    []LinkerdWorkloadInjection{},
+		UnmeshedPods: []LinkerdPodInjection{},
+	}
+
+	byName := make(map[string]*LinkerdWorkloadInjection)
+	for i := range workloads {
+		workloads[i].Enabled = injectionEnabled(workloads[i].Annotation, status.Annotation)
+		byName[workloads[i].Name] = &workloads[i]
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		workloadName := podWorkloadName(pod)
+		podState := LinkerdPodInjection{
+			Name:     pod.Name,
+			Workload: workloadName,
+			Meshed:   hasLinkerdProxy(pod),
+			Enabled:  injectionEnabled(pod.Annotations[linkerdInjectAnnotation], status.Annotation),
+		}
+
+		// A pod created before the template changed still carries the old
+		// annotation, so compare with the workload's current intent
+		if workload := byName[workloadName]; workload != nil {
+			podState.Enabled = workload.Enabled
+			workload.TotalPods++
+			if podState.Meshed {
+				workload.MeshedPods++
+			}
+		}
+
+		podState.NeedsRestart = podState.Meshed != podState.Enabled
+		if podState.NeedsRestart {
+			status.PendingRestart++
+		}
+		if !podState.Meshed {
+			status.UnmeshedPods = append(status.UnmeshedPods, podState)
+		}
+	}
+
+	for _, workload := range workloads {
+		status.Workloads = append(status.Workloads, workload)
+	}
+	sort.Slice(status.Workloads, func(i, j int) bool {
+		if status.Workloads[i].Kind != status.Workloads[j].Kind {
+			return status.Workloads[i].Kind < status.Workloads[j].Kind
+		}
+		return status.Workloads[i].Name < status.Workloads[j].Name
+	})
+
+	return status, nil
+}
+
+// Restart every injectable workload in a namespace so new pods get (or lose)
+// the proxy
+func restartNamespaceWorkloads(namespace string) ([]string, error) {
+	workloads, err := getInjectableWorkloads(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	restarted := []string{}
+	var errors []string
+	for _, workload := range workloads {
+		ref := workload.Kind + "/" + workload.Name
+		if _, err := runKubectl(nil, "rollout", "restart", ref, "-n", namespace); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", ref, err))
+			continue
+		}
+		restarted = append(restarted, ref)
+	}
+
+	if len(errors) > 0 {
+		return restarted, fmt.Errorf("failed to restart %s", strings.Join(errors, "; "))
+	}
+	return restarted, nil
+}
+
+func setNamespaceInjection(namespace string, enabled bool) error {
+	_, err := runKubectl(nil, "annotate", "namespace", namespace,
+		linkerdInjectAnnotation+"="+linkerdInjectionValue(enabled), "--overwrite")
+	return err
+}
+
+// Annotate a workload's pod template. Changing the template rolls the
+// workload out on its own, so no separate restart is needed.
+func setWorkloadInjection(namespace, kind, name string, enabled bool) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{linkerdInjectAnnotation: linkerdInjectionValue(enabled)},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = runKubectl(nil, "patch", kind, name, "-n", namespace, "--type", "merge", "-p", string(patch))
+	return err
+}
+
+// Enable injection for a namespace and restart its workloads
+func injectLinkerdProxy(namespace string) error {
+	if err := setNamespaceInjection(namespace, true); err != nil {
+		return err
+	}
+	_, err := restartNamespaceWorkloads(namespace)
+	return err
+}
+
+func injectionStatusResponse(c echo.Context, namespace string, response map[string]interface{}) error {
+	status, err := getLinkerdInjectionStatus(namespace)
+	if err != nil {
+		if isNotFoundError(err) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": fmt.Sprintf("Namespace %s not found", namespace),
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("Failed to get injection status: %v", err),
+		})
+	}
+
+	if response == nil {
+		return c.JSON(http.StatusOK, status)
+	}
+	response["injection"] = status
+	return c.JSON(http.StatusOK, response)
+}
+
+func registerLinkerdInjectionRoutes(e *echo.Echo) {
+	// Injection settings and un-meshed pods of a namespace
+	e.GET("/api/linkerd/injection/:namespace", func(c echo.Context) error {
+		return injectionStatusResponse(c, c.Param("namespace"), nil)
+	})
+
+	// Enable or disable injection for a namespace, optionally restarting its
+	// workloads
+	e.PUT("/api/linkerd/injection/:namespace", func(c echo.Context) error {
+		namespace := c.Param("namespace")
+
+		var request LinkerdInjectionRequest
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid injection request",
+			})
+		}
+
+		if err := setNamespaceInjection(namespace, request.Enabled); err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": fmt.Sprintf("Namespace %s not found", namespace),
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to annotate namespace: %v", err),
+			})
+		}
+
+		response := map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Linkerd injection %s for namespace %s", linkerdInjectionValue(request.Enabled), namespace),
+		}
+		if request.Restart {
+			restarted, err := restartNamespaceWorkloads(namespace)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+					"error":     fmt.Sprintf("Namespace annotated but %v", err),
+					"restarted": restarted,
+				})
+			}
+			response["restarted"] = restarted
+		}
+
+		return injectionStatusResponse(c, namespace, response)
+	})
+
+	// Enable or disable injection for one workload
+	e.PUT("/api/linkerd/injection/:namespace/:kind/:name", func(c echo.Context) error {
+		namespace, name := c.Param("namespace"), c.Param("name")
+		kind, ok := injectableKinds[strings.ToLower(c.Param("kind"))]
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("unsupported workload kind %q (use deployment, statefulset or daemonset)", c.Param("kind")),
+			})
+		}
+
+		var request LinkerdInjectionRequest
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid injection request",
+			})
+		}
+
+		if err := setWorkloadInjection(namespace, kind, name, request.Enabled); err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": fmt.Sprintf("%s %s/%s not found", kind, namespace, name),
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to update %s: %v", kind, err),
+			})
+		}
+
+		return injectionStatusResponse(c, namespace, map[string]interface{}{
+			"success":   true,
+			"message":   fmt.Sprintf("Linkerd injection %s for %s/%s; the workload is rolling out", linkerdInjectionValue(request.Enabled), kind, name),
+			"restarted": []string{kind + "/" + name},
+		})
+	})
+
+	// Proxy injection status of the emojivoto sample, which always runs in
+	// its own namespace
+	e.GET("/api/linkerd/applications/:namespace/emojivoto/proxy", func(c echo.Context) error {
+		return injectionStatusResponse(c, "emojivoto", nil)
+	})
+}
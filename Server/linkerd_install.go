@@ -0,0 +1,464 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Configurable linkerd install and the viz, jaeger and multicluster
+// extensions. Since stable-2.12 the CRDs ship separately (linkerd install
+// --crds) and must exist before the control plane manifest is applied.
+
+type LinkerdInstallOptions struct {
+	HA                 bool              `json:"ha"`
+	CRDsFirst          *bool             `json:"crds_first,omitempty"`
+	ProxyCPURequest    string            `json:"proxy_cpu_request,omitempty"`
+	ProxyCPULimit      string            `json:"proxy_cpu_limit,omitempty"`
+	ProxyMemoryRequest string            `json:"proxy_memory_request,omitempty"`
+	ProxyMemoryLimit   string            `json:"proxy_memory_limit,omitempty"`
+	Set                map[string]string `json:"set,omitempty"`
+	// Keep the trust anchor key in a Secret for later issuer rotation.
+	// Anyone able to read it can mint mesh identities; set this to false to
+	// have the key returned once in the install response instead.
+	StoreTrustAnchorKey *bool `json:"store_trust_anchor_key,omitempty"`
+}
+
+type LinkerdExtensionOptions struct {
+	HA  bool              `json:"ha"`
+	Set map[string]string `json:"set,omitempty"`
+}
+
+type LinkerdExtension struct {
+	Name        string             `json:"name"`
+	Namespace   string             `json:"namespace"`
+	Description string             `json:"description"`
+	Installed   bool               `json:"installed"`
+	Components  []LinkerdComponent `json:"components"`
+}
+
+var linkerdExtensions = []LinkerdExtension{
+	{Name: "viz", Namespace: "linkerd-viz", Description: "Metrics, dashboard and tap"},
+	{Name: "jaeger", Namespace: "linkerd-jaeger", Description: "Distributed tracing with a collector and Jaeger"},
+	{Name: "multicluster", Namespace: "linkerd-multicluster", Description: "Service mirroring and gateways across clusters"},
+}
+
+func findLinkerdExtension(name string) (LinkerdExtension, bool) {
+	for _, extension := range linkerdExtensions {
+		if extension.Name == name {
+			return extension, true
+		}
+	}
+	return LinkerdExtension{}, false
+}
+
+// Namespaces scanned for control plane and extension components
+func linkerdComponentNamespaces() []string {
+	namespaces := []string{linkerdNamespace}
+	for _, extension := range linkerdExtensions {
+		namespaces = append(namespaces, extension.Namespace)
+	}
+	return namespaces
+}
+
+func normalizeLinkerdInstallOptions(options *LinkerdInstallOptions) {
+	if options.CRDsFirst == nil {
+		crdsFirst := true
+		options.CRDsFirst = &crdsFirst
+	}
+	if options.StoreTrustAnchorKey == nil {
+		storeTrustAnchorKey := true
+		options.StoreTrustAnchorKey = &storeTrustAnchorKey
+	}
+}
+
+func validateSetValues(set map[string]string) []string {
+	var errors []string
+	for key, value := range set {
+		if key == "" || strings.ContainsAny(key, " =") {
+			errors = append(errors, fmt.Sprintf("invalid set key %q", key))
+		}
+		if strings.ContainsAny(value, "\n") {
+			errors = append(errors, fmt.Sprintf("set value for %q must be a single line", key))
+		}
+	}
+	return errors
+}
+
+func validateLinkerdInstallOptions(options LinkerdInstallOptions) []string {
+	var errors []string
+
+	quantities := []struct {
+		field string
+		value string
+	}{
+		{"proxy_cpu_request", options.ProxyCPURequest},
+		{"proxy_cpu_limit", options.ProxyCPULimit},
+		{"proxy_memory_request", options.ProxyMemoryRequest},
+		{"proxy_memory_limit", options.ProxyMemoryLimit},
+	}
+	parsed := make(map[string]resource.Quantity)
+	for _, q := range quantities {
+		if q.value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(q.value)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: invalid quantity %q", q.field, q.value))
+			continue
+		}
+		parsed[q.field] = quantity
+	}
+
+	for _, pair := range [][2]string{{"proxy_cpu_request", "proxy_cpu_limit"}, {"proxy_memory_request", "proxy_memory_limit"}} {
+		request, hasRequest := parsed[pair[0]]
+		limit, hasLimit := parsed[pair[1]]
+		if hasRequest && hasLimit && request.Cmp(limit) > 0 {
+			errors = append(errors, fmt.Sprintf("%s must not exceed %s", pair[0], pair[1]))
+		}
+	}
+
+	return append(errors, validateSetValues(options.Set)...)
+}
+
+func sortedSetArgs(set map[string]string) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for _, key := range keys {
+		args = append(args, "--set", fmt.Sprintf("%s=%s", key, set[key]))
+	}
+	return args
+}
+
+// linkerd install flags for the control plane
+func linkerdInstallArgs(options LinkerdInstallOptions) []string {
+	args := []string{"install"}
+	if options.HA {
+		args = append(args, "--ha")
+	}
+	flags := []struct {
+		name  string
+		value string
+	}{
+		{"--proxy-cpu-request", options.ProxyCPURequest},
+		{"--proxy-cpu-limit", options.ProxyCPULimit},
+		{"--proxy-memory-request", options.ProxyMemoryRequest},
+		{"--proxy-memory-limit", options.ProxyMemoryLimit},
+	}
+	for _, flag := range flags {
+		if flag.value != "" {
+			args = append(args, flag.name, flag.value)
+		}
+	}
+	return append(args, sortedSetArgs(options.Set)...)
+}
+
+func runLinkerdCLI(args ...string) ([]byte, error) {
+	cmd := exec.Command("linkerd", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		name := strings.Join(args, " ")
+		if len(args) > 2 {
+			name = strings.Join(args[:2], " ")
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("linkerd %s failed: %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("linkerd %s failed: %v", name, err)
+	}
+	return output, nil
+}
+
+func applyManifestOutput(manifest []byte) (string, error) {
+	output, err := runKubectl(manifest, "apply", "-f", "-")
+	return strings.TrimSpace(string(output)), err
+}
+
+// Render the control plane manifest with the given options. The identity
+// certificates are only needed to render, so throwaway ones are used when
+// none are passed.
+func generateLinkerdManifest(options LinkerdInstallOptions, certs *linkerdIdentityCerts) ([]byte, error) {
+	if certs == nil {
+		generated, err := generateLinkerdIdentityCerts()
+		if err != nil {
+			return nil, err
+		}
+		certs = generated
+	}
+	certArgs, cleanup, err := linkerdInstallCertArgs(certs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write identity certificates: %v", err)
+	}
+	defer cleanup()
+
+	return runLinkerdCLI(append(linkerdInstallArgs(options), certArgs...)...)
+}
+
+// Install the CRDs (when requested) and the control plane with Meshify
+// generated identity certificates
+func installLinkerd(options LinkerdInstallOptions) (*linkerdIdentityCerts, []string, error) {
+	steps := []string{}
+
+	if *options.CRDsFirst {
+		log.Println("Installing Linkerd CRDs...")
+		crds, err := runLinkerdCLI("install", "--crds")
+		if err != nil {
+			return nil, steps, err
+		}
+		if _, err := applyManifestOutput(crds); err != nil {
+			return nil, steps, fmt.Errorf("failed to apply Linkerd CRDs: %v", err)
+		}
+		steps = append(steps, "crds")
+	}
+
+	// Generate the trust anchor and issuer instead of letting the CLI
+	// create throwaway ones, so their expiry can be tracked and the issuer
+	// rotated later
+	certs, err := generateLinkerdIdentityCerts()
+	if err != nil {
+		return nil, steps, fmt.Errorf("failed to generate identity certificates: %v", err)
+	}
+
+	log.Println("Installing Linkerd control plane...")
+	manifest, err := generateLinkerdManifest(options, certs)
+	if err != nil {
+		return nil, steps, err
+	}
+	if _, err := applyManifestOutput(manifest); err != nil {
+		return nil, steps, fmt.Errorf("failed to apply Linkerd manifests: %v", err)
+	}
+	steps = append(steps, "control-plane")
+
+	return certs, steps, nil
+}
+
+func getLinkerdExtensions() ([]LinkerdExtension, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+	components, err := getLinkerdComponents(clientset)
+	if err != nil {
+		return nil, err
+	}
+
+	extensions := []LinkerdExtension{}
+	for _, extension := range linkerdExtensions {
+		extension.Components = []LinkerdComponent{}
+		for _, component := range components {
+			if component.Namespace == extension.Namespace {
+				extension.Components = append(extension.Components, component)
+			}
+		}
+		extension.Installed = len(extension.Components) > 0
+		extensions = append(extensions, extension)
+	}
+	return extensions, nil
+}
+
+func installLinkerdExtension(extension LinkerdExtension, options LinkerdExtensionOptions) (string, error) {
+	args := []string{extension.Name, "install"}
+	if options.HA {
+		args = append(args, "--ha")
+	}
+	manifest, err := runLinkerdCLI(append(args, sortedSetArgs(options.Set)...)...)
+	if err != nil {
+		return "", err
+	}
+	return applyManifestOutput(manifest)
+}
+
+func uninstallLinkerdExtension(extension LinkerdExtension) (string, error) {
+	manifest, err := runLinkerdCLI(extension.Name, "uninstall")
+	if err != nil {
+		return "", err
+	}
+	output, err := runKubectl(manifest, "delete", "-f", "-", "--ignore-not-found")
+	return strings.TrimSpace(string(output)), err
+}
+
+// Options are optional, so an empty body installs with the defaults
+func bindOptional(c echo.Context, target interface{}) error {
+	if c.Request().ContentLength == 0 {
+		return nil
+	}
+	return c.Bind(target)
+}
+
+func registerLinkerdInstallRoutes(e *echo.Echo) {
+	// Install Linkerd
+	e.POST("/api/linkerd/install", func(c echo.Context) error {
+		response := map[string]interface{}{
+			"success": false,
+			"message": "",
+		}
+
+		var options LinkerdInstallOptions
+		if err := bindOptional(c, &options); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid install options",
+			})
+		}
+		normalizeLinkerdInstallOptions(&options)
+
+		if errors := validateLinkerdInstallOptions(options); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		certs, steps, err := installLinkerd(options)
+		response["steps"] = steps
+		if err != nil {
+			log.Printf("Error installing Linkerd: %v", err)
+			response["message"] = err.Error()
+			return c.JSON(http.StatusInternalServerError, response)
+		}
+
+		response["success"] = true
+		response["message"] = "Linkerd installed successfully"
+		response["options"] = options
+		if *options.StoreTrustAnchorKey {
+			if err := storeLinkerdTrustAnchor(certs.TrustAnchor); err != nil {
+				log.Printf("Warning: Failed to store Linkerd trust anchor: %v", err)
+				response["warning"] = fmt.Sprintf("Trust anchor key could not be stored, so issuer rotation will need it supplied: %v", err)
+				response["trust_anchor_cert"] = string(certs.TrustAnchor.CertPEM)
+				response["trust_anchor_key"] = string(certs.TrustAnchor.KeyPEM)
+			}
+		} else {
+			if err := deleteStoredLinkerdTrustAnchor(); err != nil {
+				log.Printf("Warning: Failed to remove previously stored Linkerd trust anchor: %v", err)
+			}
+			response["trust_anchor_cert"] = string(certs.TrustAnchor.CertPEM)
+			response["trust_anchor_key"] = string(certs.TrustAnchor.KeyPEM)
+			response["warning"] = "The trust anchor key is not stored in the cluster and is shown only once; keep it safe, issuer rotation requires it"
+		}
+		if cert, err := parseCertificatePEM(certs.Issuer.CertPEM); err == nil {
+			response["issuer_expiry"] = cert.NotAfter
+		}
+		if cert, err := parseCertificatePEM(certs.TrustAnchor.CertPEM); err == nil {
+			response["trust_anchor_expiry"] = cert.NotAfter
+		}
+		return c.JSON(http.StatusOK, response)
+	})
+
+	// Preview the control plane manifest an install would apply
+	e.POST("/api/linkerd/install/preview", func(c echo.Context) error {
+		var options LinkerdInstallOptions
+		if err := bindOptional(c, &options); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid install options",
+			})
+		}
+		normalizeLinkerdInstallOptions(&options)
+
+		if errors := validateLinkerdInstallOptions(options); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		manifest, err := generateLinkerdManifest(options, nil)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		resources := summarizeManifest(string(manifest))
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"options":   options,
+			"manifest":  string(manifest),
+			"resources": resources,
+			"count":     len(resources),
+		})
+	})
+
+	// Extensions with their install state and components
+	e.GET("/api/linkerd/extensions", func(c echo.Context) error {
+		extensions, err := getLinkerdExtensions()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get Linkerd extensions: %v", err),
+			})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"extensions": extensions,
+			"count":      len(extensions),
+		})
+	})
+
+	// Install an extension
+	e.POST("/api/linkerd/extensions/:name", func(c echo.Context) error {
+		extension, ok := findLinkerdExtension(c.Param("name"))
+		if !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": fmt.Sprintf("unknown extension %q (use viz, jaeger or multicluster)", c.Param("name")),
+			})
+		}
+
+		var options LinkerdExtensionOptions
+		if err := bindOptional(c, &options); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid extension options",
+			})
+		}
+		if errors := validateSetValues(options.Set); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		output, err := installLinkerdExtension(extension, options)
+		if err != nil {
+			log.Printf("Error installing Linkerd %s: %v", extension.Name, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to install %s: %v", extension.Name, err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Linkerd %s extension installed in %s", extension.Name, extension.Namespace),
+			"output":  output,
+		})
+	})
+
+	// Uninstall an extension
+	e.DELETE("/api/linkerd/extensions/:name", func(c echo.Context) error {
+		extension, ok := findLinkerdExtension(c.Param("name"))
+		if !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": fmt.Sprintf("unknown extension %q (use viz, jaeger or multicluster)", c.Param("name")),
+			})
+		}
+
+		output, err := uninstallLinkerdExtension(extension)
+		if err != nil {
+			log.Printf("Error uninstalling Linkerd %s: %v", extension.Name, err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to uninstall %s: %v", extension.Name, err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Linkerd %s extension uninstalled", extension.Name),
+			"output":  output,
+		})
+	})
+}
@@ -0,0 +1,1130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+// Linkerd policy resources: Server, AuthorizationPolicy,
+// MeshTLSAuthentication, NetworkAuthentication and HTTPRoutes, both the
+// policy.linkerd.io and the Gateway API (gateway.networking.k8s.io) kind.
+// A Server claims a port on a set of pods. Traffic that no
+// AuthorizationPolicy targeting the Server, one of its routes or its
+// namespace admits falls back to the Server's accessPolicy, deny by default.
+
+const (
+	linkerdTrustDomain = "cluster.local"
+
+	linkerdPolicyGroup = "policy.linkerd.io"
+	gatewayAPIGroup    = "gateway.networking.k8s.io"
+
+	defaultLinkerdClusterNetworks = "10.0.0.0/8,100.64.0.0/10,172.16.0.0/12,192.168.0.0/16,fd00::/8"
+)
+
+var validProxyProtocols = map[string]bool{
+	"unknown": true, "HTTP/1": true, "HTTP/2": true, "gRPC": true, "opaque": true, "TLS": true,
+}
+
+var validAccessPolicies = map[string]bool{
+	"deny": true, "all-unauthenticated": true, "all-authenticated": true,
+	"cluster-unauthenticated": true, "cluster-authenticated": true, "audit": true,
+}
+
+// Policy resource types, following the policy.linkerd.io CRD schemas
+
+type LinkerdServerSpec struct {
+	PodSelector   *metav1.LabelSelector `json:"podSelector,omitempty"`
+	Port          intstr.IntOrString    `json:"port"`
+	ProxyProtocol string                `json:"proxyProtocol,omitempty"`
+	AccessPolicy  string                `json:"accessPolicy,omitempty"`
+}
+
+type LinkerdServer struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	LinkerdServerSpec
+}
+
+type PolicyTargetRef struct {
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type LinkerdAuthorizationPolicySpec struct {
+	TargetRef                  PolicyTargetRef   `json:"targetRef"`
+	RequiredAuthenticationRefs []PolicyTargetRef `json:"requiredAuthenticationRefs"`
+}
+
+type LinkerdAuthorizationPolicy struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	LinkerdAuthorizationPolicySpec
+}
+
+type MeshTLSAuthenticationSpec struct {
+	Identities   []string          `json:"identities,omitempty"`
+	IdentityRefs []PolicyTargetRef `json:"identityRefs,omitempty"`
+}
+
+type MeshTLSAuthentication struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	MeshTLSAuthenticationSpec
+}
+
+type AuthenticatedNetwork struct {
+	CIDR   string   `json:"cidr"`
+	Except []string `json:"except,omitempty"`
+}
+
+type NetworkAuthenticationSpec struct {
+	Networks []AuthenticatedNetwork `json:"networks"`
+}
+
+type NetworkAuthentication struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	NetworkAuthenticationSpec
+}
+
+type RouteParentRef struct {
+	Group       string `json:"group,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace,omitempty"`
+	SectionName string `json:"sectionName,omitempty"`
+	Port        *int32 `json:"port,omitempty"`
+}
+
+type RoutePathMatch struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value"`
+}
+
+type RouteHeaderMatch struct {
+	Type  string `json:"type,omitempty"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type LinkerdRouteMatch struct {
+	Path        *RoutePathMatch    `json:"path,omitempty"`
+	Headers     []RouteHeaderMatch `json:"headers,omitempty"`
+	QueryParams []RouteHeaderMatch `json:"queryParams,omitempty"`
+	Method      string             `json:"method,omitempty"`
+}
+
+type RouteBackendRef struct {
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Port      *int32 `json:"port,omitempty"`
+	Weight    *int32 `json:"weight,omitempty"`
+}
+
+type LinkerdRouteRule struct {
+	Matches     []LinkerdRouteMatch      `json:"matches,omitempty"`
+	Filters     []map[string]interface{} `json:"filters,omitempty"`
+	BackendRefs []RouteBackendRef        `json:"backendRefs,omitempty"`
+}
+
+type LinkerdHTTPRouteSpec struct {
+	ParentRefs []RouteParentRef   `json:"parentRefs"`
+	Hostnames  []string           `json:"hostnames,omitempty"`
+	Rules      []LinkerdRouteRule `json:"rules,omitempty"`
+}
+
+type LinkerdHTTPRoute struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	LinkerdHTTPRouteSpec
+}
+
+// Gateway API HTTPRoute. Linkerd reads the same spec from either group and
+// prefers this one on current releases.
+type GatewayHTTPRoute struct {
+	LinkerdHTTPRoute
+}
+
+// Common behaviour of the policy resources so one set of CRUD routes serves
+// every kind
+type linkerdPolicyObject interface {
+	setMeta(namespace, name string)
+	manifest() k8sResource
+	validate() []string
+}
+
+type linkerdPolicyKind struct {
+	path     string
+	resource string
+	label    string
+	plural   string
+	singular string
+	new      func() linkerdPolicyObject
+}
+
+var linkerdPolicyKinds = []linkerdPolicyKind{
+	{"servers", "servers.policy.linkerd.io", "Server", "servers", "server",
+		func() linkerdPolicyObject { return &LinkerdServer{} }},
+	{"authorizationpolicies", "authorizationpolicies.policy.linkerd.io", "AuthorizationPolicy", "authorization_policies", "authorization_policy",
+		func() linkerdPolicyObject { return &LinkerdAuthorizationPolicy{} }},
+	{"meshtlsauthentications", "meshtlsauthentications.policy.linkerd.io", "MeshTLSAuthentication", "mesh_tls_authentications", "mesh_tls_authentication",
+		func() linkerdPolicyObject { return &MeshTLSAuthentication{} }},
+	{"networkauthentications", "networkauthentications.policy.linkerd.io", "NetworkAuthentication", "network_authentications", "network_authentication",
+		func() linkerdPolicyObject { return &NetworkAuthentication{} }},
+	{"httproutes", "httproutes.policy.linkerd.io", "HTTPRoute", "http_routes", "http_route",
+		func() linkerdPolicyObject { return &LinkerdHTTPRoute{} }},
+	{"gatewayhttproutes", "httproutes.gateway.networking.k8s.io", "Gateway API HTTPRoute", "gateway_http_routes", "gateway_http_route",
+		func() linkerdPolicyObject { return &GatewayHTTPRoute{} }},
+}
+
+func linkerdPolicyMeta(apiVersion, kind, namespace, name string, spec interface{}) k8sResource {
+	return k8sResource{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Metadata: k8sObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: spec,
+	}
+}
+
+func (s *LinkerdServer) setMeta(namespace, name string) { s.Namespace, s.Name = namespace, name }
+
+// accessPolicy only exists from policy.linkerd.io/v1beta3 on; older
+// clusters still get the v1beta1 schema
+func (s *LinkerdServer) manifest() k8sResource {
+	apiVersion := "policy.linkerd.io/v1beta1"
+	if s.AccessPolicy != "" {
+		apiVersion = "policy.linkerd.io/v1beta3"
+	}
+	return linkerdPolicyMeta(apiVersion, "Server", s.Namespace, s.Name, s.LinkerdServerSpec)
+}
+
+func (s *LinkerdServer) validate() []string {
+	var errors []string
+	if s.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	if s.PodSelector == nil {
+		errors = append(errors, "podSelector is required")
+	} else if _, err := metav1.LabelSelectorAsSelector(s.PodSelector); err != nil {
+		errors = append(errors, fmt.Sprintf("podSelector: %v", err))
+	}
+	switch s.Port.Type {
+	case intstr.Int:
+		if s.Port.IntVal < 1 || s.Port.IntVal > 65535 {
+			errors = append(errors, "port must be between 1 and 65535")
+		}
+	case intstr.String:
+		if s.Port.StrVal == "" {
+			errors = append(errors, "port is required")
+		}
+	}
+	if s.ProxyProtocol != "" && !validProxyProtocols[s.ProxyProtocol] {
+		errors = append(errors, fmt.Sprintf("unsupported proxyProtocol %q", s.ProxyProtocol))
+	}
+	if s.AccessPolicy != "" && !validAccessPolicies[s.AccessPolicy] {
+		errors = append(errors, fmt.Sprintf("unsupported accessPolicy %q", s.AccessPolicy))
+	}
+	return errors
+}
+
+func (p *LinkerdAuthorizationPolicy) setMeta(namespace, name string) {
+	p.Namespace, p.Name = namespace, name
+}
+
+func (p *LinkerdAuthorizationPolicy) manifest() k8sResource {
+	spec := p.LinkerdAuthorizationPolicySpec
+	if spec.RequiredAuthenticationRefs == nil {
+		spec.RequiredAuthenticationRefs = []PolicyTargetRef{}
+	}
+	return linkerdPolicyMeta("policy.linkerd.io/v1alpha1", "AuthorizationPolicy", p.Namespace, p.Name, spec)
+}
+
+func (p *LinkerdAuthorizationPolicy) validate() []string {
+	var errors []string
+	if p.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	switch p.TargetRef.Kind {
+	case "Server", "HTTPRoute", "Namespace":
+	default:
+		errors = append(errors, fmt.Sprintf("targetRef.kind must be Server, HTTPRoute or Namespace, got %q", p.TargetRef.Kind))
+	}
+	if p.TargetRef.Kind == "HTTPRoute" {
+		switch p.TargetRef.Group {
+		case "", linkerdPolicyGroup, gatewayAPIGroup:
+		default:
+			errors = append(errors, fmt.Sprintf("targetRef.group must be %s or %s for an HTTPRoute, got %q", linkerdPolicyGroup, gatewayAPIGroup, p.TargetRef.Group))
+		}
+	}
+	if p.TargetRef.Name == "" {
+		errors = append(errors, "targetRef.name is required")
+	}
+	for i, ref := range p.RequiredAuthenticationRefs {
+		switch ref.Kind {
+		case "MeshTLSAuthentication", "NetworkAuthentication", "ServiceAccount":
+		default:
+			errors = append(errors, fmt.Sprintf("requiredAuthenticationRefs[%d]: unsupported kind %q", i, ref.Kind))
+		}
+		if ref.Name == "" {
+			errors = append(errors, fmt.Sprintf("requiredAuthenticationRefs[%d]: name is required", i))
+		}
+	}
+	return errors
+}
+
+func (m *MeshTLSAuthentication) setMeta(namespace, name string) {
+	m.Namespace, m.Name = namespace, name
+}
+
+func (m *MeshTLSAuthentication) manifest() k8sResource {
+	return linkerdPolicyMeta("policy.linkerd.io/v1alpha1", "MeshTLSAuthentication", m.Namespace, m.Name, m.MeshTLSAuthenticationSpec)
+}
+
+func (m *MeshTLSAuthentication) validate() []string {
+	var errors []string
+	if m.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	if (len(m.Identities) == 0) == (len(m.IdentityRefs) == 0) {
+		errors = append(errors, "exactly one of identities or identityRefs is required")
+	}
+	for i, ref := range m.IdentityRefs {
+		if ref.Kind != "ServiceAccount" && ref.Kind != "Namespace" {
+			errors = append(errors, fmt.Sprintf("identityRefs[%d]: kind must be ServiceAccount or Namespace", i))
+		}
+		if ref.Name == "" {
+			errors = append(errors, fmt.Sprintf("identityRefs[%d]: name is required", i))
+		}
+	}
+	return errors
+}
+
+func (n *NetworkAuthentication) setMeta(namespace, name string) {
+	n.Namespace, n.Name = namespace, name
+}
+
+func (n *NetworkAuthentication) manifest() k8sResource {
+	return linkerdPolicyMeta("policy.linkerd.io/v1alpha1", "NetworkAuthentication", n.Namespace, n.Name, n.NetworkAuthenticationSpec)
+}
+
+func (n *NetworkAuthentication) validate() []string {
+	var errors []string
+	if n.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	if len(n.Networks) == 0 {
+		errors = append(errors, "at least one network is required")
+	}
+	for i, network := range n.Networks {
+		if !validIPBlock(network.CIDR) {
+			errors = append(errors, fmt.Sprintf("networks[%d]: invalid cidr %q", i, network.CIDR))
+		}
+		for _, except := range network.Except {
+			if !validIPBlock(except) {
+				errors = append(errors, fmt.Sprintf("networks[%d]: invalid except %q", i, except))
+			}
+		}
+	}
+	return errors
+}
+
+func (r *LinkerdHTTPRoute) setMeta(namespace, name string) { r.Namespace, r.Name = namespace, name }
+
+func (r *LinkerdHTTPRoute) manifest() k8sResource {
+	return linkerdPolicyMeta("policy.linkerd.io/v1beta2", "HTTPRoute", r.Namespace, r.Name, r.LinkerdHTTPRouteSpec)
+}
+
+func (r *GatewayHTTPRoute) manifest() k8sResource {
+	return linkerdPolicyMeta(gatewayAPIGroup+"/v1beta1", "HTTPRoute", r.Namespace, r.Name, r.LinkerdHTTPRouteSpec)
+}
+
+func (r *LinkerdHTTPRoute) validate() []string {
+	var errors []string
+	if r.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	if len(r.ParentRefs) == 0 {
+		errors = append(errors, "at least one parentRef is required")
+	}
+	for i, parent := range r.ParentRefs {
+		if parent.Kind != "Server" && parent.Kind != "Service" {
+			errors = append(errors, fmt.Sprintf("parentRefs[%d]: kind must be Server or Service", i))
+		}
+		if parent.Name == "" {
+			errors = append(errors, fmt.Sprintf("parentRefs[%d]: name is required", i))
+		}
+	}
+	for i, rule := range r.Rules {
+		for j, match := range rule.Matches {
+			if match.Path == nil {
+				continue
+			}
+			switch match.Path.Type {
+			case "", "Exact", "PathPrefix", "RegularExpression":
+			default:
+				errors = append(errors, fmt.Sprintf("rules[%d].matches[%d]: unsupported path type %q", i, j, match.Path.Type))
+			}
+		}
+		for j, backend := range rule.BackendRefs {
+			if backend.Name == "" {
+				errors = append(errors, fmt.Sprintf("rules[%d].backendRefs[%d]: name is required", i, j))
+			}
+			if backend.Weight != nil && *backend.Weight < 0 {
+				errors = append(errors, fmt.Sprintf("rules[%d].backendRefs[%d]: weight must not be negative", i, j))
+			}
+		}
+	}
+	return errors
+}
+
+func decodeLinkerdPolicy(kind linkerdPolicyKind, resource k8sRawResource) (linkerdPolicyObject, error) {
+	obj := kind.new()
+	if len(resource.Spec) > 0 {
+		if err := json.Unmarshal(resource.Spec, obj); err != nil {
+			return nil, fmt.Errorf("failed to parse spec: %v", err)
+		}
+	}
+	obj.setMeta(resource.Metadata.Namespace, resource.Metadata.Name)
+	return obj, nil
+}
+
+func listLinkerdPolicies(kind linkerdPolicyKind, namespace string) ([]linkerdPolicyObject, error) {
+	items, err := kubectlListResources(kind.resource, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []linkerdPolicyObject{}
+	for _, item := range items {
+		obj, err := decodeLinkerdPolicy(kind, item)
+		if err != nil {
+			log.Printf("Skipping %s %s/%s: %v", kind.label, item.Metadata.Namespace, item.Metadata.Name, err)
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+func findLinkerdPolicyKind(path string) (linkerdPolicyKind, bool) {
+	for _, kind := range linkerdPolicyKinds {
+		if kind.path == path {
+			return kind, true
+		}
+	}
+	return linkerdPolicyKind{}, false
+}
+
+func getLinkerdServers(namespace string) ([]LinkerdServer, error) {
+	kind, _ := findLinkerdPolicyKind("servers")
+	objects, err := listLinkerdPolicies(kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	servers := []LinkerdServer{}
+	for _, obj := range objects {
+		servers = append(servers, *obj.(*LinkerdServer))
+	}
+	return servers, nil
+}
+
+func getLinkerdAuthorizationPolicies(namespace string) ([]LinkerdAuthorizationPolicy, error) {
+	kind, _ := findLinkerdPolicyKind("authorizationpolicies")
+	objects, err := listLinkerdPolicies(kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	policies := []LinkerdAuthorizationPolicy{}
+	for _, obj := range objects {
+		policies = append(policies, *obj.(*LinkerdAuthorizationPolicy))
+	}
+	return policies, nil
+}
+
+func getMeshTLSAuthentications(namespace string) ([]MeshTLSAuthentication, error) {
+	kind, _ := findLinkerdPolicyKind("meshtlsauthentications")
+	objects, err := listLinkerdPolicies(kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	authns := []MeshTLSAuthentication{}
+	for _, obj := range objects {
+		authns = append(authns, *obj.(*MeshTLSAuthentication))
+	}
+	return authns, nil
+}
+
+func getNetworkAuthentications(namespace string) ([]NetworkAuthentication, error) {
+	kind, _ := findLinkerdPolicyKind("networkauthentications")
+	objects, err := listLinkerdPolicies(kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	authns := []NetworkAuthentication{}
+	for _, obj := range objects {
+		authns = append(authns, *obj.(*NetworkAuthentication))
+	}
+	return authns, nil
+}
+
+func getLinkerdHTTPRoutes(namespace string) ([]LinkerdHTTPRoute, error) {
+	kind, _ := findLinkerdPolicyKind("httproutes")
+	objects, err := listLinkerdPolicies(kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	routes := []LinkerdHTTPRoute{}
+	for _, obj := range objects {
+		routes = append(routes, *obj.(*LinkerdHTTPRoute))
+	}
+	return routes, nil
+}
+
+func getGatewayHTTPRoutes(namespace string) ([]GatewayHTTPRoute, error) {
+	kind, _ := findLinkerdPolicyKind("gatewayhttproutes")
+	objects, err := listLinkerdPolicies(kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	routes := []GatewayHTTPRoute{}
+	for _, obj := range objects {
+		routes = append(routes, *obj.(*GatewayHTTPRoute))
+	}
+	return routes, nil
+}
+
+// Authorization decisions for a Server
+
+type ServerAuthorizationRule struct {
+	Policy          string   `json:"policy"`
+	Scope           string   `json:"scope"`
+	Route           string   `json:"route,omitempty"`
+	Identities      []string `json:"identities"`
+	Networks        []string `json:"networks"`
+	Unauthenticated bool     `json:"unauthenticated"`
+	Issues          []string `json:"issues,omitempty"`
+}
+
+type ServerAuthzSource struct {
+	Namespace      string `json:"namespace,omitempty"`
+	ServiceAccount string `json:"service_account,omitempty"`
+	IP             string `json:"ip,omitempty"`
+	Identity       string `json:"identity,omitempty"`
+}
+
+type ServerAuthzDecision struct {
+	Source        ServerAuthzSource `json:"source"`
+	Allowed       bool              `json:"allowed"`
+	AllowedRoutes []string          `json:"allowed_routes"`
+	Policies      []string          `json:"policies"`
+	Reason        string            `json:"reason"`
+}
+
+type ServerAuthorizations struct {
+	Server        string                    `json:"server"`
+	Namespace     string                    `json:"namespace"`
+	Port          string                    `json:"port"`
+	Pods          []string                  `json:"pods"`
+	Routes        []string                  `json:"routes"`
+	DefaultPolicy string                    `json:"default_policy"`
+	Rules         []ServerAuthorizationRule `json:"rules"`
+	Decision      *ServerAuthzDecision      `json:"decision,omitempty"`
+}
+
+func serviceAccountIdentity(serviceAccount, namespace string) string {
+	return fmt.Sprintf("%s.%s.serviceaccount.identity.linkerd.%s", serviceAccount, namespace, linkerdTrustDomain)
+}
+
+// Identity patterns allow "*" and a leading "*." wildcard
+func identityMatches(pattern, identity string) bool {
+	if pattern == "*" {
+		return identity != ""
+	}
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(identity, pattern[1:])
+	}
+	return pattern == identity
+}
+
+func networkContains(network AuthenticatedNetwork, ip net.IP) bool {
+	contains := func(block string) bool {
+		if _, cidr, err := net.ParseCIDR(block); err == nil {
+			return cidr.Contains(ip)
+		}
+		return net.ParseIP(block).Equal(ip)
+	}
+	if !contains(network.CIDR) {
+		return false
+	}
+	for _, except := range network.Except {
+		if contains(except) {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve the authentication refs of a policy into one rule
+func resolveAuthorizationRule(policy LinkerdAuthorizationPolicy, meshTLS []MeshTLSAuthentication, networks []NetworkAuthentication) ServerAuthorizationRule {
+	rule := ServerAuthorizationRule{
+		Policy:     policy.Name,
+		Identities: []string{},
+		Networks:   []string{},
+	}
+	if len(policy.RequiredAuthenticationRefs) == 0 {
+		rule.Unauthenticated = true
+	}
+
+	for _, ref := range policy.RequiredAuthenticationRefs {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = policy.Namespace
+		}
+
+		switch ref.Kind {
+		case "ServiceAccount":
+			rule.Identities = append(rule.Identities, serviceAccountIdentity(ref.Name, namespace))
+
+		case "MeshTLSAuthentication":
+			found := false
+			for _, authn := range meshTLS {
+				if authn.Name != ref.Name || authn.Namespace != namespace {
+					continue
+				}
+				found = true
+				rule.Identities = append(rule.Identities, authn.Identities...)
+				for _, identityRef := range authn.IdentityRefs {
+					refNamespace := identityRef.Namespace
+					if refNamespace == "" {
+						refNamespace = authn.Namespace
+					}
+					if identityRef.Kind == "Namespace" {
+						rule.Identities = append(rule.Identities, "*."+identityRef.Name+".serviceaccount.identity.linkerd."+linkerdTrustDomain)
+					} else {
+						rule.Identities = append(rule.Identities, serviceAccountIdentity(identityRef.Name, refNamespace))
+					}
+				}
+			}
+			if !found {
+				rule.Issues = append(rule.Issues, fmt.Sprintf("MeshTLSAuthentication %s/%s does not exist", namespace, ref.Name))
+			}
+
+		case "NetworkAuthentication":
+			found := false
+			for _, authn := range networks {
+				if authn.Name != ref.Name || authn.Namespace != namespace {
+					continue
+				}
+				found = true
+				for _, network := range authn.Networks {
+					description := network.CIDR
+					if len(network.Except) > 0 {
+						description += " except " + strings.Join(network.Except, ", ")
+					}
+					rule.Networks = append(rule.Networks, description)
+				}
+			}
+			if !found {
+				rule.Issues = append(rule.Issues, fmt.Sprintf("NetworkAuthentication %s/%s does not exist", namespace, ref.Name))
+			}
+		}
+	}
+
+	return rule
+}
+
+// Every required authentication must be satisfied for a policy to admit a
+// client
+func policyAdmits(policy LinkerdAuthorizationPolicy, source ServerAuthzSource, meshTLS []MeshTLSAuthentication, networks []NetworkAuthentication) bool {
+	ip := net.ParseIP(source.IP)
+
+	for _, ref := range policy.RequiredAuthenticationRefs {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = policy.Namespace
+		}
+
+		single := LinkerdAuthorizationPolicy{Name: policy.Name, Namespace: policy.Namespace}
+		single.RequiredAuthenticationRefs = []PolicyTargetRef{ref}
+		rule := resolveAuthorizationRule(single, meshTLS, networks)
+		if len(rule.Issues) > 0 {
+			return false
+		}
+
+		satisfied := false
+		switch ref.Kind {
+		case "ServiceAccount", "MeshTLSAuthentication":
+			for _, pattern := range rule.Identities {
+				if identityMatches(pattern, source.Identity) {
+					satisfied = true
+				}
+			}
+		case "NetworkAuthentication":
+			if ip == nil {
+				break
+			}
+			for _, authn := range networks {
+				if authn.Name != ref.Name || authn.Namespace != namespace {
+					continue
+				}
+				for _, network := range authn.Networks {
+					if networkContains(network, ip) {
+						satisfied = true
+					}
+				}
+			}
+		}
+		if !satisfied {
+			return false
+		}
+	}
+	return true
+}
+
+// Decision of the Server's access policy for a client no authorization
+// policy admits. cluster-* policies also require the client to come from the
+// cluster networks; a client given only by service account is a pod and
+// counts as in the cluster.
+func defaultPolicyAdmits(accessPolicy string, source ServerAuthzSource, clusterNetworks []string) bool {
+	inCluster := true
+	if ip := net.ParseIP(source.IP); ip != nil {
+		inCluster = false
+		for _, block := range clusterNetworks {
+			if networkContains(AuthenticatedNetwork{CIDR: block}, ip) {
+				inCluster = true
+			}
+		}
+	}
+
+	switch accessPolicy {
+	case "all-unauthenticated", "audit":
+		return true
+	case "all-authenticated":
+		return source.Identity != ""
+	case "cluster-unauthenticated":
+		return inCluster
+	case "cluster-authenticated":
+		return source.Identity != "" && inCluster
+	}
+	return false
+}
+
+// clusterNetworks from the linkerd-config values, or Linkerd's default
+func getLinkerdClusterNetworks() []string {
+	networks := defaultLinkerdClusterNetworks
+	if clientset, err := getKubeClientset(); err == nil {
+		configMap, err := clientset.CoreV1().ConfigMaps(linkerdNamespace).Get(context.Background(), "linkerd-config", metav1.GetOptions{})
+		if err == nil {
+			var values struct {
+				ClusterNetworks string `json:"clusterNetworks"`
+			}
+			if yaml.Unmarshal([]byte(configMap.Data["values"]), &values) == nil && values.ClusterNetworks != "" {
+				networks = values.ClusterNetworks
+			}
+		}
+	}
+
+	blocks := []string{}
+	for _, block := range strings.Split(networks, ",") {
+		if block = strings.TrimSpace(block); block != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+func routeParentIsServer(route LinkerdHTTPRoute, server LinkerdServer) bool {
+	for _, parent := range route.ParentRefs {
+		namespace := parent.Namespace
+		if namespace == "" {
+			namespace = route.Namespace
+		}
+		if parent.Kind == "Server" && parent.Name == server.Name && namespace == server.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func getServerAuthorizations(namespace, name string, source *ServerAuthzSource) (*ServerAuthorizations, error) {
+	resource, err := kubectlGetResource("servers.policy.linkerd.io", namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	kind, _ := findLinkerdPolicyKind("servers")
+	obj, err := decodeLinkerdPolicy(kind, *resource)
+	if err != nil {
+		return nil, err
+	}
+	server := *obj.(*LinkerdServer)
+
+	policies, err := getLinkerdAuthorizationPolicies(namespace)
+	if err != nil {
+		return nil, err
+	}
+	// Routes of both groups, keyed by group so policies match on
+	// targetRef.group
+	routeGroups := map[string][]LinkerdHTTPRoute{}
+	routeGroups[linkerdPolicyGroup], err = getLinkerdHTTPRoutes(namespace)
+	if err != nil {
+		log.Printf("Warning: Could not list Linkerd HTTPRoutes: %v", err)
+	}
+	gatewayRoutes, err := getGatewayHTTPRoutes(namespace)
+	if err != nil {
+		log.Printf("Warning: Could not list Gateway API HTTPRoutes: %v", err)
+	}
+	for _, route := range gatewayRoutes {
+		routeGroups[gatewayAPIGroup] = append(routeGroups[gatewayAPIGroup], route.LinkerdHTTPRoute)
+	}
+	meshTLS, err := getMeshTLSAuthentications("")
+	if err != nil {
+		return nil, err
+	}
+	networks, err := getNetworkAuthentications("")
+	if err != nil {
+		log.Printf("Warning: Could not list NetworkAuthentications: %v", err)
+		networks = []NetworkAuthentication{}
+	}
+
+	result := &ServerAuthorizations{
+		Server:        server.Name,
+		Namespace:     server.Namespace,
+		Port:          server.Port.String(),
+		Pods:          []string{},
+		Routes:        []string{},
+		DefaultPolicy: server.AccessPolicy,
+		Rules:         []ServerAuthorizationRule{},
+	}
+	if result.DefaultPolicy == "" {
+		result.DefaultPolicy = "deny"
+	}
+
+	if server.PodSelector != nil {
+		if selector, err := metav1.LabelSelectorAsSelector(server.PodSelector); err == nil {
+			if clientset, err := getKubeClientset(); err == nil {
+				pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector.String()})
+				if err == nil {
+					for _, pod := range pods.Items {
+						result.Pods = append(result.Pods, pod.Name)
+					}
+				}
+			}
+		}
+	}
+
+	// Attached routes by group/name, with the name they are reported under;
+	// Gateway API routes carry their group to tell them apart
+	attached := make(map[string]string)
+	for group, routes := range routeGroups {
+		for _, route := range routes {
+			if !routeParentIsServer(route, server) {
+				continue
+			}
+			label := route.Name
+			if group == gatewayAPIGroup {
+				label = route.Name + " (" + gatewayAPIGroup + ")"
+			}
+			attached[group+"/"+route.Name] = label
+			result.Routes = append(result.Routes, label)
+		}
+	}
+	sort.Strings(result.Routes)
+
+	// Policies that apply, with the scope they apply at
+	type scopedPolicy struct {
+		policy LinkerdAuthorizationPolicy
+		scope  string
+		route  string
+	}
+	var applicable []scopedPolicy
+	for _, policy := range policies {
+		target := policy.TargetRef
+		switch {
+		case target.Kind == "Server" && target.Name == server.Name:
+			applicable = append(applicable, scopedPolicy{policy, "server", ""})
+		case target.Kind == "Namespace" && target.Name == server.Namespace:
+			applicable = append(applicable, scopedPolicy{policy, "namespace", ""})
+		case target.Kind == "HTTPRoute":
+			group := target.Group
+			if group == "" {
+				group = linkerdPolicyGroup
+			}
+			if label, ok := attached[group+"/"+target.Name]; ok {
+				applicable = append(applicable, scopedPolicy{policy, "route", label})
+			}
+		}
+	}
+
+	for _, sp := range applicable {
+		rule := resolveAuthorizationRule(sp.policy, meshTLS, networks)
+		rule.Scope, rule.Route = sp.scope, sp.route
+		result.Rules = append(result.Rules, rule)
+	}
+
+	if source == nil {
+		return result, nil
+	}
+
+	if source.ServiceAccount != "" && source.Identity == "" {
+		source.Identity = serviceAccountIdentity(source.ServiceAccount, source.Namespace)
+	}
+	decision := &ServerAuthzDecision{
+		Source:        *source,
+		AllowedRoutes: []string{},
+		Policies:      []string{},
+	}
+	routeAllowed := make(map[string]bool)
+	for _, sp := range applicable {
+		if !policyAdmits(sp.policy, *source, meshTLS, networks) {
+			continue
+		}
+		decision.Policies = append(decision.Policies, sp.policy.Name)
+		if sp.scope == "route" {
+			routeAllowed[sp.route] = true
+		} else {
+			decision.Allowed = true
+		}
+	}
+
+	// The access policy applies to any traffic no authorization admits
+	switch {
+	case decision.Allowed:
+		decision.AllowedRoutes = result.Routes
+		decision.Reason = fmt.Sprintf("admitted by %s", strings.Join(decision.Policies, ", "))
+	case defaultPolicyAdmits(result.DefaultPolicy, *source, getLinkerdClusterNetworks()):
+		decision.Allowed = true
+		decision.AllowedRoutes = result.Routes
+		if len(applicable) == 0 {
+			decision.Reason = fmt.Sprintf("no policy targets the server; access policy %s admits the client", result.DefaultPolicy)
+		} else {
+			decision.Reason = fmt.Sprintf("no authorization policy admits the client; access policy %s admits it", result.DefaultPolicy)
+		}
+	case len(routeAllowed) > 0:
+		for route := range routeAllowed {
+			decision.AllowedRoutes = append(decision.AllowedRoutes, route)
+		}
+		sort.Strings(decision.AllowedRoutes)
+		decision.Reason = fmt.Sprintf("admitted only on routes %s", strings.Join(decision.AllowedRoutes, ", "))
+	case len(applicable) == 0:
+		decision.Reason = fmt.Sprintf("no policy targets the server and access policy %s denies the client", result.DefaultPolicy)
+	default:
+		decision.Reason = fmt.Sprintf("no authorization policy admits the client and access policy %s denies it", result.DefaultPolicy)
+	}
+
+	result.Decision = decision
+	return result, nil
+}
+
+func registerLinkerdPolicyRoutes(e *echo.Echo) {
+	// Authorization rules of a Server; with source_namespace and
+	// source_service_account (meshed client) or source_ip the decision for
+	// that client is included
+	e.GET("/api/linkerd/policy/servers/:namespace/:name/authorizations", func(c echo.Context) error {
+		var source *ServerAuthzSource
+		if c.QueryParam("source_service_account") != "" || c.QueryParam("source_ip") != "" {
+			source = &ServerAuthzSource{
+				Namespace:      c.QueryParam("source_namespace"),
+				ServiceAccount: c.QueryParam("source_service_account"),
+				IP:             c.QueryParam("source_ip"),
+			}
+			if source.ServiceAccount != "" && source.Namespace == "" {
+				return c.JSON(http.StatusBadRequest, map[string]string{
+					"error": "source_namespace is required with source_service_account",
+				})
+			}
+			if source.IP != "" && net.ParseIP(source.IP) == nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{
+					"error": fmt.Sprintf("invalid source_ip %q", source.IP),
+				})
+			}
+		}
+
+		authorizations, err := getServerAuthorizations(c.Param("namespace"), c.Param("name"), source)
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "Server not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to evaluate Server authorizations: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, authorizations)
+	})
+
+	for _, kind := range linkerdPolicyKinds {
+		kind := kind
+		base := "/api/linkerd/policy/" + kind.path
+
+		// List resources of the kind
+		e.GET(base, func(c echo.Context) error {
+			objects, err := listLinkerdPolicies(kind, c.QueryParam("namespace"))
+			if err != nil {
+				log.Printf("Error listing %s resources: %v", kind.label, err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": fmt.Sprintf("Failed to list %s resources: %v", kind.label, err),
+				})
+			}
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				kind.plural: objects,
+				"count":     len(objects),
+			})
+		})
+
+		// Get one resource
+		e.GET(base+"/:namespace/:name", func(c echo.Context) error {
+			resource, err := kubectlGetResource(kind.resource, c.Param("namespace"), c.Param("name"))
+			if err != nil {
+				if isNotFoundError(err) {
+					return c.JSON(http.StatusNotFound, map[string]string{
+						"error": fmt.Sprintf("%s not found", kind.label),
+					})
+				}
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": fmt.Sprintf("Failed to get %s: %v", kind.label, err),
+				})
+			}
+			obj, err := decodeLinkerdPolicy(kind, *resource)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": fmt.Sprintf("Failed to parse %s: %v", kind.label, err),
+				})
+			}
+			return c.JSON(http.StatusOK, obj)
+		})
+
+		// Create a resource
+		e.POST(base, func(c echo.Context) error {
+			obj := kind.new()
+			if err := c.Bind(obj); err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{
+					"error": fmt.Sprintf("Invalid %s payload", kind.label),
+				})
+			}
+			manifest := obj.manifest()
+			if manifest.Metadata.Namespace == "" {
+				obj.setMeta("default", manifest.Metadata.Name)
+				manifest = obj.manifest()
+			}
+
+			errors := obj.validate()
+			if len(errors) == 0 {
+				if err := kubectlDryRun(manifest, true); err != nil {
+					errors = append(errors, err.Error())
+				}
+			}
+			if len(errors) > 0 {
+				return c.JSON(http.StatusBadRequest, map[string]interface{}{
+					"valid":  false,
+					"errors": errors,
+				})
+			}
+
+			output, err := kubectlCreate(manifest, false)
+			if err != nil {
+				log.Printf("Error creating %s: %v", kind.label, err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": fmt.Sprintf("Failed to create %s: %v", kind.label, err),
+				})
+			}
+
+			return c.JSON(http.StatusCreated, map[string]interface{}{
+				"success":     true,
+				"message":     fmt.Sprintf("%s created successfully", kind.label),
+				"output":      output,
+				kind.singular: obj,
+			})
+		})
+
+		// Update a resource
+		e.PUT(base+"/:namespace/:name", func(c echo.Context) error {
+			obj := kind.new()
+			if err := c.Bind(obj); err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{
+					"error": fmt.Sprintf("Invalid %s payload", kind.label),
+				})
+			}
+			obj.setMeta(c.Param("namespace"), c.Param("name"))
+
+			if _, err := kubectlGetResource(kind.resource, c.Param("namespace"), c.Param("name")); err != nil {
+				if isNotFoundError(err) {
+					return c.JSON(http.StatusNotFound, map[string]string{
+						"error": fmt.Sprintf("%s not found", kind.label),
+					})
+				}
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": fmt.Sprintf("Failed to get %s: %v", kind.label, err),
+				})
+			}
+
+			errors := obj.validate()
+			if len(errors) == 0 {
+				if err := kubectlDryRun(obj.manifest(), false); err != nil {
+					errors = append(errors, err.Error())
+				}
+			}
+			if len(errors) > 0 {
+				return c.JSON(http.StatusBadRequest, map[string]interface{}{
+					"valid":  false,
+					"errors": errors,
+				})
+			}
+
+			output, err := kubectlApply(obj.manifest(), false)
+			if err != nil {
+				log.Printf("Error updating %s: %v", kind.label, err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": fmt.Sprintf("Failed to update %s: %v", kind.label, err),
+				})
+			}
+
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"success":     true,
+				"message":     fmt.Sprintf("%s updated successfully", kind.label),
+				"output":      output,
+				kind.singular: obj,
+			})
+		})
+
+		// Delete a resource
+		e.DELETE(base+"/:namespace/:name", func(c echo.Context) error {
+			output, err := kubectlDeleteResource(kind.resource, c.Param("namespace"), c.Param("name"))
+			if err != nil {
+				if isNotFoundError(err) {
+					return c.JSON(http.StatusNotFound, map[string]string{
+						"error": fmt.Sprintf("%s not found", kind.label),
+					})
+				}
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": fmt.Sprintf("Failed to delete %s: %v", kind.label, err),
+				})
+			}
+
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"success": true,
+				"message": output,
+			})
+		})
+	}
+}
@@ -0,0 +1,714 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	"sigs.k8s.io/yaml"
+)
+
+// Linkerd ServiceProfiles. Profiles are generated the way linkerd profile
+// does it: one route per OpenAPI operation or per gRPC method, and routes
+// are then tuned with timeouts, retries and response classes.
+
+const serviceProfileKind = "serviceprofiles.linkerd.io"
+
+// ServiceProfile route types, following the linkerd.io/v1alpha2 CRD schema
+
+type ServiceProfileRoute struct {
+	Name            string          `json:"name"`
+	Condition       RequestMatch    `json:"condition"`
+	ResponseClasses []ResponseClass `json:"responseClasses,omitempty"`
+	IsRetryable     bool            `json:"isRetryable,omitempty"`
+	Timeout         string          `json:"timeout,omitempty"`
+	Metrics         *RouteMetrics   `json:"metrics,omitempty"`
+}
+
+type RequestMatch struct {
+	PathRegex string         `json:"pathRegex,omitempty"`
+	Method    string         `json:"method,omitempty"`
+	All       []RequestMatch `json:"all,omitempty"`
+	Any       []RequestMatch `json:"any,omitempty"`
+	Not       *RequestMatch  `json:"not,omitempty"`
+}
+
+type ResponseClass struct {
+	Condition ResponseMatch `json:"condition"`
+	IsFailure bool          `json:"isFailure"`
+}
+
+type ResponseMatch struct {
+	Status *StatusRange    `json:"status,omitempty"`
+	All    []ResponseMatch `json:"all,omitempty"`
+	Any    []ResponseMatch `json:"any,omitempty"`
+	Not    *ResponseMatch  `json:"not,omitempty"`
+}
+
+type StatusRange struct {
+	Min uint32 `json:"min,omitempty"`
+	Max uint32 `json:"max,omitempty"`
+}
+
+type RetryBudget struct {
+	RetryRatio          float64 `json:"retryRatio"`
+	MinRetriesPerSecond uint32  `json:"minRetriesPerSecond"`
+	TTL                 string  `json:"ttl"`
+}
+
+// Live metrics of one route from linkerd viz routes
+type RouteMetrics struct {
+	SuccessRate *float64 `json:"success_rate"`
+	RPS         float64  `json:"rps"`
+	LatencyP50  *float64 `json:"latency_ms_p50"`
+	LatencyP95  *float64 `json:"latency_ms_p95"`
+	LatencyP99  *float64 `json:"latency_ms_p99"`
+}
+
+// Partial update of a route; nil fields are left unchanged
+type RouteEdit struct {
+	Name            string           `json:"name"`
+	Timeout         *string          `json:"timeout"`
+	IsRetryable     *bool            `json:"isRetryable"`
+	ResponseClasses *[]ResponseClass `json:"responseClasses"`
+}
+
+type ServiceProfileEdit struct {
+	Routes      []RouteEdit  `json:"routes"`
+	RetryBudget *RetryBudget `json:"retry_budget"`
+}
+
+type serviceProfileSpec struct {
+	Routes      []ServiceProfileRoute `json:"routes,omitempty"`
+	RetryBudget *RetryBudget          `json:"retryBudget,omitempty"`
+}
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+var pathParameter = regexp.MustCompile(`\{[^}]*\}`)
+
+// Profiles are named after the FQDN of the service they describe
+func serviceProfileName(service, namespace string) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local", service, namespace)
+}
+
+func getServiceProfiles() ([]ServiceProfile, error) {
+	return getServiceProfilesInNamespace("")
+}
+
+func getServiceProfilesInNamespace(namespace string) ([]ServiceProfile, error) {
+	items, err := kubectlListResources(serviceProfileKind, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := []ServiceProfile{}
+	for _, item := range items {
+		profile, err := serviceProfileFromResource(item)
+		if err != nil {
+			log.Printf("Skipping ServiceProfile %s/%s: %v", item.Metadata.Namespace, item.Metadata.Name, err)
+			continue
+		}
+		profiles = append(profiles, *profile)
+	}
+
+	return profiles, nil
+}
+
+func getServiceProfile(namespace, name string) (*ServiceProfile, error) {
+	resource, err := kubectlGetResource(serviceProfileKind, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return serviceProfileFromResource(*resource)
+}
+
+func serviceProfileFromResource(resource k8sRawResource) (*ServiceProfile, error) {
+	var spec serviceProfileSpec
+	if err := json.Unmarshal(resource.Spec, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %v", err)
+	}
+
+	profile := &ServiceProfile{
+		Name:        resource.Metadata.Name,
+		Namespace:   resource.Metadata.Namespace,
+		Routes:      spec.Routes,
+		RetryBudget: spec.RetryBudget,
+	}
+	if service, _, ok := serviceProfileTarget(profile.Name); ok {
+		profile.Service = service
+	}
+	if profile.Routes == nil {
+		profile.Routes = []ServiceProfileRoute{}
+	}
+	return profile, nil
+}
+
+// Routes as stored in the resource; metrics are not part of it
+func serviceProfileRoutes(profile ServiceProfile) []ServiceProfileRoute {
+	routes := make([]ServiceProfileRoute, len(profile.Routes))
+	for i, route := range profile.Routes {
+		route.Metrics = nil
+		routes[i] = route
+	}
+	return routes
+}
+
+func serviceProfileManifest(profile ServiceProfile) k8sResource {
+	routes := serviceProfileRoutes(profile)
+
+	return k8sResource{
+		APIVersion: "linkerd.io/v1alpha2",
+		Kind:       "ServiceProfile",
+		Metadata: k8sObjectMeta{
+			Name:      profile.Name,
+			Namespace: profile.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: serviceProfileSpec{
+			Routes:      routes,
+			RetryBudget: profile.RetryBudget,
+		},
+	}
+}
+
+func validateRequestMatch(field string, match RequestMatch) []string {
+	var errors []string
+	if match.PathRegex == "" && match.Method == "" && len(match.All) == 0 && len(match.Any) == 0 && match.Not == nil {
+		errors = append(errors, fmt.Sprintf("%s: condition must not be empty", field))
+	}
+	if match.PathRegex != "" {
+		if _, err := regexp.Compile(match.PathRegex); err != nil {
+			errors = append(errors, fmt.Sprintf("%s.pathRegex: %v", field, err))
+		}
+	}
+	for i, m := range match.All {
+		errors = append(errors, validateRequestMatch(fmt.Sprintf("%s.all[%d]", field, i), m)...)
+	}
+	for i, m := range match.Any {
+		errors = append(errors, validateRequestMatch(fmt.Sprintf("%s.any[%d]", field, i), m)...)
+	}
+	if match.Not != nil {
+		errors = append(errors, validateRequestMatch(field+".not", *match.Not)...)
+	}
+	return errors
+}
+
+func validateResponseMatch(field string, match ResponseMatch) []string {
+	var errors []string
+	if match.Status == nil && len(match.All) == 0 && len(match.Any) == 0 && match.Not == nil {
+		errors = append(errors, fmt.Sprintf("%s: condition must not be empty", field))
+	}
+	if status := match.Status; status != nil {
+		if status.Min == 0 && status.Max == 0 {
+			errors = append(errors, fmt.Sprintf("%s.status: min or max is required", field))
+		}
+		if (status.Min != 0 && (status.Min < 100 || status.Min > 599)) || (status.Max != 0 && (status.Max < 100 || status.Max > 599)) {
+			errors = append(errors, fmt.Sprintf("%s.status: codes must be between 100 and 599", field))
+		}
+		if status.Min != 0 && status.Max != 0 && status.Min > status.Max {
+			errors = append(errors, fmt.Sprintf("%s.status: min must not exceed max", field))
+		}
+	}
+	for i, m := range match.All {
+		errors = append(errors, validateResponseMatch(fmt.Sprintf("%s.all[%d]", field, i), m)...)
+	}
+	for i, m := range match.Any {
+		errors = append(errors, validateResponseMatch(fmt.Sprintf("%s.any[%d]", field, i), m)...)
+	}
+	if match.Not != nil {
+		errors = append(errors, validateResponseMatch(field+".not", *match.Not)...)
+	}
+	return errors
+}
+
+func validateServiceProfile(profile ServiceProfile) []string {
+	var errors []string
+
+	// External authorities are allowed; in-cluster names must be a service FQDN
+	if profile.Name == "" {
+		errors = append(errors, "name is required")
+	} else if _, _, ok := serviceProfileTarget(profile.Name); !ok && strings.HasSuffix(profile.Name, ".cluster.local") {
+		errors = append(errors, fmt.Sprintf("name %q must be the service FQDN (service.namespace.svc.cluster.local)", profile.Name))
+	}
+
+	names := make(map[string]bool)
+	for i, route := range profile.Routes {
+		field := fmt.Sprintf("routes[%d]", i)
+		if route.Name == "" {
+			errors = append(errors, field+": name is required")
+		} else if names[route.Name] {
+			errors = append(errors, fmt.Sprintf("%s: name %q is used by another route", field, route.Name))
+		}
+		names[route.Name] = true
+
+		errors = append(errors, validateRequestMatch(field+".condition", route.Condition)...)
+		for j, class := range route.ResponseClasses {
+			errors = append(errors, validateResponseMatch(fmt.Sprintf("%s.responseClasses[%d].condition", field, j), class.Condition)...)
+		}
+		if route.Timeout != "" {
+			if d, err := time.ParseDuration(route.Timeout); err != nil || d <= 0 {
+				errors = append(errors, fmt.Sprintf("%s: invalid timeout %q", field, route.Timeout))
+			}
+		}
+	}
+
+	if budget := profile.RetryBudget; budget != nil {
+		if budget.RetryRatio < 0 {
+			errors = append(errors, "retryBudget.retryRatio must not be negative")
+		}
+		if d, err := time.ParseDuration(budget.TTL); err != nil || d <= 0 {
+			errors = append(errors, fmt.Sprintf("retryBudget: invalid ttl %q", budget.TTL))
+		}
+	}
+
+	return errors
+}
+
+func dryRunServiceProfile(profile ServiceProfile, create bool) []string {
+	if errors := validateServiceProfile(profile); len(errors) > 0 {
+		return errors
+	}
+	if err := kubectlDryRun(serviceProfileManifest(profile), create); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// Turn an OpenAPI path template into a route regex, matching each
+// {parameter} with one path segment
+func pathTemplateRegex(path string) string {
+	var regex strings.Builder
+	last := 0
+	for _, loc := range pathParameter.FindAllStringIndex(path, -1) {
+		regex.WriteString(regexp.QuoteMeta(path[last:loc[0]]))
+		regex.WriteString("[^/]*")
+		last = loc[1]
+	}
+	regex.WriteString(regexp.QuoteMeta(path[last:]))
+	return regex.String()
+}
+
+// Routes for every operation of a Swagger 2.0 or OpenAPI 3 document, in JSON
+// or YAML. The x-linkerd-retryable and x-linkerd-timeout operation
+// extensions are honoured like linkerd profile --open-api does.
+func routesFromOpenAPI(data []byte) ([]ServiceProfileRoute, error) {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %v", err)
+	}
+
+	var doc struct {
+		Swagger  string `json:"swagger"`
+		OpenAPI  string `json:"openapi"`
+		BasePath string `json:"basePath"`
+		Servers  []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %v", err)
+	}
+	if doc.Swagger == "" && doc.OpenAPI == "" {
+		return nil, fmt.Errorf("document has no swagger or openapi version field")
+	}
+
+	base := doc.BasePath
+	if len(doc.Servers) > 0 {
+		if serverURL, err := url.Parse(doc.Servers[0].URL); err == nil {
+			base = serverURL.Path
+		}
+	}
+	base = strings.TrimRight(base, "/")
+
+	routes := []ServiceProfileRoute{}
+	for path, item := range doc.Paths {
+		for _, method := range openAPIMethods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var operation struct {
+				Retryable bool   `json:"x-linkerd-retryable"`
+				Timeout   string `json:"x-linkerd-timeout"`
+			}
+			json.Unmarshal(raw, &operation)
+
+			fullPath := base + path
+			routes = append(routes, ServiceProfileRoute{
+				Name: fmt.Sprintf("%s %s", strings.ToUpper(method), fullPath),
+				Condition: RequestMatch{
+					Method:    strings.ToUpper(method),
+					PathRegex: pathTemplateRegex(fullPath),
+				},
+				IsRetryable: operation.Retryable,
+				Timeout:     operation.Timeout,
+			})
+		}
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("document defines no operations")
+	}
+
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+	return routes, nil
+}
+
+var (
+	protoComment = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+	protoPackage = regexp.MustCompile(`\bpackage\s+([\w.]+)\s*;`)
+	protoService = regexp.MustCompile(`\bservice\s+(\w+)\s*\{`)
+	protoRPC     = regexp.MustCompile(`\brpc\s+(\w+)\s*\(`)
+)
+
+// Routes for every rpc of the services in a .proto file. gRPC calls are
+// POSTs to /package.Service/Method.
+func routesFromProtobuf(data []byte) ([]ServiceProfileRoute, error) {
+	source := protoComment.ReplaceAllString(string(data), "")
+
+	pkg := ""
+	if match := protoPackage.FindStringSubmatch(source); match != nil {
+		pkg = match[1] + "."
+	}
+
+	routes := []ServiceProfileRoute{}
+	for _, loc := range protoService.FindAllStringSubmatchIndex(source, -1) {
+		service := source[loc[2]:loc[3]]
+
+		// Service body runs to the matching closing brace
+		depth, end := 1, loc[1]
+		for end < len(source) && depth > 0 {
+			switch source[end] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			end++
+		}
+
+		for _, rpc := range protoRPC.FindAllStringSubmatch(source[loc[1]:end], -1) {
+			path := fmt.Sprintf("/%s%s/%s", pkg, service, rpc[1])
+			routes = append(routes, ServiceProfileRoute{
+				Name:      "POST " + path,
+				Condition: RequestMatch{Method: "POST", PathRegex: regexp.QuoteMeta(path)},
+			})
+		}
+	}
+	if len(routes) == 0 {
+		return nil, fmt.Errorf("no rpc definitions found")
+	}
+
+	return routes, nil
+}
+
+// Per-route metrics for the service a profile describes
+func getRouteMetrics(service, namespace string) (map[string]RouteMetrics, error) {
+	output, err := runLinkerdViz("routes", "svc/"+service, "-n", namespace, "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var tables map[string][]struct {
+		Route        string   `json:"route"`
+		Success      *float64 `json:"success"`
+		RPS          *float64 `json:"rps"`
+		LatencyMSp50 *float64 `json:"latency_ms_p50"`
+		LatencyMSp95 *float64 `json:"latency_ms_p95"`
+		LatencyMSp99 *float64 `json:"latency_ms_p99"`
+	}
+	if err := json.Unmarshal(output, &tables); err != nil {
+		return nil, fmt.Errorf("failed to parse linkerd routes output: %v", err)
+	}
+
+	metrics := make(map[string]RouteMetrics)
+	for _, rows := range tables {
+		for _, row := range rows {
+			m := RouteMetrics{LatencyP50: row.LatencyMSp50, LatencyP95: row.LatencyMSp95, LatencyP99: row.LatencyMSp99}
+			if row.Success != nil {
+				rate := *row.Success * 100
+				m.SuccessRate = &rate
+			}
+			if row.RPS != nil {
+				m.RPS = *row.RPS
+			}
+			metrics[row.Route] = m
+		}
+	}
+	return metrics, nil
+}
+
+func attachRouteMetrics(profile *ServiceProfile) {
+	if profile.Service == "" {
+		return
+	}
+	metrics, err := getRouteMetrics(profile.Service, profile.Namespace)
+	if err != nil {
+		log.Printf("Warning: Could not get route metrics for %s: %v", profile.Name, err)
+		return
+	}
+	for i := range profile.Routes {
+		if m, ok := metrics[profile.Routes[i].Name]; ok {
+			profile.Routes[i].Metrics = &m
+		}
+	}
+}
+
+// Merge route edits into a profile
+func applyServiceProfileEdit(profile *ServiceProfile, edit ServiceProfileEdit) []string {
+	var errors []string
+
+	byName := make(map[string]int)
+	for i, route := range profile.Routes {
+		byName[route.Name] = i
+	}
+
+	for _, change := range edit.Routes {
+		i, ok := byName[change.Name]
+		if !ok {
+			errors = append(errors, fmt.Sprintf("route %q does not exist", change.Name))
+			continue
+		}
+		if change.Timeout != nil {
+			profile.Routes[i].Timeout = *change.Timeout
+		}
+		if change.IsRetryable != nil {
+			profile.Routes[i].IsRetryable = *change.IsRetryable
+		}
+		if change.ResponseClasses != nil {
+			profile.Routes[i].ResponseClasses = *change.ResponseClasses
+		}
+	}
+
+	if edit.RetryBudget != nil {
+		profile.RetryBudget = edit.RetryBudget
+	}
+
+	return errors
+}
+
+func registerServiceProfileRoutes(e *echo.Echo) {
+	// List the ServiceProfiles of a namespace with per-route metrics
+	e.GET("/api/linkerd/service-profiles/:namespace", func(c echo.Context) error {
+		profiles, err := getServiceProfilesInNamespace(c.Param("namespace"))
+		if err != nil {
+			log.Printf("Error getting ServiceProfiles: %v", err)
+			return c.JSON(http.StatusOK, []ServiceProfile{})
+		}
+
+		for i := range profiles {
+			attachRouteMetrics(&profiles[i])
+		}
+		return c.JSON(http.StatusOK, profiles)
+	})
+
+	// Get a ServiceProfile with per-route metrics
+	e.GET("/api/linkerd/service-profiles/:namespace/:name", func(c echo.Context) error {
+		profile, err := getServiceProfile(c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "ServiceProfile not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get ServiceProfile: %v", err),
+			})
+		}
+
+		attachRouteMetrics(profile)
+		return c.JSON(http.StatusOK, profile)
+	})
+
+	// Generate a ServiceProfile from an uploaded OpenAPI document or .proto
+	// file. Form fields: file, service, and optionally format (openapi or
+	// protobuf, detected from the file name otherwise). ?dry_run=true only
+	// returns the generated profile.
+	e.POST("/api/linkerd/service-profiles/:namespace", func(c echo.Context) error {
+		namespace := c.Param("namespace")
+		service := c.FormValue("service")
+		if service == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "service is required",
+			})
+		}
+
+		header, err := c.FormFile("file")
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "An OpenAPI or protobuf file upload is required",
+			})
+		}
+		file, err := header.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("Failed to read upload: %v", err),
+			})
+		}
+		defer file.Close()
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("Failed to read upload: %v", err),
+			})
+		}
+
+		format := c.FormValue("format")
+		if format == "" {
+			format = "openapi"
+			if filepath.Ext(header.Filename) == ".proto" {
+				format = "protobuf"
+			}
+		}
+
+		var routes []ServiceProfileRoute
+		switch format {
+		case "openapi":
+			routes, err = routesFromOpenAPI(data)
+		case "protobuf":
+			routes, err = routesFromProtobuf(data)
+		default:
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("unsupported format %q (use openapi or protobuf)", format),
+			})
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		profile := ServiceProfile{
+			Name:      serviceProfileName(service, namespace),
+			Namespace: namespace,
+			Service:   service,
+			Routes:    routes,
+		}
+
+		if errors := dryRunServiceProfile(profile, true); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":           false,
+				"errors":          errors,
+				"service_profile": profile,
+			})
+		}
+
+		if c.QueryParam("dry_run") == "true" {
+			return c.JSON(http.StatusOK, map[string]interface{}{
+				"valid":           true,
+				"message":         fmt.Sprintf("Generated %d routes", len(routes)),
+				"service_profile": profile,
+			})
+		}
+
+		output, err := kubectlCreate(serviceProfileManifest(profile), false)
+		if err != nil {
+			log.Printf("Error creating ServiceProfile: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to create ServiceProfile: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusCreated, map[string]interface{}{
+			"success":         true,
+			"message":         fmt.Sprintf("ServiceProfile created with %d routes", len(routes)),
+			"output":          output,
+			"service_profile": profile,
+		})
+	})
+
+	// Edit route timeouts, retryability and response classes, and the retry
+	// budget
+	e.PATCH("/api/linkerd/service-profiles/:namespace/:name", func(c echo.Context) error {
+		var edit ServiceProfileEdit
+		if err := c.Bind(&edit); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid ServiceProfile edit payload",
+			})
+		}
+
+		profile, err := getServiceProfile(c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "ServiceProfile not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get ServiceProfile: %v", err),
+			})
+		}
+
+		if errors := applyServiceProfileEdit(profile, edit); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+		if errors := validateServiceProfile(*profile); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		// Merge-patch only the fields an edit can touch so the rest of the
+		// spec (dstOverrides, opaquePorts, ...) is left as it is
+		spec := map[string]interface{}{"routes": serviceProfileRoutes(*profile)}
+		if profile.RetryBudget != nil {
+			spec["retryBudget"] = profile.RetryBudget
+		}
+		patch := map[string]interface{}{"spec": spec}
+		if _, err := kubectlPatch(serviceProfileKind, profile.Namespace, profile.Name, "merge", patch, true); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": []string{err.Error()},
+			})
+		}
+
+		output, err := kubectlPatch(serviceProfileKind, profile.Namespace, profile.Name, "merge", patch, false)
+		if err != nil {
+			log.Printf("Error updating ServiceProfile: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to update ServiceProfile: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success":         true,
+			"message":         "ServiceProfile updated successfully",
+			"output":          output,
+			"service_profile": profile,
+		})
+	})
+
+	// Delete a ServiceProfile
+	e.DELETE("/api/linkerd/service-profiles/:namespace/:name", func(c echo.Context) error {
+		output, err := kubectlDeleteResource(serviceProfileKind, c.Param("namespace"), c.Param("name"))
+		if err != nil {
+			if isNotFoundError(err) {
+				return c.JSON(http.StatusNotFound, map[string]string{
+					"error": "ServiceProfile not found",
+				})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to delete ServiceProfile: %v", err),
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": output,
+		})
+	})
+}
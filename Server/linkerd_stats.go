@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Linkerd data-plane statistics. Pod counts come from the Kubernetes API and
+// golden metrics (success rate, RPS, latency) from the viz metrics API
+// through linkerd viz stat.
+
+type LinkerdWorkloadStats struct {
+	Namespace   string   `json:"namespace"`
+	Name        string   `json:"name"`
+	MeshedPods  int      `json:"meshed_pods"`
+	TotalPods   int      `json:"total_pods"`
+	SuccessRate *float64 `json:"success_rate"`
+	RPS         float64  `json:"rps"`
+	LatencyP50  *float64 `json:"latency_ms_p50"`
+	LatencyP95  *float64 `json:"latency_ms_p95"`
+	LatencyP99  *float64 `json:"latency_ms_p99"`
+}
+
+type LinkerdNamespaceStats struct {
+	Namespace   string   `json:"namespace"`
+	MeshedPods  int      `json:"meshed_pods"`
+	TotalPods   int      `json:"total_pods"`
+	SuccessRate *float64 `json:"success_rate"`
+	RPS         float64  `json:"rps"`
+}
+
+// Row of linkerd viz stat -o json
+type vizStatRow struct {
+	Namespace    string   `json:"namespace"`
+	Kind         string   `json:"kind"`
+	Name         string   `json:"name"`
+	Meshed       string   `json:"meshed"`
+	Success      *float64 `json:"success"`
+	RPS          *float64 `json:"rps"`
+	LatencyMSp50 *float64 `json:"latency_ms_p50"`
+	LatencyMSp95 *float64 `json:"latency_ms_p95"`
+	LatencyMSp99 *float64 `json:"latency_ms_p99"`
+}
+
+func hasLinkerdProxy(pod corev1.Pod) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == "linkerd-proxy" {
+			return true
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == "linkerd-proxy" {
+			return true
+		}
+	}
+	return false
+}
+
+func linkerdProxyReady(pod corev1.Pod) bool {
+	for _, status := range append(pod.Status.ContainerStatuses, pod.Status.InitContainerStatuses...) {
+		if status.Name == "linkerd-proxy" {
+			return status.Ready
+		}
+	}
+	return false
+}
+
+// Run a viz subcommand. Older CLIs serve it without the viz prefix.
+func runLinkerdViz(args ...string) ([]byte, error) {
+	var lastErr error
+	for _, prefix := range [][]string{{"viz"}, {}} {
+		cmd := exec.Command("linkerd", append(append([]string{}, prefix...), args...)...)
+		output, err := cmd.Output()
+		if err == nil {
+			return output, nil
+		}
+		name := strings.TrimSpace(strings.Join(append(prefix, args[0]), " "))
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			lastErr = fmt.Errorf("linkerd %s failed: %s", name, strings.TrimSpace(string(exitErr.Stderr)))
+		} else {
+			lastErr = fmt.Errorf("linkerd %s failed: %v", name, err)
+		}
+	}
+	return nil, lastErr
+}
+
+// Golden metrics per deployment from the viz metrics API
+func getVizDeploymentStats(namespace string) ([]vizStatRow, error) {
+	args := []string{"stat", "deployments", "--all-namespaces", "--output", "json"}
+	if namespace != "" {
+		args = []string{"stat", "deployments", "-n", namespace, "--output", "json"}
+	}
+
+	output, err := runLinkerdViz(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []vizStatRow
+	if strings.TrimSpace(string(output)) == "" {
+		return rows, nil
+	}
+	if err := json.Unmarshal(output, &rows); err != nil {
+		return nil, fmt.Errorf("failed to parse linkerd stat output: %v", err)
+	}
+	return rows, nil
+}
+
+// Namespaces left out of coverage; they are never meshed
+func isSystemNamespace(namespace string) bool {
+	return strings.HasPrefix(namespace, "kube-")
+}
+
+// Per-deployment and per-namespace stats. The error reports missing viz
+// metrics; pod counts are still returned in that case.
+func getLinkerdWorkloadStats(namespace string) ([]LinkerdWorkloadStats, []LinkerdNamespaceStats, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, nil, err
+	}
+	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+
+	workloads := make(map[string]*LinkerdWorkloadStats)
+	namespaces := make(map[string]*LinkerdNamespaceStats)
+	for _, pod := range pods.Items {
+		if isSystemNamespace(pod.Namespace) || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+
+		key := pod.Namespace + "/" + podWorkloadName(pod)
+		if workloads[key] == nil {
+			workloads[key] = &LinkerdWorkloadStats{Namespace: pod.Namespace, Name: podWorkloadName(pod)}
+		}
+		if namespaces[pod.Namespace] == nil {
+			namespaces[pod.Namespace] = &LinkerdNamespaceStats{Namespace: pod.Namespace}
+		}
+
+		workloads[key].TotalPods++
+		namespaces[pod.Namespace].TotalPods++
+		if hasLinkerdProxy(pod) {
+			workloads[key].MeshedPods++
+			namespaces[pod.Namespace].MeshedPods++
+		}
+	}
+
+	rows, metricsErr := getVizDeploymentStats(namespace)
+	successfulRPS := make(map[string]float64)
+	for _, row := range rows {
+		stats := workloads[row.Namespace+"/"+row.Name]
+		if stats == nil {
+			continue
+		}
+		stats.SuccessRate = row.Success
+		if stats.SuccessRate != nil {
+			rate := *row.Success * 100
+			stats.SuccessRate = &rate
+		}
+		if row.RPS != nil {
+			stats.RPS = *row.RPS
+		}
+		stats.LatencyP50, stats.LatencyP95, stats.LatencyP99 = row.LatencyMSp50, row.LatencyMSp95, row.LatencyMSp99
+
+		// Namespace success rate is weighted by request volume
+		if ns := namespaces[row.Namespace]; ns != nil && row.Success != nil && row.RPS != nil {
+			ns.RPS += *row.RPS
+			successfulRPS[row.Namespace] += *row.Success * *row.RPS
+		}
+	}
+
+	namespaceStats := []LinkerdNamespaceStats{}
+	for name, ns := range namespaces {
+		if ns.RPS > 0 {
+			rate := successfulRPS[name] / ns.RPS * 100
+			ns.SuccessRate = &rate
+		}
+		namespaceStats = append(namespaceStats, *ns)
+	}
+	sort.Slice(namespaceStats, func(i, j int) bool { return namespaceStats[i].Namespace < namespaceStats[j].Namespace })
+
+	workloadStats := []LinkerdWorkloadStats{}
+	for _, stats := range workloads {
+		workloadStats = append(workloadStats, *stats)
+	}
+	sort.Slice(workloadStats, func(i, j int) bool {
+		if workloadStats[i].Namespace != workloadStats[j].Namespace {
+			return workloadStats[i].Namespace < workloadStats[j].Namespace
+		}
+		return workloadStats[i].Name < workloadStats[j].Name
+	})
+
+	return workloadStats, namespaceStats, metricsErr
+}
+
+func getLinkerdDataPlaneStatus() (*LinkerdDataPlane, error) {
+	clientset, err := getKubeClientset()
+	if err != nil {
+		return nil, err
+	}
+	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+
+	dataPlane := &LinkerdDataPlane{}
+	total := 0
+	for _, pod := range pods.Items {
+		if isSystemNamespace(pod.Namespace) || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		total++
+		if hasLinkerdProxy(pod) {
+			dataPlane.ProxiesTotal++
+			if linkerdProxyReady(pod) {
+				dataPlane.ProxiesHealthy++
+			}
+		}
+	}
+	dataPlane.Coverage = fmt.Sprintf("%.1f%%", float64(dataPlane.ProxiesTotal)/float64(max(total, 1))*100)
+
+	workloads, namespaces, err := getLinkerdWorkloadStats("")
+	if err != nil {
+		dataPlane.MetricsError = err.Error()
+	}
+	dataPlane.Workloads = workloads
+	dataPlane.Namespaces = namespaces
+
+	return dataPlane, nil
+}
+
+func registerLinkerdStatsRoutes(e *echo.Echo) {
+	// Meshed pod counts and golden metrics per namespace and deployment
+	e.GET("/api/linkerd/stats", func(c echo.Context) error {
+		workloads, namespaces, err := getLinkerdWorkloadStats(c.QueryParam("namespace"))
+		if workloads == nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to get Linkerd stats: %v", err),
+			})
+		}
+
+		response := map[string]interface{}{
+			"namespaces": namespaces,
+			"workloads":  workloads,
+			"count":      len(workloads),
+		}
+		if err != nil {
+			response["metrics_error"] = err.Error()
+		}
+		return c.JSON(http.StatusOK, response)
+	})
+}
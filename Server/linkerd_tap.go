@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// Live request tapping through linkerd viz tap. The CLI prints one JSON
+// document per tap event (request init, response init, response end); the
+// events of a request share an id and are folded into a single record that
+// is sent to the client once the response ends.
+
+const (
+	defaultTapMaxEvents = 100
+	maxTapMaxEvents     = 1000
+)
+
+var tapResourcePattern = regexp.MustCompile(`^[a-z]+(/[a-z0-9]([-a-z0-9.]*[a-z0-9])?)?$`)
+
+type TapEndpoint struct {
+	Address        string `json:"address"`
+	Pod            string `json:"pod,omitempty"`
+	Namespace      string `json:"namespace,omitempty"`
+	Workload       string `json:"workload,omitempty"`
+	ServiceAccount string `json:"service_account,omitempty"`
+	TLS            bool   `json:"tls"`
+}
+
+type TapEvent struct {
+	ID            string      `json:"id"`
+	Direction     string      `json:"direction"`
+	Method        string      `json:"method"`
+	Authority     string      `json:"authority"`
+	Path          string      `json:"path"`
+	Status        int         `json:"status"`
+	GRPCStatus    *int        `json:"grpc_status,omitempty"`
+	LatencyMS     float64     `json:"latency_ms"`
+	ResponseBytes int64       `json:"response_bytes"`
+	Source        TapEndpoint `json:"source"`
+	Destination   TapEndpoint `json:"destination"`
+	Timestamp     time.Time   `json:"timestamp"`
+}
+
+type TapFilters struct {
+	Namespace   string
+	Resource    string
+	To          string
+	ToNamespace string
+	Method      string
+	Path        string
+	Authority   string
+	MaxRPS      string
+	MaxEvents   int
+}
+
+// Protobuf durations render as {"seconds": n, "nanos": n}
+type tapDuration struct {
+	Seconds int64 `json:"seconds"`
+	Nanos   int64 `json:"nanos"`
+}
+
+func (d *tapDuration) milliseconds() float64 {
+	if d == nil {
+		return 0
+	}
+	return float64(d.Seconds)*1000 + float64(d.Nanos)/1e6
+}
+
+type tapPeer struct {
+	IP       string            `json:"ip"`
+	Port     int               `json:"port"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type tapEventID struct {
+	Base   int64 `json:"base"`
+	Stream int64 `json:"stream"`
+}
+
+type tapRawEvent struct {
+	Source           tapPeer `json:"source"`
+	Destination      tapPeer `json:"destination"`
+	ProxyDirection   string  `json:"proxyDirection"`
+	RequestInitEvent *struct {
+		ID        tapEventID `json:"id"`
+		Method    string     `json:"method"`
+		Authority string     `json:"authority"`
+		Path      string     `json:"path"`
+	} `json:"requestInitEvent"`
+	ResponseInitEvent *struct {
+		ID               tapEventID   `json:"id"`
+		SinceRequestInit *tapDuration `json:"sinceRequestInit"`
+		HTTPStatus       int          `json:"httpStatus"`
+	} `json:"responseInitEvent"`
+	ResponseEndEvent *struct {
+		ID               tapEventID   `json:"id"`
+		SinceRequestInit *tapDuration `json:"sinceRequestInit"`
+		ResponseBytes    int64        `json:"responseBytes"`
+		GRPCStatusCode   *int         `json:"grpcStatusCode"`
+	} `json:"responseEndEvent"`
+}
+
+func (id tapEventID) String() string {
+	return fmt.Sprintf("%d:%d", id.Base, id.Stream)
+}
+
+func tapEndpoint(peer tapPeer) TapEndpoint {
+	endpoint := TapEndpoint{
+		Address:        fmt.Sprintf("%s:%d", peer.IP, peer.Port),
+		Pod:            peer.Metadata["pod"],
+		Namespace:      peer.Metadata["namespace"],
+		ServiceAccount: peer.Metadata["serviceaccount"],
+		TLS:            peer.Metadata["tls"] == "true",
+	}
+	for _, kind := range []string{"deployment", "statefulset", "daemonset", "job"} {
+		if name := peer.Metadata[kind]; name != "" {
+			endpoint.Workload = kind + "/" + name
+			break
+		}
+	}
+	return endpoint
+}
+
+func parseTapFilters(c echo.Context) (TapFilters, []string) {
+	filters := TapFilters{
+		Namespace:   c.QueryParam("namespace"),
+		Resource:    c.QueryParam("resource"),
+		To:          c.QueryParam("to"),
+		ToNamespace: c.QueryParam("to_namespace"),
+		Method:      strings.ToUpper(c.QueryParam("method")),
+		Path:        c.QueryParam("path"),
+		Authority:   c.QueryParam("authority"),
+		MaxRPS:      c.QueryParam("max_rps"),
+		MaxEvents:   defaultTapMaxEvents,
+	}
+	if filters.Namespace == "" {
+		filters.Namespace = "default"
+	}
+
+	var errors []string
+	if filters.Resource == "" {
+		errors = append(errors, "resource is required (e.g. deploy/web or ns/emojivoto)")
+	} else if !tapResourcePattern.MatchString(filters.Resource) {
+		errors = append(errors, fmt.Sprintf("invalid resource %q", filters.Resource))
+	}
+	if filters.To != "" && !tapResourcePattern.MatchString(filters.To) {
+		errors = append(errors, fmt.Sprintf("invalid to %q", filters.To))
+	}
+	if filters.Path != "" && !strings.HasPrefix(filters.Path, "/") {
+		errors = append(errors, "path must start with /")
+	}
+	if filters.MaxRPS != "" {
+		if rps, err := strconv.ParseFloat(filters.MaxRPS, 64); err != nil || rps <= 0 {
+			errors = append(errors, fmt.Sprintf("invalid max_rps %q", filters.MaxRPS))
+		}
+	}
+	if value := c.QueryParam("max_events"); value != "" {
+		maxEvents, err := strconv.Atoi(value)
+		if err != nil || maxEvents < 1 || maxEvents > maxTapMaxEvents {
+			errors = append(errors, fmt.Sprintf("max_events must be between 1 and %d", maxTapMaxEvents))
+		} else {
+			filters.MaxEvents = maxEvents
+		}
+	}
+
+	return filters, errors
+}
+
+func tapArgs(filters TapFilters) []string {
+	args := []string{"tap", filters.Resource, "--namespace", filters.Namespace, "--output", "json"}
+	optional := []struct {
+		flag  string
+		value string
+	}{
+		{"--to", filters.To},
+		{"--to-namespace", filters.ToNamespace},
+		{"--method", filters.Method},
+		{"--path", filters.Path},
+		{"--authority", filters.Authority},
+		{"--max-rps", filters.MaxRPS},
+	}
+	for _, option := range optional {
+		if option.value != "" {
+			args = append(args, option.flag, option.value)
+		}
+	}
+	return args
+}
+
+// Start linkerd viz tap, or plain tap on CLIs that predate the viz
+// extension, and return the running command with its stdout
+func startLinkerdTap(ctx context.Context, filters TapFilters, stderr *bytes.Buffer) (*exec.Cmd, io.ReadCloser, error) {
+	args := tapArgs(filters)
+	if err := exec.Command("linkerd", "viz", "tap", "--help").Run(); err == nil {
+		args = append([]string{"viz"}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, "linkerd", args...)
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start linkerd tap: %v", err)
+	}
+	return cmd, stdout, nil
+}
+
+func writeSSE(w *echo.Response, event string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	w.Flush()
+}
+
+func registerLinkerdTapRoutes(e *echo.Echo) {
+	// Stream tapped requests as server-sent events. Each "tap" event is one
+	// completed request; the stream ends with an "end" event once max_events
+	// requests were sent or the tap stops.
+	e.GET("/api/linkerd/tap", func(c echo.Context) error {
+		filters, errors := parseTapFilters(c)
+		if len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+		if _, err := exec.LookPath("linkerd"); err != nil {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{
+				"error": "Linkerd CLI not found",
+			})
+		}
+
+		ctx, cancel := context.WithCancel(c.Request().Context())
+		defer cancel()
+
+		var stderr bytes.Buffer
+		cmd, stdout, err := startLinkerdTap(ctx, filters, &stderr)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		w := c.Response()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		w.Flush()
+
+		pending := make(map[string]*TapEvent)
+		sent := 0
+		decoder := json.NewDecoder(stdout)
+		for sent < filters.MaxEvents {
+			var raw tapRawEvent
+			if err := decoder.Decode(&raw); err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					log.Printf("Error reading linkerd tap output: %v", err)
+					writeSSE(w, "error", map[string]string{"error": fmt.Sprintf("tap stream failed: %v", err)})
+				}
+				break
+			}
+
+			switch {
+			case raw.RequestInitEvent != nil:
+				id := raw.RequestInitEvent.ID.String()
+				pending[id] = &TapEvent{
+					ID:          id,
+					Direction:   strings.ToLower(raw.ProxyDirection),
+					Method:      raw.RequestInitEvent.Method,
+					Authority:   raw.RequestInitEvent.Authority,
+					Path:        raw.RequestInitEvent.Path,
+					Source:      tapEndpoint(raw.Source),
+					Destination: tapEndpoint(raw.Destination),
+					Timestamp:   time.Now(),
+				}
+
+			case raw.ResponseInitEvent != nil:
+				if event := pending[raw.ResponseInitEvent.ID.String()]; event != nil {
+					event.Status = raw.ResponseInitEvent.HTTPStatus
+					event.LatencyMS = raw.ResponseInitEvent.SinceRequestInit.milliseconds()
+				}
+
+			case raw.ResponseEndEvent != nil:
+				id := raw.ResponseEndEvent.ID.String()
+				event := pending[id]
+				if event == nil {
+					continue
+				}
+				delete(pending, id)
+				event.ResponseBytes = raw.ResponseEndEvent.ResponseBytes
+				event.GRPCStatus = raw.ResponseEndEvent.GRPCStatusCode
+				if event.LatencyMS == 0 {
+					event.LatencyMS = raw.ResponseEndEvent.SinceRequestInit.milliseconds()
+				}
+				writeSSE(w, "tap", event)
+				sent++
+			}
+		}
+
+		// Stop the tap once the cap is reached or the stream broke
+		cancel()
+		cmd.Wait()
+		reason := "max_events reached"
+		if sent < filters.MaxEvents {
+			reason = "tap stopped"
+			if message := strings.TrimSpace(stderr.String()); message != "" {
+				reason = message
+			}
+		}
+		if c.Request().Context().Err() == nil {
+			writeSSE(w, "end", map[string]interface{}{"events": sent, "reason": reason})
+		}
+		return nil
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+)
+
+// SMI TrafficSplit as served by the linkerd-smi extension
+func trafficSplitManifest(split TrafficSplit) k8sResource {
+	return k8sResource{
+		APIVersion: "split.smi-spec.io/v1alpha2",
+		Kind:       "TrafficSplit",
+		Metadata: k8sObjectMeta{
+			Name:      split.Name,
+			Namespace: split.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "meshify"},
+		},
+		Spec: map[string]interface{}{
+			"service":  split.Service,
+			"backends": split.Backends,
+		},
+	}
+}
+
+func validateTrafficSplit(split TrafficSplit) []string {
+	var errors []string
+
+	if split.Name == "" {
+		errors = append(errors, "name is required")
+	}
+	if split.Service == "" {
+		errors = append(errors, "service is required")
+	}
+	if len(split.Backends) == 0 {
+		errors = append(errors, "at least one backend is required")
+	}
+
+	total := 0
+	for i, backend := range split.Backends {
+		if backend.Service == "" {
+			errors = append(errors, fmt.Sprintf("backends[%d]: service is required", i))
+		}
+		if backend.Weight < 0 {
+			errors = append(errors, fmt.Sprintf("backends[%d]: weight must not be negative", i))
+		}
+		total += backend.Weight
+	}
+	if len(split.Backends) > 0 && total == 0 {
+		errors = append(errors, "at least one backend must have a positive weight")
+	}
+
+	return errors
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/rest"
@@ -70,17 +71,24 @@ type ServicePort struct {
 }
 
 type VirtualService struct {
-	Name      string   `json:"name"`
-	Namespace string   `json:"namespace"`
-	Hosts     []string `json:"hosts"`
-	Gateways  []string `json:"gateways"`
+	Name      string                   `json:"name"`
+	Namespace string                   `json:"namespace"`
+	Hosts     []string                 `json:"hosts"`
+	Gateways  []string                 `json:"gateways"`
+	ExportTo  []string                 `json:"export_to,omitempty"`
+	HTTP      []HTTPRoute              `json:"http,omitempty"`
+	TCP       []map[string]interface{} `json:"tcp,omitempty"`
+	TLS       []map[string]interface{} `json:"tls,omitempty"`
 }
 
 type Gateway struct {
-	Name      string   `json:"name"`
-	Namespace string   `json:"namespace"`
-	Hosts     []string `json:"hosts"`
-	Port      int32    `json:"port"`
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Hosts     []string          `json:"hosts"`
+	Port      int32             `json:"port"`
+	Selector  map[string]string `json:"selector,omitempty"`
+	Servers   []GatewayServer   `json:"servers"`
+	Addresses []string          `json:"addresses,omitempty"`
 }
 
 type DeploymentResponse struct {
@@ -125,6 +133,7 @@ type LinkerdComponent struct {
 	Age       string `json:"age"`
 	Image     string `json:"image,omitempty"`
 	Type      string `json:"type"` // deployment, daemonset, etc.
+	Extension string `json:"extension,omitempty"` // viz, jaeger or multicluster
 }
 
 type LinkerdService struct {
@@ -150,10 +159,11 @@ type TrafficSplitBackend struct {
 }
 
 type ServiceProfile struct {
-	Name      string   `json:"name"`
-	Namespace string   `json:"namespace"`
-	Routes    []string `json:"routes"`
-	RetryBudget string `json:"retry_budget,omitempty"`
+	Name        string                `json:"name"`
+	Namespace   string                `json:"namespace"`
+	Service     string                `json:"service,omitempty"`
+	Routes      []ServiceProfileRoute `json:"routes"`
+	RetryBudget *RetryBudget          `json:"retry_budget,omitempty"`
 }
 
 type LinkerdApplication struct {
@@ -177,6 +187,12 @@ type LinkerdStatus struct {
 	Services        []LinkerdService        `json:"services"`
 	TrafficSplits   []TrafficSplit          `json:"traffic_splits"`
 	ServiceProfiles []ServiceProfile        `json:"service_profiles"`
+	HTTPRoutes      []LinkerdHTTPRoute      `json:"http_routes"`
+	GatewayHTTPRoutes []GatewayHTTPRoute    `json:"gateway_http_routes"`
+	Servers         []LinkerdServer         `json:"servers"`
+	AuthorizationPolicies  []LinkerdAuthorizationPolicy `json:"authorization_policies"`
+	MeshTLSAuthentications []MeshTLSAuthentication      `json:"mesh_tls_authentications"`
+	Certificates    *LinkerdCertificateStatus `json:"certificates,omitempty"`
 }
 
 type LinkerdControlPlane struct {
@@ -184,12 +200,18 @@ type LinkerdControlPlane struct {
 	Version     string `json:"version"`
 	Namespace   string `json:"namespace"`
 	Healthy     bool   `json:"healthy"`
+	Failures    int    `json:"failures"`
+	Warnings    int    `json:"warnings"`
+	Checks      []LinkerdCheck `json:"checks"`
 }
 
 type LinkerdDataPlane struct {
 	ProxiesTotal   int `json:"proxies_total"`
 	ProxiesHealthy int `json:"proxies_healthy"`
 	Coverage       string `json:"coverage"`
+	Namespaces     []LinkerdNamespaceStats `json:"namespaces"`
+	Workloads      []LinkerdWorkloadStats  `json:"workloads"`
+	MetricsError   string `json:"metrics_error,omitempty"`
 }
 
 // Add these structures after the existing Linkerd structures
@@ -495,23 +517,6 @@ func downloadIstioSamples() (string, error) {
 	return bookinfoPath, nil
 }
 
-func downloadGateway() (string, error) {
-	tmpDir, err := ioutil.TempDir("", "istio-gateway-")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %v", err)
-	}
-
-	gatewayURL := "https://raw.githubusercontent.com/istio/istio/release-1.17/samples/bookinfo/networking/bookinfo-gateway.yaml"
-	gatewayPath := filepath.Join(tmpDir, "bookinfo-gateway.yaml")
-	
-	if err := downloadFile(gatewayURL, gatewayPath); err != nil {
-		os.RemoveAll(tmpDir)
-		return "", fmt.Errorf("failed to download gateway.yaml: %v", err)
-	}
-	
-	return gatewayPath, nil
-}
-
 // Helper function to download files
 func downloadFile(url, filepath string) error {
 	resp, err := http.Get(url)
@@ -565,66 +570,6 @@ func getBookinfoPath() (string, func(), error) {
 	return downloadedPath, cleanup, nil
 }
 
-func getGatewayPath() (string, func(), error) {
-	// Try local first
-	localPaths := []string{
-		filepath.Join(os.Getenv("HOME"), "istio-1.17.2", "samples", "bookinfo", "networking", "bookinfo-gateway.yaml"),
-		filepath.Join(os.Getenv("HOME"), "istio", "samples", "bookinfo", "networking", "bookinfo-gateway.yaml"),
-		"/usr/local/istio/samples/bookinfo/networking/bookinfo-gateway.yaml",
-	}
-
-	for _, path := range localPaths {
-		if _, err := os.Stat(path); err == nil {
-			return path, func() {}, nil
-		}
-	}
-
-	// Download if not found
-	downloadedPath, err := downloadGateway()
-	if err != nil {
-		return "", nil, err
-	}
-
-	cleanup := func() {
-		os.RemoveAll(filepath.Dir(downloadedPath))
-	}
-
-	return downloadedPath, cleanup, nil
-}
-
-func getIngressIP() string {
-	gatewayPath, cleanup, err := getGatewayPath()
-	if err != nil {
-		log.Printf("Failed to get gateway configuration: %s", err.Error())
-		return ""
-	}
-	defer cleanup()
-
-	// Apply gateway configuration
-	gatewayCmd := exec.Command("kubectl", "apply", "-f", gatewayPath)
-	if err := gatewayCmd.Run(); err != nil {
-		log.Printf("Failed to apply gateway: %s", err.Error())
-		return ""
-	}
-
-	time.Sleep(5 * time.Second)
-
-	// Get ingress IP
-	cmd := exec.Command("kubectl", "get", "svc", "istio-ingressgateway", "-n", "istio-system", "-o", "jsonpath={.status.loadBalancer.ingress[0].ip}")
-	output, err := cmd.Output()
-	if err != nil {
-		// Fallback to external IP
-		cmd = exec.Command("kubectl", "get", "svc", "istio-ingressgateway", "-n", "istio-system", "-o", "jsonpath={.spec.externalIPs[0]}")
-		output, err = cmd.Output()
-		if err != nil {
-			log.Printf("Failed to get ingress IP: %s", err.Error())
-			return ""
-		}
-	}
-
-	return strings.TrimSpace(string(output))
-	}
-
 func extractTitle(html string) string {
 	// Define a regular expression pattern to match the title tag
 	titleRegex := regexp.MustCompile(`<title>(.*?)</title>`)
@@ -743,103 +688,6 @@ func getIstioServices(clientset *kubernetes.Clientset) ([]IstioService, error) {
 	return services, nil
 }
 
-// Get Virtual Services using kubectl
-func getVirtualServices() ([]VirtualService, error) {
-	cmd := exec.Command("kubectl", "get", "virtualservices", "-A", "-o", "json")
-	output, err := cmd.Output()
-		if err != nil {
-		return []VirtualService{}, nil // Return empty if not found
-	}
-	
-	var result map[string]interface{}
-	if err := json.Unmarshal(output, &result); err != nil {
-		return []VirtualService{}, nil
-	}
-	
-	var virtualServices []VirtualService
-	if items, ok := result["items"].([]interface{}); ok {
-		for _, item := range items {
-			if vs, ok := item.(map[string]interface{}); ok {
-				metadata := vs["metadata"].(map[string]interface{})
-				spec := vs["spec"].(map[string]interface{})
-				
-				var hosts []string
-				if hostList, ok := spec["hosts"].([]interface{}); ok {
-					for _, host := range hostList {
-						hosts = append(hosts, host.(string))
-					}
-				}
-				
-				var gateways []string
-				if gatewayList, ok := spec["gateways"].([]interface{}); ok {
-					for _, gateway := range gatewayList {
-						gateways = append(gateways, gateway.(string))
-					}
-				}
-				
-				virtualServices = append(virtualServices, VirtualService{
-					Name:      metadata["name"].(string),
-					Namespace: metadata["namespace"].(string),
-					Hosts:     hosts,
-					Gateways:  gateways,
-				})
-			}
-		}
-	}
-	
-	return virtualServices, nil
-}
-
-// Get Gateways using kubectl
-func getGateways() ([]Gateway, error) {
-	cmd := exec.Command("kubectl", "get", "gateways", "-A", "-o", "json")
-	output, err := cmd.Output()
-		if err != nil {
-		return []Gateway{}, nil // Return empty if not found
-	}
-	
-	var result map[string]interface{}
-	if err := json.Unmarshal(output, &result); err != nil {
-		return []Gateway{}, nil
-	}
-	
-	var gateways []Gateway
-	if items, ok := result["items"].([]interface{}); ok {
-		for _, item := range items {
-			if gw, ok := item.(map[string]interface{}); ok {
-				metadata := gw["metadata"].(map[string]interface{})
-				spec := gw["spec"].(map[string]interface{})
-				
-				var hosts []string
-				var port int32
-				
-				// Remove the unused selector variable and fix the logic
-				if servers, ok := spec["servers"].([]interface{}); ok && len(servers) > 0 {
-					server := servers[0].(map[string]interface{})
-					if hostList, ok := server["hosts"].([]interface{}); ok {
-						for _, host := range hostList {
-							hosts = append(hosts, host.(string))
-						}
-					}
-					if portInfo, ok := server["port"].(map[string]interface{}); ok {
-						if p, ok := portInfo["number"].(float64); ok {
-							port = int32(p)
-						}
-					}
-				}
-				
-				gateways = append(gateways, Gateway{
-					Name:      metadata["name"].(string),
-					Namespace: metadata["namespace"].(string),
-					Hosts:     hosts,
-					Port:      port,
-				})
-			}
-		}
-	}
-	
-	return gateways, nil
-}
 
 // Get application status (like bookinfo)
 func getApplicationStatus(clientset *kubernetes.Clientset, namespace, appLabel string) (*ApplicationStatus, error) {
@@ -975,7 +823,10 @@ func checkLinkerdInstallation() (bool, *LinkerdStatus, error) {
 		log.Printf("Warning: Could not get control plane status: %v", err)
 		controlPlane = &LinkerdControlPlane{
 			Status: "Unknown",
+			Version: "Unknown",
+			Namespace: "linkerd",
 			Healthy: false,
+			Checks: []LinkerdCheck{},
 		}
 	}
 
@@ -999,83 +850,43 @@ func checkLinkerdInstallation() (bool, *LinkerdStatus, error) {
 		Services:     []LinkerdService{},
 		TrafficSplits: []TrafficSplit{},
 		ServiceProfiles: []ServiceProfile{},
+		HTTPRoutes: []LinkerdHTTPRoute{},
+		GatewayHTTPRoutes: []GatewayHTTPRoute{},
+		Servers: []LinkerdServer{},
+		AuthorizationPolicies: []LinkerdAuthorizationPolicy{},
+		MeshTLSAuthentications: []MeshTLSAuthentication{},
 	}
 
 	return true, status, nil
 }
 
 func getLinkerdControlPlaneStatus() (*LinkerdControlPlane, error) {
-	cmd := exec.Command("linkerd", "check", "--output", "json")
-	output, err := cmd.Output()
-		if err != nil {
-		// Fallback to basic check
-		cmd = exec.Command("linkerd", "version", "--output", "json")
-		output, err = cmd.Output()
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// Parse JSON output for detailed status
-	var result map[string]interface{}
-	if err := json.Unmarshal(output, &result); err != nil {
-		// If JSON parsing fails, do basic check
-		return &LinkerdControlPlane{
-			Status: "Running",
-			Version: "Unknown",
-			Namespace: "linkerd",
-			Healthy: true,
-		}, nil
+	checks, err := runLinkerdCheck()
+	if err != nil {
+		return nil, err
 	}
 
-	// Extract control plane info from linkerd check output
-	healthy := true
-	status := "Running"
-	
-	// This is a simplified parsing - actual linkerd check output is complex
 	controlPlane := &LinkerdControlPlane{
-		Status: status,
-		Version: "Unknown",
+		Status:    "Running",
+		Version:   getLinkerdServerVersion(),
 		Namespace: "linkerd",
-		Healthy: healthy,
-	}
-
-	return controlPlane, nil
-}
-
-func getLinkerdDataPlaneStatus() (*LinkerdDataPlane, error) {
-	// Get proxy status using linkerd stat
-	cmd := exec.Command("linkerd", "stat", "deployments", "--output", "json")
-	output, err := cmd.Output()
-		if err != nil {
-		return &LinkerdDataPlane{
-			ProxiesTotal: 0,
-			ProxiesHealthy: 0,
-			Coverage: "0%",
-		}, nil
+		Checks:    checks,
 	}
-
-	// Parse the stat output to get proxy counts
-	var statResult map[string]interface{}
-	if err := json.Unmarshal(output, &statResult); err != nil {
-		return &LinkerdDataPlane{
-			ProxiesTotal: 0,
-			ProxiesHealthy: 0,
-			Coverage: "0%",
-		}, nil
+	for _, check := range checks {
+		switch check.Status {
+		case "error":
+			controlPlane.Failures++
+		case "warning":
+			controlPlane.Warnings++
+		}
 	}
 
-	// Extract proxy statistics
-	total := 0
-	healthy := 0
-	
-	dataPlane := &LinkerdDataPlane{
-		ProxiesTotal: total,
-		ProxiesHealthy: healthy,
-		Coverage: fmt.Sprintf("%.1f%%", float64(healthy)/float64(max(total, 1))*100),
+	controlPlane.Healthy = controlPlane.Failures == 0
+	if !controlPlane.Healthy {
+		controlPlane.Status = "Degraded"
 	}
 
-	return dataPlane, nil
+	return controlPlane, nil
 }
 
 func max(a, b int) int {
@@ -1085,78 +896,87 @@ func max(a, b int) int {
 	return b
 }
 
-// Get Linkerd components
+// Get Linkerd components of the control plane and installed extensions
 func getLinkerdComponents(clientset *kubernetes.Clientset) ([]LinkerdComponent, error) {
 	var components []LinkerdComponent
-	
-	// Get deployments in linkerd namespace
-	deployments, err := clientset.AppsV1().Deployments("linkerd").List(context.Background(), metav1.ListOptions{})
-		if err != nil {
-		return nil, err
-	}
-	
-	for _, deployment := range deployments.Items {
-		status := "Unknown"
-		ready := fmt.Sprintf("%d/%d", deployment.Status.ReadyReplicas, deployment.Status.Replicas)
-		
-		if deployment.Status.ReadyReplicas == deployment.Status.Replicas {
-			status = "Running"
-		} else if deployment.Status.ReadyReplicas == 0 {
-			status = "Not Ready"
-		} else {
-			status = "Partially Ready"
+
+	for _, namespace := range linkerdComponentNamespaces() {
+		extension := ""
+		if namespace != linkerdNamespace {
+			extension = strings.TrimPrefix(namespace, "linkerd-")
 		}
-		
-		age := time.Since(deployment.CreationTimestamp.Time).Round(time.Second).String()
-		
-		var image string
-		if len(deployment.Spec.Template.Spec.Containers) > 0 {
-			image = deployment.Spec.Template.Spec.Containers[0].Image
+
+		// Get deployments in the namespace
+		deployments, err := clientset.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-		
-		components = append(components, LinkerdComponent{
-			Name:      deployment.Name,
-			Namespace: deployment.Namespace,
-			Status:    status,
-			Ready:     ready,
-			Age:       age,
-			Image:     image,
-			Type:      "Deployment",
-		})
-	}
 
-	// Get daemonsets in linkerd namespace
-	daemonsets, err := clientset.AppsV1().DaemonSets("linkerd").List(context.Background(), metav1.ListOptions{})
-	if err == nil {
-		for _, ds := range daemonsets.Items {
+		for _, deployment := range deployments.Items {
 			status := "Unknown"
-			ready := fmt.Sprintf("%d/%d", ds.Status.NumberReady, ds.Status.DesiredNumberScheduled)
-			
-			if ds.Status.NumberReady == ds.Status.DesiredNumberScheduled {
+			ready := fmt.Sprintf("%d/%d", deployment.Status.ReadyReplicas, deployment.Status.Replicas)
+
+			if deployment.Status.ReadyReplicas == deployment.Status.Replicas {
 				status = "Running"
-			} else {
+			} else if deployment.Status.ReadyReplicas == 0 {
 				status = "Not Ready"
+			} else {
+				status = "Partially Ready"
 			}
-			
-			age := time.Since(ds.CreationTimestamp.Time).Round(time.Second).String()
-			
+
+			age := time.Since(deployment.CreationTimestamp.Time).Round(time.Second).String()
+
 			var image string
-			if len(ds.Spec.Template.Spec.Containers) > 0 {
-				image = ds.Spec.Template.Spec.Containers[0].Image
+			if len(deployment.Spec.Template.Spec.Containers) > 0 {
+				image = deployment.Spec.Template.Spec.Containers[0].Image
 			}
-			
+
 			components = append(components, LinkerdComponent{
-				Name:      ds.Name,
-				Namespace: ds.Namespace,
+				Name:      deployment.Name,
+				Namespace: deployment.Namespace,
 				Status:    status,
 				Ready:     ready,
 				Age:       age,
 				Image:     image,
-				Type:      "DaemonSet",
+				Type:      "Deployment",
+				Extension: extension,
 			})
 		}
+
+		// Get daemonsets in the namespace
+		daemonsets, err := clientset.AppsV1().DaemonSets(namespace).List(context.Background(), metav1.ListOptions{})
+		if err == nil {
+			for _, ds := range daemonsets.Items {
+				status := "Unknown"
+				ready := fmt.Sprintf("%d/%d", ds.Status.NumberReady, ds.Status.DesiredNumberScheduled)
+
+				if ds.Status.NumberReady == ds.Status.DesiredNumberScheduled {
+					status = "Running"
+				} else {
+					status = "Not Ready"
+				}
+
+				age := time.Since(ds.CreationTimestamp.Time).Round(time.Second).String()
+
+				var image string
+				if len(ds.Spec.Template.Spec.Containers) > 0 {
+					image = ds.Spec.Template.Spec.Containers[0].Image
+				}
+
+				components = append(components, LinkerdComponent{
+					Name:      ds.Name,
+					Namespace: ds.Namespace,
+					Status:    status,
+					Ready:     ready,
+					Age:       age,
+					Image:     image,
+					Type:      "DaemonSet",
+					Extension: extension,
+				})
+			}
+		}
 	}
-	
+
 	return components, nil
 }
 
@@ -1170,6 +990,21 @@ func getLinkerdServices(clientset *kubernetes.Clientset) ([]LinkerdService, erro
 		return nil, err
 	}
 	
+	// Pods are listed once per namespace and matched to selectors in memory
+	podsByNamespace := map[string][]corev1.Pod{}
+	namespacePods := func(namespace string) []corev1.Pod {
+		if pods, ok := podsByNamespace[namespace]; ok {
+			return pods
+		}
+		var pods []corev1.Pod
+		podList, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
+		if err == nil {
+			pods = podList.Items
+		}
+		podsByNamespace[namespace] = pods
+		return pods
+	}
+
 	for _, svc := range svcList.Items {
 		var ports []ServicePort
 		for _, port := range svc.Spec.Ports {
@@ -1181,12 +1016,23 @@ func getLinkerdServices(clientset *kubernetes.Clientset) ([]LinkerdService, erro
 			})
 		}
 		
-		// Check if service has linkerd annotation (proxy injection)
+		// Injected when every pod behind the service runs a proxy
 		injected := false
-		if svc.Annotations != nil {
-			if _, exists := svc.Annotations["linkerd.io/inject"]; exists {
-				injected = true
+		if len(svc.Spec.Selector) > 0 {
+			selector := labels.SelectorFromSet(svc.Spec.Selector)
+			matched := 0
+			injected = true
+			for _, pod := range namespacePods(svc.Namespace) {
+				if !selector.Matches(labels.Set(pod.Labels)) {
+					continue
+				}
+				matched++
+				if !hasLinkerdProxy(pod) {
+					injected = false
+					break
+				}
 			}
+			injected = injected && matched > 0
 		}
 		
 		services = append(services, LinkerdService{
@@ -1257,57 +1103,6 @@ func getTrafficSplits() ([]TrafficSplit, error) {
 	return trafficSplits, nil
 }
 
-// Get Service Profiles using kubectl
-func getServiceProfiles() ([]ServiceProfile, error) {
-	cmd := exec.Command("kubectl", "get", "serviceprofile", "-A", "-o", "json")
-	output, err := cmd.Output()
-		if err != nil {
-		return []ServiceProfile{}, nil // Return empty if not found
-	}
-	
-	var result map[string]interface{}
-	if err := json.Unmarshal(output, &result); err != nil {
-		return []ServiceProfile{}, nil
-	}
-	
-	var serviceProfiles []ServiceProfile
-	if items, ok := result["items"].([]interface{}); ok {
-		for _, item := range items {
-			if sp, ok := item.(map[string]interface{}); ok {
-				metadata := sp["metadata"].(map[string]interface{})
-				spec := sp["spec"].(map[string]interface{})
-				
-				var routes []string
-				if routeList, ok := spec["routes"].([]interface{}); ok {
-					for _, route := range routeList {
-						if r, ok := route.(map[string]interface{}); ok {
-							if name, ok := r["name"].(string); ok {
-								routes = append(routes, name)
-							}
-						}
-					}
-				}
-				
-				retryBudget := ""
-				if rb, ok := spec["retryBudget"].(map[string]interface{}); ok {
-					if ratio, ok := rb["retryRatio"].(float64); ok {
-						retryBudget = fmt.Sprintf("%.2f", ratio)
-					}
-				}
-				
-				serviceProfiles = append(serviceProfiles, ServiceProfile{
-					Name:        metadata["name"].(string),
-					Namespace:   metadata["namespace"].(string),
-					Routes:      routes,
-					RetryBudget: retryBudget,
-				})
-			}
-		}
-	}
-	
-	return serviceProfiles, nil
-}
-
 // Deploy sample application for Linkerd
 func deployLinkerdSample() error {
 	// Deploy emojivoto sample app
@@ -1318,12 +1113,6 @@ func deployLinkerdSample() error {
 	return cmd.Run()
 }
 
-// Inject Linkerd proxy into namespace
-func injectLinkerdProxy(namespace string) error {
-	cmd := exec.Command("kubectl", "annotate", "namespace", namespace, "linkerd.io/inject=enabled")
-	return cmd.Run()
-}
-
 // Helper functions for monitoring services
 
 func checkPrometheusStatus() (*MonitoringService, error) {
@@ -2169,6 +1958,12 @@ func main() {
 	// Start real-time metrics collection
 	startMetricsCollection()
 
+	// Start the progressive delivery controller
+	startRolloutController()
+
+	// Revert chaos faults left behind by a previous run
+	go recoverChaosExperiments()
+
 	// === LINKERD ROUTES ===
 	
 	// Get comprehensive Linkerd status
@@ -2239,6 +2034,53 @@ func main() {
 		}
 		status.ServiceProfiles = serviceProfiles
 
+		// Get policy resources
+		httpRoutes, err := getLinkerdHTTPRoutes("")
+		if err != nil {
+			log.Printf("Error getting Linkerd HTTPRoutes: %v", err)
+			httpRoutes = []LinkerdHTTPRoute{}
+		}
+		status.HTTPRoutes = httpRoutes
+
+		gatewayHTTPRoutes, err := getGatewayHTTPRoutes("")
+		if err != nil {
+			log.Printf("Error getting Gateway API HTTPRoutes: %v", err)
+			gatewayHTTPRoutes = []GatewayHTTPRoute{}
+		}
+		status.GatewayHTTPRoutes = gatewayHTTPRoutes
+
+		servers, err := getLinkerdServers("")
+		if err != nil {
+			log.Printf("Error getting Linkerd Servers: %v", err)
+			servers = []LinkerdServer{}
+		}
+		status.Servers = servers
+
+		authorizationPolicies, err := getLinkerdAuthorizationPolicies("")
+		if err != nil {
+			log.Printf("Error getting Linkerd AuthorizationPolicies: %v", err)
+			authorizationPolicies = []LinkerdAuthorizationPolicy{}
+		}
+		status.AuthorizationPolicies = authorizationPolicies
+
+		meshTLSAuthentications, err := getMeshTLSAuthentications("")
+		if err != nil {
+			log.Printf("Error getting MeshTLSAuthentications: %v", err)
+			meshTLSAuthentications = []MeshTLSAuthentication{}
+		}
+		status.MeshTLSAuthentications = meshTLSAuthentications
+
+		// Get identity certificate expiry
+		certificates, err := getLinkerdCertificateStatus(defaultCertificateWarnDays)
+		if err != nil {
+			log.Printf("Error getting Linkerd certificates: %v", err)
+		} else {
+			for _, warning := range certificates.Warnings {
+				log.Printf("Warning: Linkerd %s", warning)
+			}
+			status.Certificates = certificates
+		}
+
 		return c.JSON(http.StatusOK, status)
 	})
 
@@ -2300,34 +2142,6 @@ func main() {
 			return c.JSON(http.StatusOK, adapters)
 	})
 
-	// Install Linkerd
-	e.POST("/api/linkerd/install", func(c echo.Context) error {
-		response := map[string]interface{}{
-			"success": false,
-			"message": "",
-		}
-
-		// Install Linkerd using CLI
-		installCmd := exec.Command("linkerd", "install")
-		installOutput, err := installCmd.Output()
-		if err != nil {
-			response["message"] = fmt.Sprintf("Failed to generate Linkerd manifests: %v", err)
-			return c.JSON(http.StatusInternalServerError, response)
-		}
-
-		// Apply the manifests
-		applyCmd := exec.Command("kubectl", "apply", "-f", "-")
-		applyCmd.Stdin = bytes.NewReader(installOutput)
-		if err := applyCmd.Run(); err != nil {
-			response["message"] = fmt.Sprintf("Failed to apply Linkerd manifests: %v", err)
-			return c.JSON(http.StatusInternalServerError, response)
-		}
-
-		response["success"] = true
-		response["message"] = "Linkerd installed successfully"
-		return c.JSON(http.StatusOK, response)
-	})
-
 	// Uninstall Linkerd
 	e.DELETE("/api/linkerd/uninstall", func(c echo.Context) error {
 		response := map[string]interface{}{
@@ -2358,7 +2172,6 @@ func main() {
 
 	// Deploy Emojivoto sample application
 	e.POST("/api/linkerd/applications/:namespace/emojivoto/deploy", func(c echo.Context) error {
-		namespace := c.Param("namespace")
 		response := map[string]interface{}{
 			"success": false,
 			"message": "",
@@ -2370,8 +2183,8 @@ func main() {
 			return c.JSON(http.StatusInternalServerError, response)
 		}
 
-		// Inject Linkerd proxy into the namespace
-		if err := injectLinkerdProxy(namespace); err != nil {
+		// Inject Linkerd proxy into the namespace the manifest creates
+		if err := injectLinkerdProxy("emojivoto"); err != nil {
 			log.Printf("Warning: Failed to inject proxy: %v", err)
 		}
 
@@ -2453,13 +2266,27 @@ func main() {
 			}
 		}
 
+		// Golden metrics from viz; success rate stays null without traffic
+		var successRate *float64
+		rps := 0.0
+		_, namespaceStats, err := getLinkerdWorkloadStats("emojivoto")
+		if err != nil {
+			log.Printf("Warning: Could not get emojivoto metrics: %v", err)
+		}
+		for _, stats := range namespaceStats {
+			if stats.Namespace == "emojivoto" {
+				successRate, rps = stats.SuccessRate, stats.RPS
+			}
+		}
+
 		app := map[string]interface{}{
 			"name":          "emojivoto",
 			"namespace":     "emojivoto",
 			"total_pods":    totalPods,
 			"running_pods":  runningPods,
 			"injected_pods": injectedPods,
-			"success_rate":  95.5,
+			"success_rate":  successRate,
+			"rps":           rps,
 			"status":        "Running",
 		}
 
@@ -2468,33 +2295,63 @@ func main() {
 
 	// Get traffic splits
 	e.GET("/api/linkerd/traffic-splits/:namespace", func(c echo.Context) error {
-		// Mock implementation - would query actual TrafficSplit resources
-		splits := []map[string]interface{}{}
-		return c.JSON(http.StatusOK, splits)
-	})
+		namespace := c.Param("namespace")
+
+		trafficSplits, err := getTrafficSplits()
+		if err != nil {
+			log.Printf("Error getting Traffic Splits: %v", err)
+			trafficSplits = []TrafficSplit{}
+		}
 
-	// Get service profiles
-	e.GET("/api/linkerd/service-profiles/:namespace", func(c echo.Context) error {
-		// Mock implementation - would query actual ServiceProfile resources
-		profiles := []map[string]interface{}{}
-		return c.JSON(http.StatusOK, profiles)
+		splits := []TrafficSplit{}
+		for _, split := range trafficSplits {
+			if split.Namespace == namespace {
+				splits = append(splits, split)
+			}
+		}
+		return c.JSON(http.StatusOK, splits)
 	})
 
 	// Create traffic split
 	e.POST("/api/linkerd/traffic-splits/:namespace", func(c echo.Context) error {
-		var splitConfig map[string]interface{}
-		if err := c.Bind(&splitConfig); err != nil {
+		var split TrafficSplit
+		if err := c.Bind(&split); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{
 				"error": "Invalid JSON payload",
 			})
 		}
+		split.Namespace = c.Param("namespace")
+
+		if errors := validateTrafficSplit(split); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		if err := kubectlDryRun(trafficSplitManifest(split), true); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": []string{err.Error()},
+			})
+		}
+
+		output, err := kubectlCreate(trafficSplitManifest(split), false)
+		if err != nil {
+			log.Printf("Error creating TrafficSplit: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": fmt.Sprintf("Failed to create traffic split: %v", err),
+			})
+		}
 
 		response := map[string]interface{}{
-			"success": true,
-			"message": "Traffic split created successfully",
+			"success":       true,
+			"message":       "Traffic split created successfully",
+			"output":        output,
+			"traffic_split": split,
 		}
 
-		return c.JSON(http.StatusOK, response)
+		return c.JSON(http.StatusCreated, response)
 	})
 
 	// === END LINKERD ROUTES ===
@@ -3436,18 +3293,48 @@ func main() {
 	// Download and install Istio CLI
 	e.POST("/api/istio/download", func(c echo.Context) error {
 		log.Println("Starting Istio download...")
+
+		var request struct {
+			Version string `json:"version"`
+		}
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid download payload",
+			})
+		}
 		
-		// Check if already available
-		if err := checkIstioCLI(); err == nil {
+		// Check if already available. An explicit version is fetched into the
+		// mirror even when some istioctl is already on the PATH.
+		if request.Version == "" {
+			if err := checkIstioCLI(); err == nil {
+				return c.JSON(http.StatusOK, map[string]interface{}{
+					"success": true,
+					"message": "Istio CLI is already available",
+					"status": "already_installed",
+				})
+			}
+			request.Version = os.Getenv("ISTIO_VERSION")
+		}
+		if request.Version == "" {
+			request.Version = defaultIstioVersion
+		}
+		// The version ends up in the download script's environment and in
+		// mirror paths
+		if !istioVersionPattern.MatchString(request.Version) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("Invalid Istio version %q", request.Version),
+			})
+		}
+		if _, err := istioctlForVersion(request.Version); err == nil {
 			return c.JSON(http.StatusOK, map[string]interface{}{
 				"success": true,
-				"message": "Istio CLI is already available",
+				"message": fmt.Sprintf("Istio %s is already in the mirror", request.Version),
 				"status": "already_installed",
 			})
 		}
 		
 		// Download Istio
-		if err := downloadIstio(); err != nil {
+		if err := downloadIstio(request.Version); err != nil {
 			log.Printf("Error downloading Istio: %v", err)
 			return c.JSON(http.StatusInternalServerError, map[string]string{
 				"error": fmt.Sprintf("Failed to download Istio: %v", err),
@@ -3456,14 +3343,36 @@ func main() {
 		
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"success": true,
-			"message": "Istio CLI downloaded successfully",
+			"message": fmt.Sprintf("Istio %s downloaded successfully", request.Version),
 			"status": "downloaded",
+			"version": request.Version,
 		})
 	})
 
 	// Install Istio using istioctl
 	e.POST("/api/istio/install", func(c echo.Context) error {
 		log.Println("Starting Istio installation...")
+
+		var options IstioInstallOptions
+		if err := c.Bind(&options); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid install options",
+			})
+		}
+		normalizeIstioInstallOptions(&options)
+
+		if errors := validateIstioInstallOptions(options); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+		if errors := checkInjectionNamespaces(options.InjectionNamespaces); len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
 		
 		// Check if CLI is available
 		if err := checkIstioCLI(); err != nil {
@@ -3473,7 +3382,8 @@ func main() {
 		}
 		
 		// Install Istio
-		if err := installIstio(); err != nil {
+		output, labelled, err := installIstio("istioctl", options)
+		if err != nil {
 			log.Printf("Error installing Istio: %v", err)
 			return c.JSON(http.StatusInternalServerError, map[string]string{
 				"error": fmt.Sprintf("Failed to install Istio: %v", err),
@@ -3482,8 +3392,11 @@ func main() {
 		
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"success": true,
-			"message": "Istio installed successfully",
+			"message": fmt.Sprintf("Istio installed successfully with the %s profile", options.Profile),
 			"status": "installed",
+			"profile": options.Profile,
+			"injection_namespaces": labelled,
+			"output": output,
 		})
 	})
 
@@ -3502,6 +3415,96 @@ func main() {
 
 	// === END ENHANCED ISTIO INSTALLATION ROUTES ===
 
+	// === ISTIO INSTALL OPTIONS ROUTES ===
+
+	registerIstioInstallRoutes(e)
+	registerIstioRevisionRoutes(e)
+	registerIstioUninstallRoutes(e)
+
+	// === END ISTIO INSTALL OPTIONS ROUTES ===
+
+	// === ISTIO TRAFFIC MANAGEMENT ROUTES ===
+
+	registerVirtualServiceRoutes(e)
+	registerDestinationRuleRoutes(e)
+	registerServiceEntryRoutes(e)
+	registerEgressRoutes(e)
+
+	// === END ISTIO TRAFFIC MANAGEMENT ROUTES ===
+
+	// === PROGRESSIVE DELIVERY ROUTES ===
+
+	registerCanaryRoutes(e)
+	registerRolloutRoutes(e)
+
+	// === END PROGRESSIVE DELIVERY ROUTES ===
+
+	// === CHAOS EXPERIMENT ROUTES ===
+
+	registerChaosRoutes(e)
+
+	// === END CHAOS EXPERIMENT ROUTES ===
+
+	// === ISTIO SECURITY ROUTES ===
+
+	registerMTLSRoutes(e)
+	registerAuthorizationPolicyRoutes(e)
+
+	// === END ISTIO SECURITY ROUTES ===
+
+	// === ISTIO GATEWAY ROUTES ===
+
+	registerGatewayRoutes(e)
+
+	// === END ISTIO GATEWAY ROUTES ===
+
+	// === ISTIO PROXY ROUTES ===
+
+	registerIstioProxyRoutes(e)
+
+	// === END ISTIO PROXY ROUTES ===
+
+	// === CONFIGURATION ANALYSIS ROUTES ===
+
+	registerAnalyzeRoutes(e)
+
+	// === END CONFIGURATION ANALYSIS ROUTES ===
+
+	// === LINKERD INSTALL ROUTES ===
+
+	registerLinkerdInstallRoutes(e)
+
+	// === END LINKERD INSTALL ROUTES ===
+
+	// === LINKERD DATA PLANE ROUTES ===
+
+	registerLinkerdStatsRoutes(e)
+	registerLinkerdInjectionRoutes(e)
+	registerServiceProfileRoutes(e)
+	registerLinkerdTapRoutes(e)
+
+	// === END LINKERD DATA PLANE ROUTES ===
+
+	// === LINKERD POLICY ROUTES ===
+
+	registerLinkerdPolicyRoutes(e)
+
+	// === END LINKERD POLICY ROUTES ===
+
+	// === LINKERD IDENTITY ROUTES ===
+
+	registerLinkerdCertificateRoutes(e)
+
+	// === END LINKERD IDENTITY ROUTES ===
+
+	// === CILIUM ROUTES ===
+
+	registerCiliumRoutes(e)
+	registerCiliumFlowRoutes(e)
+	registerCiliumPolicyRoutes(e)
+
+	// === END CILIUM ROUTES ===
+
 	// Add this endpoint after the existing Istio endpoints (around line 3400)
 
 	// Deploy Istio Bookinfo application
@@ -3600,18 +3603,8 @@ func main() {
 
 		// Get ingress IP if available
 		ingressIP := "unavailable"
-		services, err := clientset.CoreV1().Services("istio-system").List(context.TODO(), metav1.ListOptions{
-			LabelSelector: "istio=ingressgateway",
-		})
-		if err == nil && len(services.Items) > 0 {
-			service := services.Items[0]
-			if len(service.Status.LoadBalancer.Ingress) > 0 {
-				if service.Status.LoadBalancer.Ingress[0].IP != "" {
-					ingressIP = service.Status.LoadBalancer.Ingress[0].IP
-				} else if service.Status.LoadBalancer.Ingress[0].Hostname != "" {
-					ingressIP = service.Status.LoadBalancer.Ingress[0].Hostname
-				}
-			}
+		if addresses, err := getGatewayAddresses(defaultGatewaySelector); err == nil && len(addresses) > 0 {
+			ingressIP = addresses[0]
 		}
 
 		return c.JSON(http.StatusOK, map[string]interface{}{
@@ -3846,6 +3839,15 @@ func main() {
 				"icon":        "cilium",
 			},
 		}
+
+		// Report the running Cilium version when the agent is installed
+		if agent, err := findCiliumAgent(); err == nil && agent != nil && len(agent.Spec.Template.Spec.Containers) > 0 {
+			if version := imageTag(agent.Spec.Template.Spec.Containers[0].Image); version != "" {
+				cilium := adapters["cilium"].(map[string]interface{})
+				cilium["version"] = strings.TrimPrefix(version, "v")
+				cilium["status"] = "installed"
+			}
+		}
 		
 		return c.JSON(http.StatusOK, adapters)
 	})
@@ -4065,8 +4067,8 @@ func checkIstioCLI() error {
 }
 
 // Download and install Istio CLI
-func downloadIstio() error {
-	log.Println("Downloading Istio...")
+func downloadIstio(version string) error {
+	log.Printf("Downloading Istio %s...", version)
 	
 	// Create temp directory
 	tmpDir, err := ioutil.TempDir("", "istio-download-")
@@ -4090,10 +4092,15 @@ func downloadIstio() error {
 		return fmt.Errorf("failed to make script executable: %v", err)
 	}
 	
-	// Run the installation script
+	// Run the installation script in the mirror so the release shows up as an
+	// available version
+	mirrorDir := getIstioMirrorDir()
+	if err := os.MkdirAll(mirrorDir, 0755); err != nil {
+		return fmt.Errorf("failed to create Istio mirror directory: %v", err)
+	}
 	installCmd := exec.Command("sh", scriptPath)
-	installCmd.Dir = tmpDir
-	installCmd.Env = append(os.Environ(), "ISTIO_VERSION=1.17.2")
+	installCmd.Dir = mirrorDir
+	installCmd.Env = append(os.Environ(), "ISTIO_VERSION="+version)
 	
 	output, err := installCmd.CombinedOutput()
 	if err != nil {
@@ -4104,28 +4111,6 @@ func downloadIstio() error {
 	return nil
 }
 
-// Install Istio using istioctl
-func installIstio() error {
-	log.Println("Installing Istio...")
-	
-	// Install Istio with default profile
-	installCmd := exec.Command("istioctl", "install", "--set", "values.defaultRevision=default", "-y")
-	output, err := installCmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to install Istio: %v, output: %s", err, output)
-	}
-	
-	log.Printf("Istio installation completed: %s", output)
-	
-	// Enable Istio injection for default namespace
-	labelCmd := exec.Command("kubectl", "label", "namespace", "default", "istio-injection=enabled", "--overwrite")
-	if err := labelCmd.Run(); err != nil {
-		log.Printf("Warning: Failed to label default namespace: %v", err)
-	}
-	
-	return nil
-}
-
 // Get real Istio installation status
 func getRealIstioStatus() (map[string]interface{}, error) {
 	// Check if istioctl is available
@@ -4149,11 +4134,32 @@ func getRealIstioStatus() (map[string]interface{}, error) {
 	}
 	
 	// Get Istio proxy status
-	proxyCmd := exec.Command("istioctl", "proxy-status")
-	proxyOutput, _ := proxyCmd.Output()
+	proxyStatus, err := getProxySyncStatus()
+	if err != nil {
+		log.Printf("Warning: Could not get proxy status: %v", err)
+		proxyStatus = []ProxySyncStatus{}
+	}
 	
 	// Parse version
 	versionStr := strings.TrimSpace(string(versionOutput))
+
+	virtualServices, err := getVirtualServices("")
+	if err != nil {
+		log.Printf("Warning: Could not get VirtualServices: %v", err)
+		virtualServices = []VirtualService{}
+	}
+
+	gateways, err := getGateways("")
+	if err != nil {
+		log.Printf("Warning: Could not get Gateways: %v", err)
+		gateways = []Gateway{}
+	}
+
+	mtls, err := getMTLSSummary()
+	if err != nil {
+		log.Printf("Warning: Could not get mTLS posture: %v", err)
+		mtls = map[string]interface{}{}
+	}
 	
 	// Get Kubernetes client for component information
 	config, err := rest.InClusterConfig()
@@ -4211,10 +4217,11 @@ func getRealIstioStatus() (map[string]interface{}, error) {
 		"version": versionStr,
 		"components": components,
 		"services": istioServices,
-		"proxy_status": string(proxyOutput),
+		"proxy_status": proxyStatus,
 		"namespaces": []string{"istio-system"},
-		"virtual_services": []interface{}{},
-		"gateways": []interface{}{},
+		"virtual_services": virtualServices,
+		"gateways": gateways,
+		"mtls": mtls,
 	}, nil
 }
 
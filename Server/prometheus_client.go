@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Minimal client for the Prometheus HTTP query API
+
+type PromSample struct {
+	Metric map[string]string `json:"metric"`
+	Value  float64           `json:"value"`
+}
+
+type promQueryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+var errNoPrometheusData = errors.New("query returned no data")
+
+var prometheusHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Prometheus base URL from PROMETHEUS_URL, defaulting to the port-forward
+// address the dashboard links to
+func getPrometheusURL() string {
+	if promURL := os.Getenv("PROMETHEUS_URL"); promURL != "" {
+		return strings.TrimRight(promURL, "/")
+	}
+	return "http://localhost:9090"
+}
+
+// Run an instant query and return every sample in the result vector
+func queryPrometheus(query string) ([]PromSample, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/query?query=%s", getPrometheusURL(), url.QueryEscape(query))
+
+	resp, err := prometheusHTTPClient.Get(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result promQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode Prometheus response: %v", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("prometheus %s: %s", result.ErrorType, result.Error)
+	}
+
+	var samples []PromSample
+	for _, r := range result.Data.Result {
+		if len(r.Value) != 2 {
+			continue
+		}
+		raw, ok := r.Value[1].(string)
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, PromSample{Metric: r.Metric, Value: value})
+	}
+
+	return samples, nil
+}
+
+// Run a query expected to produce a single value. NaN results, such as a
+// success rate with no requests, count as no data.
+func queryPrometheusScalar(query string) (float64, error) {
+	samples, err := queryPrometheus(query)
+	if err != nil {
+		return 0, err
+	}
+	if len(samples) == 0 || math.IsNaN(samples[0].Value) {
+		return 0, errNoPrometheusData
+	}
+	return samples[0].Value, nil
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// Automated progressive delivery. A rollout drives a canary through its
+// steps on a fixed interval, running PromQL success-rate and latency checks
+// on every controller tick. Any failing check rolls the canary back; a step
+// only advances once its interval has elapsed with all checks passing. A
+// check without data (no traffic yet), or one Prometheus could not answer,
+// is inconclusive: the tick is retried and only a run of max_inconclusive
+// such ticks rolls back.
+
+type Rollout struct {
+	ID          string          `json:"id"`
+	Canary      Canary          `json:"canary"`
+	Interval    string          `json:"interval"`
+	Analysis    RolloutAnalysis `json:"analysis"`
+	Status      string          `json:"status"`
+	Message     string          `json:"message,omitempty"`
+	CurrentStep int             `json:"current_step"`
+	Steps       []RolloutStep   `json:"steps"`
+	StartedAt   time.Time       `json:"started_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+
+	// A weight change is being applied with the rollout lock released
+	updating bool
+}
+
+type RolloutAnalysis struct {
+	SuccessRateQuery string   `json:"success_rate_query"`
+	MinSuccessRate   *float64 `json:"min_success_rate"`
+	LatencyQuery     string   `json:"latency_query"`
+	MaxLatencyMs     float64  `json:"max_latency_ms"`
+	AllowNoData      bool     `json:"allow_no_data"`
+	MaxInconclusive  int      `json:"max_inconclusive"`
+}
+
+type RolloutStep struct {
+	Weight      int        `json:"weight"`
+	Status      string     `json:"status"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Analyses    int        `json:"analyses"`
+	// Consecutive ticks without data
+	Inconclusive int           `json:"inconclusive"`
+	Checks       []MetricCheck `json:"checks"`
+}
+
+// MetricCheck is the evidence for one PromQL check
+type MetricCheck struct {
+	Name       string  `json:"name"`
+	Query      string  `json:"query"`
+	Value      float64 `json:"value"`
+	Threshold  float64 `json:"threshold"`
+	Comparison string  `json:"comparison"`
+	Passed     bool    `json:"passed"`
+	// The query returned no data or Prometheus could not be queried, so the
+	// check neither passed nor failed
+	Inconclusive bool      `json:"inconclusive"`
+	Error        string    `json:"error,omitempty"`
+	Time         time.Time `json:"time"`
+}
+
+const (
+	RolloutStatusRunning    = "Running"
+	RolloutStatusSucceeded  = "Succeeded"
+	RolloutStatusRolledBack = "RolledBack"
+	RolloutStatusAborted    = "Aborted"
+	RolloutStatusFailed     = "Failed"
+
+	RolloutStepPending   = "Pending"
+	RolloutStepRunning   = "Running"
+	RolloutStepPassed    = "Passed"
+	RolloutStepFailed    = "Failed"
+	RolloutStepCancelled = "Cancelled"
+
+	defaultRolloutMinSuccessRate  = 99
+	defaultRolloutMaxInconclusive = 10
+)
+
+// Default analysis queries per mesh. Placeholders are filled in from the canary.
+var defaultRolloutQueries = map[string]RolloutAnalysis{
+	"istio": {
+		SuccessRateQuery: `sum(rate(istio_requests_total{reporter="destination",destination_workload_namespace="{{namespace}}",destination_service_name="{{service}}",destination_version="{{canary_version}}",response_code!~"5.."}[1m])) / sum(rate(istio_requests_total{reporter="destination",destination_workload_namespace="{{namespace}}",destination_service_name="{{service}}",destination_version="{{canary_version}}"}[1m])) * 100`,
+		LatencyQuery:     `histogram_quantile(0.99, sum(rate(istio_request_duration_milliseconds_bucket{reporter="destination",destination_workload_namespace="{{namespace}}",destination_service_name="{{service}}",destination_version="{{canary_version}}"}[1m])) by (le))`,
+	},
+	"linkerd": {
+		SuccessRateQuery: `sum(rate(response_total{namespace="{{namespace}}",deployment="{{canary_backend}}",direction="inbound",classification="success"}[1m])) / sum(rate(response_total{namespace="{{namespace}}",deployment="{{canary_backend}}",direction="inbound"}[1m])) * 100`,
+		LatencyQuery:     `histogram_quantile(0.99, sum(rate(response_latency_ms_bucket{namespace="{{namespace}}",deployment="{{canary_backend}}",direction="inbound"}[1m])) by (le))`,
+	},
+}
+
+// Global rollout registry
+var (
+	rollouts      = make(map[string]*Rollout)
+	rolloutsMutex sync.Mutex
+)
+
+func renderRolloutQuery(query string, canary Canary) string {
+	return strings.NewReplacer(
+		"{{namespace}}", canary.Namespace,
+		"{{service}}", canary.Service,
+		"{{stable_version}}", canary.StableVersion,
+		"{{canary_version}}", canary.CanaryVersion,
+		"{{canary_backend}}", canary.CanaryBackend,
+	).Replace(query)
+}
+
+// Run the rollout's checks against Prometheus
+func runRolloutAnalysis(analysis RolloutAnalysis, canary Canary) []MetricCheck {
+	now := time.Now()
+	checks := []MetricCheck{
+		{
+			Name:       "success_rate",
+			Query:      renderRolloutQuery(analysis.SuccessRateQuery, canary),
+			Threshold:  *analysis.MinSuccessRate,
+			Comparison: ">=",
+			Time:       now,
+		},
+		{
+			Name:       "latency_p99_ms",
+			Query:      renderRolloutQuery(analysis.LatencyQuery, canary),
+			Threshold:  analysis.MaxLatencyMs,
+			Comparison: "<=",
+			Time:       now,
+		},
+	}
+
+	for i := range checks {
+		check := &checks[i]
+		value, err := queryPrometheusScalar(check.Query)
+		if err != nil {
+			// Timeouts, refused connections and 5xx responses say nothing
+			// about the canary, so they never fail a check on their own
+			check.Error = err.Error()
+			if err == errNoPrometheusData && analysis.AllowNoData {
+				check.Passed = true
+			} else {
+				check.Inconclusive = true
+			}
+			continue
+		}
+
+		check.Value = value
+		if check.Comparison == ">=" {
+			check.Passed = value >= check.Threshold
+		} else {
+			check.Passed = value <= check.Threshold
+		}
+	}
+
+	return checks
+}
+
+// Report whether any check failed outright and whether any was inconclusive
+func rolloutCheckResult(checks []MetricCheck) (failed, inconclusive bool) {
+	for _, check := range checks {
+		switch {
+		case check.Inconclusive:
+			inconclusive = true
+		case !check.Passed:
+			failed = true
+		}
+	}
+	return failed, inconclusive
+}
+
+func failedCheckSummary(checks []MetricCheck) string {
+	var failed []string
+	for _, check := range checks {
+		if check.Passed || check.Inconclusive {
+			continue
+		}
+		if check.Error != "" {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Error))
+		} else {
+			failed = append(failed, fmt.Sprintf("%s %.2f not %s %.2f", check.Name, check.Value, check.Comparison, check.Threshold))
+		}
+	}
+	return strings.Join(failed, "; ")
+}
+
+// Move the rollout's canary to a weight. The rollout lock must be held on
+// entry; it is released while kubectl runs, so API reads are not blocked
+// behind it, and held again on return. updating keeps the controller and
+// the abort route off the rollout in the meantime.
+func setRolloutCanaryWeight(rollout *Rollout, weight int, action, message string) error {
+	rollout.updating = true
+	namespace, service := rollout.Canary.Namespace, rollout.Canary.Service
+	rolloutsMutex.Unlock()
+
+	canary, err := func() (*Canary, error) {
+		canariesMutex.Lock()
+		defer canariesMutex.Unlock()
+
+		canary, exists := canaries[canaryKey(namespace, service)]
+		if !exists {
+			return nil, fmt.Errorf("canary %s/%s no longer exists", namespace, service)
+		}
+		if err := setCanaryWeight(canary, weight, action, message); err != nil {
+			return nil, err
+		}
+		updated := *canary
+		return &updated, nil
+	}()
+
+	rolloutsMutex.Lock()
+	rollout.updating = false
+	if err != nil {
+		return err
+	}
+	rollout.Canary = *canary
+	return nil
+}
+
+func finishRollout(rollout *Rollout, status, message string) {
+	now := time.Now()
+	rollout.Status = status
+	rollout.Message = message
+	rollout.FinishedAt = &now
+	log.Printf("Rollout %s finished: %s %s", rollout.ID, status, message)
+}
+
+// Roll the canary back and mark the current step and the rollout as failed
+func rollbackRollout(rollout *Rollout, status, message string) {
+	now := time.Now()
+	step := &rollout.Steps[rollout.CurrentStep]
+	step.Status = RolloutStepFailed
+	if status == RolloutStatusAborted {
+		step.Status = RolloutStepCancelled
+	}
+	step.CompletedAt = &now
+	for i := rollout.CurrentStep + 1; i < len(rollout.Steps); i++ {
+		rollout.Steps[i].Status = RolloutStepCancelled
+	}
+
+	if err := setRolloutCanaryWeight(rollout, 0, "rollback", message); err != nil {
+		finishRollout(rollout, RolloutStatusFailed, fmt.Sprintf("%s; rollback failed: %v", message, err))
+		return
+	}
+	finishRollout(rollout, status, message)
+}
+
+// Evaluate one running rollout. Prometheus is queried, and weights are
+// applied, without holding the rollout lock so API reads are not blocked
+// behind slow queries or kubectl.
+func reconcileRollout(id string) {
+	rolloutsMutex.Lock()
+	rollout, exists := rollouts[id]
+	if !exists || rollout.Status != RolloutStatusRunning || rollout.updating {
+		rolloutsMutex.Unlock()
+		return
+	}
+	analysis := rollout.Analysis
+	canary := rollout.Canary
+	rolloutsMutex.Unlock()
+
+	checks := runRolloutAnalysis(analysis, canary)
+
+	rolloutsMutex.Lock()
+	defer rolloutsMutex.Unlock()
+
+	if rollout.Status != RolloutStatusRunning || rollout.updating {
+		return
+	}
+
+	step := &rollout.Steps[rollout.CurrentStep]
+	step.Analyses++
+	step.Checks = checks
+
+	failed, inconclusive := rolloutCheckResult(checks)
+	if failed {
+		rollbackRollout(rollout, RolloutStatusRolledBack, fmt.Sprintf("Analysis failed at %d%%: %s", step.Weight, failedCheckSummary(checks)))
+		return
+	}
+	if inconclusive {
+		step.Inconclusive++
+		if step.Inconclusive >= analysis.MaxInconclusive {
+			rollbackRollout(rollout, RolloutStatusRolledBack, fmt.Sprintf("No usable metrics at %d%% after %d analyses", step.Weight, step.Inconclusive))
+		}
+		return
+	}
+	step.Inconclusive = 0
+
+	interval, _ := time.ParseDuration(rollout.Interval)
+	if time.Since(*step.StartedAt) < interval {
+		return
+	}
+
+	now := time.Now()
+	step.Status = RolloutStepPassed
+	step.CompletedAt = &now
+
+	if rollout.CurrentStep == len(rollout.Steps)-1 {
+		finishRollout(rollout, RolloutStatusSucceeded, fmt.Sprintf("%s fully promoted", rollout.Canary.CanaryVersion))
+		return
+	}
+
+	rollout.CurrentStep++
+	next := &rollout.Steps[rollout.CurrentStep]
+	if err := setRolloutCanaryWeight(rollout, next.Weight, "promote", "rollout "+rollout.ID); err != nil {
+		rollbackRollout(rollout, RolloutStatusFailed, fmt.Sprintf("Failed to promote to %d%%: %v", next.Weight, err))
+		return
+	}
+	next.Status = RolloutStepRunning
+	next.StartedAt = &now
+}
+
+// Start the background rollout controller
+func startRolloutController() {
+	log.Println("Starting progressive delivery controller...")
+
+	ticker := time.NewTicker(30 * time.Second)
+	go func() {
+		for range ticker.C {
+			rolloutsMutex.Lock()
+			var running []string
+			for id, rollout := range rollouts {
+				if rollout.Status == RolloutStatusRunning {
+					running = append(running, id)
+				}
+			}
+			rolloutsMutex.Unlock()
+
+			for _, id := range running {
+				reconcileRollout(id)
+			}
+		}
+	}()
+}
+
+func registerRolloutRoutes(e *echo.Echo) {
+	// List rollouts, newest first
+	e.GET("/api/rollouts", func(c echo.Context) error {
+		rolloutsMutex.Lock()
+		list := []Rollout{}
+		for _, rollout := range rollouts {
+			list = append(list, *rollout)
+		}
+		rolloutsMutex.Unlock()
+
+		sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.After(list[j].StartedAt) })
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"rollouts": list,
+			"count":    len(list),
+		})
+	})
+
+	// Start a rollout. The canary is created at the first step weight.
+	e.POST("/api/rollouts", func(c echo.Context) error {
+		var request struct {
+			Canary
+			Interval string          `json:"interval"`
+			Analysis RolloutAnalysis `json:"analysis"`
+		}
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid rollout payload",
+			})
+		}
+
+		canary := request.Canary
+		normalizeCanary(&canary)
+		canary.CanaryWeight = canary.Steps[0]
+
+		errors := validateCanary(canary)
+		if request.Interval == "" {
+			request.Interval = "5m"
+		}
+		if _, err := time.ParseDuration(request.Interval); err != nil {
+			errors = append(errors, fmt.Sprintf("interval: invalid duration %q", request.Interval))
+		}
+		if canary.Steps[len(canary.Steps)-1] != 100 {
+			errors = append(errors, "the last step must be 100")
+		}
+
+		analysis := request.Analysis
+		defaults := defaultRolloutQueries[canary.Mesh]
+		if analysis.SuccessRateQuery == "" {
+			analysis.SuccessRateQuery = defaults.SuccessRateQuery
+		}
+		if analysis.LatencyQuery == "" {
+			analysis.LatencyQuery = defaults.LatencyQuery
+		}
+		if analysis.MinSuccessRate == nil {
+			minSuccessRate := float64(defaultRolloutMinSuccessRate)
+			analysis.MinSuccessRate = &minSuccessRate
+		} else if *analysis.MinSuccessRate < 0 || *analysis.MinSuccessRate > 100 {
+			errors = append(errors, "analysis.min_success_rate must be between 0 and 100")
+		}
+		if analysis.MaxLatencyMs == 0 {
+			analysis.MaxLatencyMs = 500
+		}
+		if analysis.MaxInconclusive == 0 {
+			analysis.MaxInconclusive = defaultRolloutMaxInconclusive
+		} else if analysis.MaxInconclusive < 0 {
+			errors = append(errors, "analysis.max_inconclusive must not be negative")
+		}
+
+		if len(errors) > 0 {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"valid":  false,
+				"errors": errors,
+			})
+		}
+
+		rolloutsMutex.Lock()
+		defer rolloutsMutex.Unlock()
+
+		for _, existing := range rollouts {
+			if existing.Status == RolloutStatusRunning && existing.Canary.Namespace == canary.Namespace && existing.Canary.Service == canary.Service {
+				return c.JSON(http.StatusConflict, map[string]string{
+					"error": fmt.Sprintf("Rollout %s is already running for %s/%s", existing.ID, canary.Namespace, canary.Service),
+				})
+			}
+		}
+
+		// A finished canary from an earlier run is replaced
+		if previous, exists := getCanary(canary.Namespace, canary.Service); exists && previous.Phase != CanaryPhaseProgressing {
+			canariesMutex.Lock()
+			delete(canaries, canaryKey(canary.Namespace, canary.Service))
+			canariesMutex.Unlock()
+		}
+
+		if status, err := startCanary(&canary); err != nil {
+			return c.JSON(status, map[string]string{
+				"error": fmt.Sprintf("Failed to start canary: %v", err),
+			})
+		}
+
+		now := time.Now()
+		rollout := &Rollout{
+			ID:        fmt.Sprintf("%s-%s-%d", canary.Namespace, canary.Service, now.Unix()),
+			Canary:    canary,
+			Interval:  request.Interval,
+			Analysis:  analysis,
+			Status:    RolloutStatusRunning,
+			StartedAt: now,
+		}
+		for i, weight := range canary.Steps {
+			step := RolloutStep{Weight: weight, Status: RolloutStepPending, Checks: []MetricCheck{}}
+			if i == 0 {
+				step.Status = RolloutStepRunning
+				step.StartedAt = &now
+			}
+			rollout.Steps = append(rollout.Steps, step)
+		}
+		rollouts[rollout.ID] = rollout
+
+		return c.JSON(http.StatusCreated, map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Rollout started at %d%% canary traffic", canary.CanaryWeight),
+			"rollout": rollout,
+		})
+	})
+
+	// Get a rollout with its per-step evidence
+	e.GET("/api/rollouts/:id", func(c echo.Context) error {
+		rolloutsMutex.Lock()
+		defer rolloutsMutex.Unlock()
+
+		rollout, exists := rollouts[c.Param("id")]
+		if !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Rollout not found",
+			})
+		}
+		return c.JSON(http.StatusOK, rollout)
+	})
+
+	// Abort a running rollout and roll the canary back
+	e.POST("/api/rollouts/:id/abort", func(c echo.Context) error {
+		rolloutsMutex.Lock()
+		defer rolloutsMutex.Unlock()
+
+		rollout, exists := rollouts[c.Param("id")]
+		if !exists {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Rollout not found",
+			})
+		}
+		if rollout.Status != RolloutStatusRunning {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("Rollout is already %s", rollout.Status),
+			})
+		}
+		if rollout.updating {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Rollout is changing the canary weight, try again shortly",
+			})
+		}
+
+		rollbackRollout(rollout, RolloutStatusAborted, "Aborted by user")
+
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"success": rollout.Status == RolloutStatusAborted,
+			"message": rollout.Message,
+			"rollout": rollout,
+		})
+	})
+}